@@ -0,0 +1,98 @@
+package main
+
+// aliases module provides aliases of ML model versions, e.g. production
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	mongo "github.com/CHESSComputing/golib/mongo"
+)
+
+// aliasRecords retrieves aliases matching given spec from MLHub database
+func aliasRecords(spec map[string]any) ([]Alias, error) {
+	results := mongo.Get(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Aliases.Collection,
+		spec, 0, -1)
+	var aliases []Alias
+	for _, rec := range results {
+		var a Alias
+		delete(rec, "_id")
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return aliases, fmt.Errorf("[MLHub.main.aliasRecords] json.Marshal error: %w", err)
+		}
+		err = json.Unmarshal(data, &a)
+		if err != nil {
+			return aliases, fmt.Errorf("[MLHub.main.aliasRecords] json.Unmarshal error: %w", err)
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, nil
+}
+
+// promote assigns alias to given ML model version
+func promote(alias Alias) error {
+	if alias.Model == "" || alias.Type == "" || alias.Version == "" || alias.Alias == "" {
+		return errors.New("promote requires model, type, version and alias")
+	}
+	records, err := metaRecords(alias.Model, alias.Type, alias.Version)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.promote] metaRecords error: %w", err)
+	}
+	if len(records) == 0 {
+		msg := fmt.Sprintf("ML model %s type %s version %s does not exist", alias.Model, alias.Type, alias.Version)
+		return errors.New(msg)
+	}
+	spec := map[string]any{"model": alias.Model, "type": alias.Type, "alias": alias.Alias}
+	meta := map[string]any{
+		"model":   alias.Model,
+		"type":    alias.Type,
+		"alias":   alias.Alias,
+		"version": alias.Version,
+	}
+	if Verbose > 0 {
+		log.Printf("promote %+v", alias)
+	}
+	err = mongo.UpsertRecord(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Aliases.Collection,
+		spec,
+		meta)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.promote] mongo.UpsertRecord error: %w", err)
+	}
+	notifyWebhooks(AuditPromote, records[0], "alias="+alias.Alias)
+	return nil
+}
+
+// resolveVersion resolves alias of ML model to its version, if given version
+// is not an alias it is returned as is
+func resolveVersion(model, mlType, version string) string {
+	if model == "" || version == "" {
+		return version
+	}
+	spec := map[string]any{"model": model, "alias": version}
+	if mlType != "" {
+		spec["type"] = mlType
+	}
+	aliases, err := aliasRecords(spec)
+	if err != nil {
+		log.Printf("ERROR: unable to resolve alias %s of model %s, error %v", version, model, err)
+		return version
+	}
+	if len(aliases) == 1 {
+		if Verbose > 0 {
+			log.Printf("resolve model %s alias %s to version %s", model, version, aliases[0].Version)
+		}
+		return aliases[0].Version
+	}
+	return version
+}
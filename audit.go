@@ -0,0 +1,149 @@
+package main
+
+// audit module provides append-only audit log of ML model lifecycle events
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	mongo "github.com/CHESSComputing/golib/mongo"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/peer"
+)
+
+// audited events of ML model lifecycle
+const (
+	AuditUpload   = "upload"
+	AuditUpdate   = "update"
+	AuditPromote  = "promote"
+	AuditDownload = "download"
+	AuditDelete   = "delete"
+	AuditRestore  = "restore"
+	AuditPurge    = "purge"
+)
+
+// AuditEvents lists audited events of ML model lifecycle
+var AuditEvents = []string{AuditUpload, AuditUpdate, AuditPromote, AuditDownload, AuditDelete, AuditRestore, AuditPurge}
+
+// outcomes of audited actions
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// auditEvent appends event of ML model lifecycle to MLHub audit log. Audit
+// log is append-only, i.e. MLHub never updates or removes its records
+func auditEvent(event string, rec Record, r *http.Request, clientIP string, details string, aerr error) {
+	evt := AuditEvent{
+		Event:     event,
+		User:      requestUser(r),
+		Timestamp: time.Now().Unix(),
+		Model:     rec.Model,
+		Type:      rec.Type,
+		Version:   rec.Version,
+		ClientIP:  clientIP,
+		Outcome:   AuditSuccess,
+		Details:   details,
+	}
+	if aerr != nil {
+		evt.Outcome = AuditFailure
+		evt.Error = aerr.Error()
+	}
+	if Verbose > 0 {
+		log.Printf("audit event %+v", evt)
+	}
+	var records []any
+	records = append(records, evt)
+	err := mongo.UpsertAny(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Audit.Collection,
+		records)
+	if err != nil {
+		log.Printf("ERROR: unable to record audit event %+v, error %v", evt, err)
+	}
+}
+
+// helper function to audit event of HTTP request
+func auditRequest(c *gin.Context, event string, rec Record, details string, err error) {
+	auditEvent(event, rec, c.Request, c.ClientIP(), details, err)
+}
+
+// helper function to get IP address of gRPC client
+func grpcClientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// helper function to get spec of audit events from query parameters of HTTP
+// request, since and until parameters provide range of event timestamps
+func auditSpec(r *http.Request) (map[string]any, error) {
+	spec := map[string]any{}
+	if event := r.FormValue("event"); event != "" && !slices.Contains(AuditEvents, event) {
+		msg := fmt.Sprintf("unsupported audit event '%s', should be one of %v", event, AuditEvents)
+		return spec, errors.New(msg)
+	}
+	for _, key := range []string{"event", "user", "model", "type", "version", "outcome"} {
+		if val := r.FormValue(key); val != "" {
+			spec[key] = val
+		}
+	}
+	tsRange := map[string]any{}
+	for key, op := range map[string]string{"since": "$gte", "until": "$lte"} {
+		val := r.FormValue(key)
+		if val == "" {
+			continue
+		}
+		ts, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			msg := fmt.Sprintf("%s parameter should be unix timestamp, got '%s'", key, val)
+			return spec, errors.New(msg)
+		}
+		tsRange[op] = ts
+	}
+	if len(tsRange) > 0 {
+		spec["timestamp"] = tsRange
+	}
+	return spec, nil
+}
+
+// auditRecords retrieves audit events matching given spec from MLHub database
+func auditRecords(spec map[string]any, idx, limit int) ([]AuditEvent, error) {
+	results := mongo.Get(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Audit.Collection,
+		spec, idx, limit)
+	var events []AuditEvent
+	for _, rec := range results {
+		var evt AuditEvent
+		delete(rec, "_id")
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return events, fmt.Errorf("[MLHub.main.auditRecords] json.Marshal error: %w", err)
+		}
+		err = json.Unmarshal(data, &evt)
+		if err != nil {
+			return events, fmt.Errorf("[MLHub.main.auditRecords] json.Unmarshal error: %w", err)
+		}
+		events = append(events, evt)
+	}
+	return events, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+// auth module provides helper functions to identify MLHub users
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	authz "github.com/CHESSComputing/golib/authz"
+	srvConfig "github.com/CHESSComputing/golib/config"
+	services "github.com/CHESSComputing/golib/services"
+	"github.com/gin-gonic/gin"
+)
+
+// helper function to get user name from bearer token of HTTP request
+func requestUser(r *http.Request) string {
+	token := authz.BearerToken(r)
+	if token == "" {
+		return ""
+	}
+	claims, err := authz.TokenClaims(token, srvConfig.Config.Authz.ClientID)
+	if err != nil {
+		if Verbose > 0 {
+			log.Printf("unable to get token claims, error %v", err)
+		}
+		return ""
+	}
+	return claims.CustomClaims.User
+}
+
+// helper function to check if user of HTTP request is MLHub administrator
+func isAdmin(r *http.Request) bool {
+	user := requestUser(r)
+	if user == "" {
+		return false
+	}
+	for _, admin := range HubConfig.Admins {
+		if admin == user {
+			return true
+		}
+	}
+	return false
+}
+
+// helper function to check that user of HTTP request is MLHub administrator,
+// it writes HTTP response and returns false otherwise
+func adminRequest(c *gin.Context) bool {
+	if isAdmin(c.Request) {
+		return true
+	}
+	rec := services.Response("MLHub", http.StatusForbidden, services.AuthError, errors.New("MLHub administrator privileges are required"))
+	c.JSON(http.StatusForbidden, rec)
+	return false
+}
+
+// helper function to check that user of HTTP request owns ML model, i.e.
+// uploaded all its versions matching given type and version, or is MLHub
+// administrator. It writes HTTP response and returns false otherwise
+func ownerRequest(c *gin.Context, model, mlType, version string) bool {
+	if isAdmin(c.Request) {
+		return true
+	}
+	records, err := metaRecords(model, mlType, version)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return false
+	}
+	if model == "" || len(records) == 0 {
+		msg := fmt.Sprintf("ML model %s type %s version %s not found", model, mlType, version)
+		rec := services.Response("MLHub", http.StatusNotFound, services.GenericError, errors.New(msg))
+		c.JSON(http.StatusNotFound, rec)
+		return false
+	}
+	user := requestUser(c.Request)
+	for _, r := range records {
+		if user == "" || r.UserName != user {
+			msg := fmt.Sprintf("ML model %s type %s belongs to other user", model, mlType)
+			rec := services.Response("MLHub", http.StatusForbidden, services.AuthError, errors.New(msg))
+			c.JSON(http.StatusForbidden, rec)
+			return false
+		}
+	}
+	return true
+}
+
+// helper function to check that user of HTTP request is MLHub administrator
+// or owner of ML model version in trash, it writes HTTP response and returns
+// false otherwise
+func trashOwnerRequest(c *gin.Context, spec Record) (Record, bool) {
+	rec, err := trashRecord(spec.Model, spec.Type, spec.Version)
+	if err != nil {
+		resp := services.Response("MLHub", http.StatusBadRequest, services.StorageError, err)
+		c.JSON(http.StatusBadRequest, resp)
+		return rec, false
+	}
+	if isAdmin(c.Request) {
+		return rec, true
+	}
+	if user := requestUser(c.Request); user == "" || rec.UserName != user {
+		msg := fmt.Sprintf("ML model %s type %s belongs to other user", spec.Model, spec.Type)
+		resp := services.Response("MLHub", http.StatusForbidden, services.AuthError, errors.New(msg))
+		c.JSON(http.StatusForbidden, resp)
+		return rec, false
+	}
+	return rec, true
+}
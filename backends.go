@@ -0,0 +1,144 @@
+package main
+
+// backends module provides health probes of ML backends
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	"github.com/gin-gonic/gin"
+)
+
+// errBackendUnavailable is returned when ML backend is unhealthy
+var errBackendUnavailable = errors.New("ML backend is unavailable")
+
+// backendsHealth keeps health status of ML backends keyed by backend (replica) URI
+var backendsHealth = struct {
+	sync.RWMutex
+	records map[string]BackendStatus
+}{records: make(map[string]BackendStatus)}
+
+// helper function to get health status of ML backend, backends which were
+// not probed yet are considered healthy
+func backendHealth(backend srvConfig.MLBackend) BackendStatus {
+	backendsHealth.RLock()
+	defer backendsHealth.RUnlock()
+	if status, ok := backendsHealth.records[backend.URI]; ok {
+		return status
+	}
+	return BackendStatus{Name: backend.Name, Type: backend.Type, URI: backend.URI, Healthy: true}
+}
+
+// helper function to update health status of ML backend with result of its
+// probe or request, backend becomes unhealthy after configured number of
+// consecutive failures and healthy again after first success
+func updateHealth(backend srvConfig.MLBackend, err error, latency time.Duration) BackendStatus {
+	backendsHealth.Lock()
+	defer backendsHealth.Unlock()
+	status, ok := backendsHealth.records[backend.URI]
+	if !ok {
+		status = BackendStatus{Healthy: true}
+	}
+	status.Name = backend.Name
+	status.Type = backend.Type
+	status.URI = backend.URI
+	status.LastCheck = time.Now().Unix()
+	status.Latency = float64(latency.Microseconds()) / 1000
+	if err == nil {
+		status.Healthy = true
+		status.Failures = 0
+		status.Error = ""
+	} else {
+		status.Failures++
+		status.Error = err.Error()
+		if status.Failures >= HubConfig.Health.Threshold {
+			if status.Healthy {
+				log.Printf("WARNING: ML backend %s %s is unhealthy, error %v", backend.Name, backend.URI, err)
+			}
+			status.Healthy = false
+		}
+	}
+	backendsHealth.records[backend.URI] = status
+	return status
+}
+
+// helper function to record failure of request to ML backend, only
+// connection errors are accounted since ML backend may legitimately reject
+// given input
+func backendFailure(backend srvConfig.MLBackend, err error) {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		updateHealth(backend, err, 0)
+	}
+}
+
+// helper function to probe ML backend
+func probeBackend(backend srvConfig.MLBackend) BackendStatus {
+	client := &http.Client{Timeout: time.Duration(HubConfig.Health.Timeout) * time.Second}
+	time0 := time.Now()
+	rsp, err := client.Get(backend.URI + HubConfig.Health.Path)
+	if err == nil {
+		rsp.Body.Close()
+		if rsp.StatusCode >= http.StatusInternalServerError {
+			err = fmt.Errorf("health probe responded with %s", rsp.Status)
+		}
+	}
+	return updateHealth(backend, err, time.Since(time0))
+}
+
+// helper function to probe all configured ML backends
+func probeBackends() {
+	var wg sync.WaitGroup
+	for _, backend := range srvConfig.Config.MLHub.ML.MLBackends {
+		for _, replica := range backendReplicas(backend) {
+			wg.Add(1)
+			go func(replica srvConfig.MLBackend) {
+				defer wg.Done()
+				status := probeBackend(replica)
+				if Verbose > 1 {
+					log.Printf("ML backend health %+v", status)
+				}
+			}(replica)
+		}
+	}
+	wg.Wait()
+}
+
+// HealthProbes periodically probes health of ML backends
+func HealthProbes() {
+	interval := time.Duration(HubConfig.Health.Interval) * time.Second
+	for {
+		probeBackends()
+		time.Sleep(interval)
+	}
+}
+
+// BackendsHandler provides health status of ML backends and their replicas via /backends
+func BackendsHandler(c *gin.Context) {
+	var records []BackendStatus
+	for _, backend := range srvConfig.Config.MLHub.ML.MLBackends {
+		for _, replica := range backendReplicas(backend) {
+			status := backendHealth(replica)
+			status.Requests = outstandingRequests(replica.URI)
+			status.Breaker = breakerState(replica)
+			records = append(records, status)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name == records[j].Name {
+			return records[i].URI < records[j].URI
+		}
+		return records[i].Name < records[j].Name
+	})
+	c.JSON(http.StatusOK, records)
+}
@@ -0,0 +1,314 @@
+package main
+
+// backup module provides export of MLHub into portable zip archive and its
+// import, e.g. to migrate MLHub between clusters or to take point-in-time
+// backups. The archive contains manifest, meta-data records, model cards and
+// ML bundles of ML models, and aliases of their versions
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// BackupFormat defines format version of MLHub backup archive
+const BackupFormat = 1
+
+// conflict policies of import of MLHub backup archive
+const (
+	SkipConflicts      = "skip"
+	OverwriteConflicts = "overwrite"
+)
+
+// statuses of imported ML models and aliases
+const (
+	ImportImported    = "imported"
+	ImportOverwritten = "overwritten"
+	ImportSkipped     = "skipped"
+	ImportFailed      = "failed"
+)
+
+// names of MLHub backup archive entries
+const (
+	backupManifest = "manifest.json"
+	backupAliases  = "aliases.json"
+	backupMetadata = "metadata.json"
+	backupCard     = "README.md"
+)
+
+// WriteBackup writes MLHub backup archive of all active ML models and their
+// aliases into provided writer. ML model versions are stored under
+// models/type/model/version directories of the archive
+func WriteBackup(w io.Writer, base string) error {
+	records, err := metaRecords("", "", "")
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.WriteBackup] metaRecords error: %w", err)
+	}
+	aliases, err := aliasRecords(map[string]any{})
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.WriteBackup] aliasRecords error: %w", err)
+	}
+	// check ML bundles first to not produce partial archive if any of them is missing
+	for _, rec := range records {
+		fname := filepath.Join(versionDir(StorageDir, rec), rec.Bundle)
+		if _, err := os.Stat(fname); err != nil {
+			msg := fmt.Sprintf("ML bundle of %s type %s version %s is missing, please run consistency check", rec.Model, rec.Type, rec.Version)
+			return errors.New(msg)
+		}
+	}
+	manifest := BackupManifest{
+		Format:  BackupFormat,
+		Created: time.Now().Unix(),
+		Source:  base,
+		Aliases: len(aliases),
+	}
+	zw := zip.NewWriter(w)
+	for _, rec := range records {
+		entry, err := writeBackupModel(zw, rec)
+		if err != nil {
+			return err
+		}
+		manifest.Models = append(manifest.Models, entry)
+	}
+	data, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.WriteBackup] json.Marshal error: %w", err)
+	}
+	if err := zipContent(zw, backupAliases, data); err != nil {
+		return err
+	}
+	data, err = json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.WriteBackup] json.Marshal error: %w", err)
+	}
+	if err := zipContent(zw, backupManifest, data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("[MLHub.main.WriteBackup] zip.Close error: %w", err)
+	}
+	if Verbose > 0 {
+		log.Printf("exported %d ML models and %d aliases", len(manifest.Models), len(aliases))
+	}
+	return nil
+}
+
+// helper function to write meta-data record, model card and ML bundle of
+// ML model version to backup archive
+func writeBackupModel(zw *zip.Writer, rec Record) (BackupEntry, error) {
+	digest, err := bundleDigest(rec)
+	if err != nil {
+		return BackupEntry{}, fmt.Errorf("[MLHub.main.writeBackupModel] bundleDigest error: %w", err)
+	}
+	entry := BackupEntry{
+		Model:   rec.Model,
+		Type:    rec.Type,
+		Version: rec.Version,
+		Bundle:  rec.Bundle,
+		Digest:  digest,
+		Path:    path.Join("models", rec.Type, rec.Model, rec.Version),
+	}
+	// replicas belong to ML backends of this MLHub and are set again on import
+	rec.Replicas = nil
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return entry, fmt.Errorf("[MLHub.main.writeBackupModel] json.Marshal error: %w", err)
+	}
+	if err := zipContent(zw, path.Join(entry.Path, backupMetadata), data); err != nil {
+		return entry, err
+	}
+	if err := zipContent(zw, path.Join(entry.Path, backupCard), []byte(ModelCard(rec))); err != nil {
+		return entry, err
+	}
+	file, err := os.Open(filepath.Join(versionDir(StorageDir, rec), rec.Bundle))
+	if err != nil {
+		return entry, fmt.Errorf("[MLHub.main.writeBackupModel] os.Open error: %w", err)
+	}
+	defer file.Close()
+	fw, err := zw.Create(path.Join(entry.Path, rec.Bundle))
+	if err != nil {
+		return entry, fmt.Errorf("[MLHub.main.writeBackupModel] zip.Create error: %w", err)
+	}
+	if _, err := io.Copy(fw, file); err != nil {
+		return entry, fmt.Errorf("[MLHub.main.writeBackupModel] io.Copy error: %w", err)
+	}
+	return entry, nil
+}
+
+// importBackup imports ML models and aliases from MLHub backup archive, ML
+// models are uploaded to MLHub storage and deployed to configured ML
+// backends. Existing versions and aliases are either skipped or overwritten
+// according to conflict policy, overwritten ML models are moved to trash
+func importBackup(ctx context.Context, fname, conflict string) (ImportReport, error) {
+	report := ImportReport{Conflict: conflict}
+	if conflict != SkipConflicts && conflict != OverwriteConflicts {
+		msg := fmt.Sprintf("unsupported conflict policy '%s', should be %s or %s", conflict, SkipConflicts, OverwriteConflicts)
+		return report, errors.New(msg)
+	}
+	zr, err := zip.OpenReader(fname)
+	if err != nil {
+		return report, fmt.Errorf("[MLHub.main.importBackup] zip.OpenReader error: %w", err)
+	}
+	defer zr.Close()
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	var manifest BackupManifest
+	if err := readBackupJSON(files, backupManifest, &manifest); err != nil {
+		return report, err
+	}
+	if manifest.Format != BackupFormat {
+		msg := fmt.Sprintf("unsupported backup format %d, should be %d", manifest.Format, BackupFormat)
+		return report, errors.New(msg)
+	}
+	for _, entry := range manifest.Models {
+		result := importBackupModel(ctx, files, entry, conflict)
+		if result.Error != "" {
+			log.Printf("ERROR: unable to import %s type %s version %s, error %s", entry.Model, entry.Type, entry.Version, result.Error)
+		}
+		report.Results = append(report.Results, result)
+	}
+	var aliases []Alias
+	if err := readBackupJSON(files, backupAliases, &aliases); err != nil {
+		return report, err
+	}
+	for _, alias := range aliases {
+		report.Results = append(report.Results, importBackupAlias(alias, conflict))
+	}
+	if Verbose > 0 {
+		log.Printf("imported backup of %s created at %s with %s conflict policy",
+			manifest.Source, time.Unix(manifest.Created, 0).Format(time.RFC3339), conflict)
+	}
+	return report, nil
+}
+
+// helper function to decode JSON entry of backup archive
+func readBackupJSON(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		msg := fmt.Sprintf("backup archive does not contain %s", name)
+		return errors.New(msg)
+	}
+	reader, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.readBackupJSON] zip.Open error: %w", err)
+	}
+	defer reader.Close()
+	if err := json.NewDecoder(reader).Decode(v); err != nil {
+		return fmt.Errorf("[MLHub.main.readBackupJSON] json.Decode error: %w", err)
+	}
+	return nil
+}
+
+// helper function to import ML model version from backup archive, the
+// digest of its ML bundle is verified against the manifest before upload
+func importBackupModel(ctx context.Context, files map[string]*zip.File, entry BackupEntry, conflict string) ImportResult {
+	result := ImportResult{Model: entry.Model, Type: entry.Type, Version: entry.Version, Status: ImportFailed}
+	var rec Record
+	if err := readBackupJSON(files, path.Join(entry.Path, backupMetadata), &rec); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if rec.Model != entry.Model || rec.Type != entry.Type || rec.Version != entry.Version || rec.Bundle != entry.Bundle {
+		result.Error = "meta-data record does not match backup manifest"
+		return result
+	}
+	// records of backup archive are checked before existing versions are trashed
+	if err := checkRemoteRecord(rec); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if _, err := mlBackend(rec.Backend, rec.Type); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	rec, err := validateUpload(rec)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	f, ok := files[path.Join(entry.Path, entry.Bundle)]
+	if !ok {
+		result.Error = fmt.Sprintf("backup archive does not contain ML bundle %s", entry.Bundle)
+		return result
+	}
+	bf := BundleFile{
+		Name: entry.Bundle,
+		Open: func() (io.ReadCloser, error) {
+			return f.Open()
+		},
+	}
+	digest, err := bundleFileDigest(bf)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if entry.Digest != "" && digest != entry.Digest {
+		result.Error = fmt.Sprintf("ML bundle digest %s does not match backup manifest digest %s", digest, entry.Digest)
+		return result
+	}
+	status := ImportImported
+	records, err := metaRecords(rec.Model, rec.Type, rec.Version)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if len(records) > 0 {
+		if conflict == SkipConflicts {
+			result.Status = ImportSkipped
+			return result
+		}
+		for _, r := range records {
+			if err := trashModel(ctx, r); err != nil {
+				result.Error = err.Error()
+				return result
+			}
+		}
+		status = ImportOverwritten
+	}
+	rec.Replicas = nil
+	rec.Deleted = 0
+	if err := Upload(ctx, rec, bf); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = status
+	return result
+}
+
+// helper function to import alias of ML model version from backup archive
+func importBackupAlias(alias Alias, conflict string) ImportResult {
+	result := ImportResult{Model: alias.Model, Type: alias.Type, Version: alias.Version, Alias: alias.Alias, Status: ImportFailed}
+	spec := map[string]any{"model": alias.Model, "type": alias.Type, "alias": alias.Alias}
+	existing, err := aliasRecords(spec)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	status := ImportImported
+	if len(existing) > 0 {
+		if existing[0].Version == alias.Version || conflict == SkipConflicts {
+			result.Status = ImportSkipped
+			return result
+		}
+		status = ImportOverwritten
+	}
+	if err := promote(alias); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = status
+	return result
+}
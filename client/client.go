@@ -0,0 +1,188 @@
+// Package client provides Go client of MLHub service
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ProgressFunc reports progress of data transfer of given file
+type ProgressFunc func(name string, done, total int64)
+
+// Client represents MLHub client
+type Client struct {
+	URL        string        // MLHub server URL
+	Token      string        // access token
+	HTTPClient *http.Client  // HTTP client to use
+	Retries    int           // number of retries of idempotent requests
+	Backoff    time.Duration // initial delay between retries
+	ChunkSize  int64         // size of upload chunks
+	Progress   ProgressFunc  // optional progress callback of uploads and downloads
+}
+
+// New creates new MLHub client for given server URL and access token
+func New(url, token string) *Client {
+	return &Client{
+		URL:        strings.TrimSuffix(url, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 5 * time.Minute},
+		Retries:    3,
+		Backoff:    500 * time.Millisecond,
+		ChunkSize:  8 << 20,
+	}
+}
+
+// Error represents error response of MLHub server
+type Error struct {
+	StatusCode int    `json:"-"`       // HTTP status code
+	Status     string `json:"-"`       // HTTP status
+	Body       string `json:"-"`       // raw response body
+	Service    string `json:"service"` // service name
+	Code       int    `json:"code"`    // MLHub error code
+	Message    string `json:"error"`   // error message
+}
+
+// Error implements error interface
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Body
+	}
+	return fmt.Sprintf("MLHub server responded with %s: %s", e.Status, msg)
+}
+
+// helper function to decode error response of MLHub server
+func decodeError(rsp *http.Response, body []byte) error {
+	e := &Error{StatusCode: rsp.StatusCode, Status: rsp.Status, Body: strings.TrimSpace(string(body))}
+	json.Unmarshal(body, e)
+	return e
+}
+
+// helper function to check if request with given method can be retried
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+// helper function to check if response status code is worth to retry
+func retryable(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusBadGateway ||
+		code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// helper function to create HTTP request to MLHub server
+func (c *Client) newRequest(ctx context.Context, method, path, ctype string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if ctype != "" {
+		req.Header.Set("Content-Type", ctype)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return req, nil
+}
+
+// helper function to perform HTTP request, idempotent requests are retried
+// on network errors and temporary failures of MLHub server
+func (c *Client) do(ctx context.Context, method, path, ctype string, body []byte) (*http.Response, error) {
+	retries := 0
+	if idempotent(method) {
+		retries = c.Retries
+	}
+	delay := c.Backoff
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := c.newRequest(ctx, method, path, ctype, reader)
+		if err != nil {
+			return nil, err
+		}
+		rsp, err := c.HTTPClient.Do(req)
+		if err == nil && !retryable(rsp.StatusCode) {
+			return rsp, nil
+		}
+		if attempt >= retries || ctx.Err() != nil {
+			return rsp, err
+		}
+		if rsp != nil {
+			io.Copy(io.Discard, rsp.Body)
+			rsp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// helper function to perform HTTP request and decode its JSON response
+func (c *Client) call(ctx context.Context, method, path, ctype string, body []byte, out any) error {
+	rsp, err := c.do(ctx, method, path, ctype, body)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return decodeError(rsp, data)
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// helper function to perform HTTP request with JSON payload
+func (c *Client) callJSON(ctx context.Context, method, path string, in, out any) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.call(ctx, method, path, "application/json", data, out)
+}
+
+// helper function to report progress of data transfer
+func (c *Client) progress(name string, done, total int64) {
+	if c.Progress != nil {
+		c.Progress(name, done, total)
+	}
+}
+
+// progressReader represents reader which reports progress of data transfer
+type progressReader struct {
+	reader io.Reader
+	client *Client
+	name   string
+	done   int64
+	total  int64
+}
+
+// Read implements io.Reader interface
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	p.done += int64(n)
+	p.client.progress(p.name, p.done, p.total)
+	return n, err
+}
@@ -0,0 +1,458 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mlhub "github.com/CHESSComputing/MLHub/mlhub"
+)
+
+// helper function to create test client of given test server
+func testClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c := New(srv.URL+"/", "secret")
+	c.Backoff = time.Millisecond
+	return c
+}
+
+// helper function to write MLHub error response
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{"service": "MLHub", "code": 1, "error": msg})
+}
+
+// TestListModels tests query parameters, auth header and decoding of ML records
+func TestListModels(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("unexpected authorization header %q", auth)
+		}
+		q := r.URL.Query()
+		if q.Get("type") != "TensorFlow" || q.Get("q") != "mnist" || q.Get("limit") != "10" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		if q.Has("backend") {
+			t.Errorf("empty filter is sent: %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode([]mlhub.Record{{Model: "mnist", Type: "TensorFlow", Version: "v1"}})
+	})
+	records, err := c.ListModels(context.Background(), ModelQuery{Query: "mnist", Type: "TensorFlow", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Model != "mnist" || records[0].Version != "v1" {
+		t.Errorf("unexpected records %+v", records)
+	}
+}
+
+// TestGetModel tests model path escaping and version alias
+func TestGetModel(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/model/my%20model" || r.URL.Query().Get("version") != "production" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		json.NewEncoder(w).Encode(mlhub.Record{Model: "my model", Version: "v2"})
+	})
+	rec, err := c.GetModel(context.Background(), "my model", "", "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Version != "v2" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+}
+
+// TestErrorDecoding tests decoding of MLHub error responses
+func TestErrorDecoding(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/model/") {
+			writeError(w, http.StatusNotFound, "no ML model found")
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("forbidden"))
+	})
+	_, err := c.GetModel(context.Background(), "mnist", "", "")
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected client error, got %v", err)
+	}
+	if e.StatusCode != http.StatusNotFound || e.Message != "no ML model found" || e.Service != "MLHub" {
+		t.Errorf("unexpected error %+v", e)
+	}
+	err = c.Delete(context.Background(), "mnist", "", "v1")
+	if !errors.As(err, &e) || e.StatusCode != http.StatusForbidden || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// TestRetries tests that idempotent requests are retried and others are not
+func TestRetries(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.Method]++
+		n := calls[r.Method]
+		mu.Unlock()
+		if n < 3 {
+			writeError(w, http.StatusServiceUnavailable, "busy")
+			return
+		}
+		json.NewEncoder(w).Encode([]mlhub.Record{})
+	})
+	if _, err := c.ListModels(context.Background(), ModelQuery{}); err != nil {
+		t.Fatal(err)
+	}
+	if calls["GET"] != 3 {
+		t.Errorf("expected 3 GET requests, got %d", calls["GET"])
+	}
+	if _, err := c.Predict(context.Background(), mlhub.Record{Model: "mnist"}, PredictInput{Input: 1}); err == nil {
+		t.Error("expected error of non-idempotent request")
+	}
+	if calls["POST"] != 1 {
+		t.Errorf("expected single POST request, got %d", calls["POST"])
+	}
+
+	// retries are exhausted
+	calls = map[string]int{}
+	c.Retries = 1
+	_, err := c.ListModels(context.Background(), ModelQuery{})
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// TestDelete tests delete request payload
+func TestDelete(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var rec mlhub.Record
+		if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+			t.Error(err)
+		}
+		if r.Method != "DELETE" || r.URL.Path != "/delete" || rec.Model != "mnist" || rec.Version != "v1" {
+			t.Errorf("unexpected request %s %s %+v", r.Method, r.URL, rec)
+		}
+		if ctype := r.Header.Get("Content-Type"); ctype != "application/json" {
+			t.Errorf("unexpected content type %s", ctype)
+		}
+	})
+	if err := c.Delete(context.Background(), "mnist", "TensorFlow", "v1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRestore tests restore and purge of ML models in trash
+func TestRestore(t *testing.T) {
+	var paths []string
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var rec mlhub.Record
+		if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+			t.Error(err)
+		}
+		if rec.Model != "mnist" || rec.Type != "TensorFlow" || rec.Version != "v1" {
+			t.Errorf("unexpected record %+v", rec)
+		}
+		paths = append(paths, r.Method+" "+r.URL.Path)
+	})
+	ctx := context.Background()
+	if err := c.Restore(ctx, "mnist", "TensorFlow", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Purge(ctx, "mnist", "TensorFlow", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(paths, ",") != "POST /restore,DELETE /purge" {
+		t.Errorf("unexpected requests %v", paths)
+	}
+}
+
+// TestUpload tests chunked upload which resumes from already uploaded offset
+func TestUpload(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 10))
+	fname := filepath.Join(t.TempDir(), "model.tar.gz")
+	if err := os.WriteFile(fname, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	rec := mlhub.Record{
+		Model: "mnist", Type: "TensorFlow", Version: "v1",
+		Provenance: mlhub.Provenance{Datasets: []string{"/a=1", "/b=2"}, Parameters: map[string]any{"epochs": 5}},
+	}
+	uploadID, err := UploadID(fname, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// server already has first 30 bytes of the upload
+	var mu sync.Mutex
+	received := append([]byte{}, content[:30]...)
+	var chunks int
+	var form map[string]string
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/uploads/"+uploadID:
+			json.NewEncoder(w).Encode(mlhub.UploadStatus{ID: uploadID, Size: int64(len(received))})
+		case r.Method == "PUT" && r.URL.Path == "/uploads/"+uploadID:
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			if offset != len(received) || r.URL.Query().Get("file") != "model.tar.gz" {
+				writeError(w, http.StatusConflict, "wrong offset")
+				return
+			}
+			data, _ := io.ReadAll(r.Body)
+			received = append(received, data...)
+			chunks++
+			json.NewEncoder(w).Encode(mlhub.UploadStatus{ID: uploadID, Size: int64(len(received))})
+		case r.Method == "POST" && r.URL.Path == "/upload":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Error(err)
+			}
+			form = map[string]string{}
+			for key, vals := range r.MultipartForm.Value {
+				form[key] = vals[0]
+			}
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	c.ChunkSize = 32
+	var progress []int64
+	c.Progress = func(name string, done, total int64) {
+		if name != "model.tar.gz" || total != int64(len(content)) {
+			t.Errorf("unexpected progress of %s %d/%d", name, done, total)
+		}
+		progress = append(progress, done)
+	}
+	if err := c.Upload(context.Background(), rec, fname); err != nil {
+		t.Fatal(err)
+	}
+	if string(received) != string(content) {
+		t.Errorf("uploaded content mismatch: %q", received)
+	}
+	if chunks != 3 {
+		t.Errorf("expected 3 chunks, got %d", chunks)
+	}
+	if len(progress) != 3 || progress[2] != int64(len(content)) {
+		t.Errorf("unexpected progress %v", progress)
+	}
+	expect := map[string]string{
+		"upload": uploadID, "model": "mnist", "type": "TensorFlow", "version": "v1",
+		"datasets": "/a=1,/b=2", "parameters": `{"epochs":5}`,
+	}
+	for key, val := range expect {
+		if form[key] != val {
+			t.Errorf("form field %s=%q, expected %q", key, form[key], val)
+		}
+	}
+	if _, ok := form["backend"]; ok {
+		t.Error("empty form field is sent")
+	}
+}
+
+// TestDownload tests download which resumes partially downloaded file
+func TestDownload(t *testing.T) {
+	content := strings.Repeat("abcdefghij", 10)
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/mnist" || r.URL.Query().Get("version") != "v1" {
+			writeError(w, http.StatusNotFound, "no ML model found")
+			return
+		}
+		http.ServeContent(w, r, "model.tar.gz", time.Time{}, strings.NewReader(content))
+	})
+	fname := filepath.Join(t.TempDir(), "model.tar.gz")
+	if err := os.WriteFile(fname, []byte(content[:40]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var done, total int64
+	c.Progress = func(name string, d, tot int64) { done, total = d, tot }
+	if err := c.Download(context.Background(), "mnist", "", "v1", fname); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("downloaded content mismatch: %q", data)
+	}
+	if done != int64(len(content)) || total != int64(len(content)) {
+		t.Errorf("unexpected progress %d/%d", done, total)
+	}
+
+	// complete file is not downloaded again
+	if err := c.Download(context.Background(), "mnist", "", "v1", fname); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(fname); string(data) != content {
+		t.Errorf("downloaded content mismatch: %q", data)
+	}
+
+	// missing model
+	err = c.Download(context.Background(), "unknown", "", "v1", filepath.Join(t.TempDir(), "x"))
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// TestExportImport tests export of backup archive and its import with conflict policy
+func TestExportImport(t *testing.T) {
+	archive := "PK backup archive"
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /export":
+			w.Header().Set("Content-Type", "application/zip")
+			w.Write([]byte(archive))
+		case "POST /import":
+			data, _ := io.ReadAll(r.Body)
+			if string(data) != archive || r.Header.Get("Content-Type") != "application/zip" {
+				writeError(w, http.StatusBadRequest, "unexpected backup archive")
+				return
+			}
+			report := mlhub.ImportReport{
+				Conflict: r.URL.Query().Get("conflict"),
+				Results:  []mlhub.ImportResult{{Model: "mnist", Type: "TensorFlow", Version: "v1", Status: "overwritten"}},
+			}
+			json.NewEncoder(w).Encode(report)
+		default:
+			writeError(w, http.StatusNotFound, "unknown API")
+		}
+	})
+	fname := filepath.Join(t.TempDir(), "backup.zip")
+	ctx := context.Background()
+	if err := c.Export(ctx, fname); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(fname); string(data) != archive {
+		t.Errorf("exported content mismatch: %q", data)
+	}
+	report, err := c.Import(ctx, fname, "overwrite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Conflict != "overwrite" || len(report.Results) != 1 || report.Results[0].Status != "overwritten" {
+		t.Errorf("unexpected import report %+v", report)
+	}
+}
+
+// TestPull tests pull of ML models from given or all remote MLHub instances
+func TestPull(t *testing.T) {
+	var remotes []string
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/federation" {
+			writeError(w, http.StatusNotFound, "unknown API")
+			return
+		}
+		remotes = append(remotes, r.URL.Query().Get("remote"))
+		json.NewEncoder(w).Encode([]mlhub.MirrorReport{{Remote: "chess", Synced: 123}})
+	})
+	ctx := context.Background()
+	reports, err := c.Pull(ctx, "chess")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Remote != "chess" || reports[0].Synced != 123 {
+		t.Errorf("unexpected mirror reports %+v", reports)
+	}
+	if _, err := c.Pull(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(remotes, ",") != "chess," {
+		t.Errorf("unexpected remotes %v", remotes)
+	}
+}
+
+// TestPredict tests JSON and multipart predictions along with provenance header
+func TestPredict(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/predict" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			file, hdr, err := r.FormFile("image")
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			defer file.Close()
+			data, _ := io.ReadAll(file)
+			if r.FormValue("model") != "mnist" {
+				t.Errorf("unexpected model %s", r.FormValue("model"))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"file": hdr.Filename, "size": len(data)})
+			return
+		}
+		var rec mlhub.Record
+		if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.Header().Set(ProvenanceHeader, "abc")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"model": rec.Model, "input": rec.Input})
+	})
+	spec := mlhub.Record{Model: "mnist", Type: "TensorFlow"}
+	pred, err := c.Predict(context.Background(), spec, PredictInput{Input: []float64{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pred.Provenance != "abc" || pred.ContentType != "application/json" {
+		t.Errorf("unexpected prediction %+v", pred)
+	}
+	if got := strings.TrimSpace(string(pred.Data)); got != `{"input":[1,2],"model":"mnist"}` {
+		t.Errorf("unexpected prediction output %s", got)
+	}
+
+	fname := filepath.Join(t.TempDir(), "digit.png")
+	os.WriteFile(fname, []byte("image"), 0644)
+	pred, err = c.Predict(context.Background(), spec, PredictInput{File: fname})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(pred.Data)); got != `{"file":"digit.png","size":5}` {
+		t.Errorf("unexpected prediction output %s", got)
+	}
+}
+
+// TestPredictBatch tests ordering of batch predictions and joined errors
+func TestPredictBatch(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var rec mlhub.Record
+		json.NewDecoder(r.Body).Decode(&rec)
+		if v, ok := rec.Input.(float64); !ok || v < 0 {
+			writeError(w, http.StatusBadRequest, "invalid input")
+			return
+		}
+		json.NewEncoder(w).Encode(rec.Input)
+	})
+	inputs := []PredictInput{{Input: 1}, {Input: -1}, {Input: 3}, {Input: 4}}
+	preds, err := c.PredictBatch(context.Background(), mlhub.Record{Model: "mnist"}, inputs, 2)
+	if err == nil || !strings.Contains(err.Error(), "input #1") {
+		t.Errorf("unexpected error %v", err)
+	}
+	expect := []string{"1", "", "3", "4"}
+	for idx, pred := range preds {
+		if got := strings.TrimSpace(string(pred.Data)); got != expect[idx] {
+			t.Errorf("prediction #%d is %q, expected %q", idx, got, expect[idx])
+		}
+	}
+}
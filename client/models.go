@@ -0,0 +1,398 @@
+package client
+
+// models module provides MLHub client APIs to manage ML models
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	mlhub "github.com/CHESSComputing/MLHub/mlhub"
+)
+
+// ModelQuery represents query of ML models
+type ModelQuery struct {
+	Query      string // free text query of ML model name and description
+	Type       string // ML model type
+	Backend    string // ML backend name
+	Discipline string // ML model discipline
+	User       string // ML model author
+	Origin     string // URL of remote MLHub of mirrored ML models
+	Source     string // source ML model of converted ML models, e.g. ONNX exports
+	Since      int64  // ML models uploaded since given unix timestamp
+	Idx        int    // index of first record
+	Limit      int    // number of records, zero means all
+}
+
+// helper function to build model query parameters
+func modelQuery(mlType, version string) string {
+	vals := url.Values{}
+	if mlType != "" {
+		vals.Set("type", mlType)
+	}
+	if version != "" {
+		vals.Set("version", version)
+	}
+	if len(vals) == 0 {
+		return ""
+	}
+	return "?" + vals.Encode()
+}
+
+// ModelURL returns URL of ML model page
+func (c *Client) ModelURL(model, mlType, version string) string {
+	return c.URL + "/model/" + url.PathEscape(model) + modelQuery(mlType, version)
+}
+
+// ListModels lists ML models matching given query
+func (c *Client) ListModels(ctx context.Context, q ModelQuery) ([]mlhub.Record, error) {
+	vals := url.Values{}
+	for key, val := range map[string]string{
+		"q": q.Query, "type": q.Type, "backend": q.Backend, "discipline": q.Discipline, "user": q.User, "origin": q.Origin,
+		"source": q.Source,
+	} {
+		if val != "" {
+			vals.Set(key, val)
+		}
+	}
+	if q.Since > 0 {
+		vals.Set("since", strconv.FormatInt(q.Since, 10))
+	}
+	vals.Set("idx", strconv.Itoa(q.Idx))
+	vals.Set("limit", strconv.Itoa(q.Limit))
+	var records []mlhub.Record
+	err := c.call(ctx, "GET", "/models?"+vals.Encode(), "", nil, &records)
+	return records, err
+}
+
+// GetModel returns ML model record, version can be either ML model version or its alias
+func (c *Client) GetModel(ctx context.Context, model, mlType, version string) (mlhub.Record, error) {
+	var rec mlhub.Record
+	path := "/model/" + url.PathEscape(model) + modelQuery(mlType, version)
+	err := c.call(ctx, "GET", path, "", nil, &rec)
+	return rec, err
+}
+
+// Delete moves ML model version to trash, it can be restored until it is
+// purged from trash
+func (c *Client) Delete(ctx context.Context, model, mlType, version string) error {
+	spec := mlhub.Record{Model: model, Type: mlType, Version: version}
+	return c.callJSON(ctx, "DELETE", "/delete", spec, nil)
+}
+
+// Trash lists ML models in trash, empty model and type match all ML models
+func (c *Client) Trash(ctx context.Context, model, mlType string) ([]mlhub.Record, error) {
+	vals := url.Values{}
+	if model != "" {
+		vals.Set("model", model)
+	}
+	if mlType != "" {
+		vals.Set("type", mlType)
+	}
+	var records []mlhub.Record
+	err := c.call(ctx, "GET", "/trash?"+vals.Encode(), "", nil, &records)
+	return records, err
+}
+
+// Restore restores ML model version from trash
+func (c *Client) Restore(ctx context.Context, model, mlType, version string) error {
+	spec := mlhub.Record{Model: model, Type: mlType, Version: version}
+	return c.callJSON(ctx, "POST", "/restore", spec, nil)
+}
+
+// Purge permanently deletes ML model version from trash
+func (c *Client) Purge(ctx context.Context, model, mlType, version string) error {
+	spec := mlhub.Record{Model: model, Type: mlType, Version: version}
+	return c.callJSON(ctx, "DELETE", "/purge", spec, nil)
+}
+
+// CheckConsistency checks consistency of MLHub storage, meta-data and ML
+// backends with given policy, report or repair, it requires MLHub
+// administrator privileges
+func (c *Client) CheckConsistency(ctx context.Context, policy string) (mlhub.ConsistencyReport, error) {
+	var report mlhub.ConsistencyReport
+	path := "/consistency?policy=" + url.QueryEscape(policy)
+	err := c.call(ctx, "POST", path, "", nil, &report)
+	return report, err
+}
+
+// Mirrors provides mirroring state of remote MLHub instances, it requires
+// MLHub administrator privileges
+func (c *Client) Mirrors(ctx context.Context) ([]mlhub.Mirror, error) {
+	var states []mlhub.Mirror
+	err := c.call(ctx, "GET", "/federation", "", nil, &states)
+	return states, err
+}
+
+// Pull pulls ML models changed since the last pull from given remote MLHub,
+// or from all remote MLHub instances if remote is empty, it requires MLHub
+// administrator privileges
+func (c *Client) Pull(ctx context.Context, remote string) ([]mlhub.MirrorReport, error) {
+	var reports []mlhub.MirrorReport
+	path := "/federation"
+	if remote != "" {
+		path += "?remote=" + url.QueryEscape(remote)
+	}
+	err := c.call(ctx, "POST", path, "", nil, &reports)
+	return reports, err
+}
+
+// ImportHF imports ML model from Hugging Face-style repository and returns
+// its record, ML model type and backend are inferred unless they are provided
+func (c *Client) ImportHF(ctx context.Context, imp mlhub.HFImport) (mlhub.Record, error) {
+	var rec mlhub.Record
+	err := c.callJSON(ctx, "POST", "/import/huggingface", imp, &rec)
+	return rec, err
+}
+
+// Promote assigns alias, e.g. production, to ML model version
+func (c *Client) Promote(ctx context.Context, alias mlhub.Alias) error {
+	return c.callJSON(ctx, "POST", "/promote", alias, nil)
+}
+
+// UploadID returns identifier of resumable upload for given bundle file and
+// ML model record, the same identifier allows to resume interrupted upload
+func UploadID(fname string, rec mlhub.Record) (string, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "%s/%s/%s", rec.Model, rec.Type, rec.Version)
+	return hex.EncodeToString(h.Sum(nil))[:32], nil
+}
+
+// Upload uploads ML bundle file along with ML model record. The bundle is
+// uploaded in chunks and interrupted upload is resumed by repeating the call
+func (c *Client) Upload(ctx context.Context, rec mlhub.Record, fname string) error {
+	uploadID, err := UploadID(fname, rec)
+	if err != nil {
+		return err
+	}
+	if err := c.uploadChunks(ctx, uploadID, fname); err != nil {
+		return err
+	}
+	prov := rec.Provenance
+	fields := map[string]string{
+		"upload":        uploadID,
+		"model":         rec.Model,
+		"type":          rec.Type,
+		"backend":       rec.Backend,
+		"version":       rec.Version,
+		"discipline":    rec.Discipline,
+		"description":   rec.Description,
+		"reference":     rec.Reference,
+		"datasets":      strings.Join(prov.Datasets, ","),
+		"evaluation":    strings.Join(prov.Evaluation, ","),
+		"commit":        prov.Commit,
+		"parentmodel":   prov.ParentModel,
+		"parentversion": prov.ParentVersion,
+	}
+	if conv := rec.Conversion; conv != nil {
+		fields["sourcemodel"] = conv.SourceModel
+		fields["sourcetype"] = conv.SourceType
+		fields["sourceversion"] = conv.SourceVersion
+		fields["converter"] = conv.Converter
+	}
+	if len(prov.Parameters) > 0 {
+		params, err := json.Marshal(prov.Parameters)
+		if err != nil {
+			return err
+		}
+		fields["parameters"] = string(params)
+	}
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for key, val := range fields {
+		if val != "" {
+			writer.WriteField(key, val)
+		}
+	}
+	writer.Close()
+	return c.call(ctx, "POST", "/upload", writer.FormDataContentType(), body.Bytes(), nil)
+}
+
+// helper function to upload file in chunks starting from already uploaded offset
+func (c *Client) uploadChunks(ctx context.Context, uploadID, fname string) error {
+	var status mlhub.UploadStatus
+	if err := c.call(ctx, "GET", "/uploads/"+uploadID, "", nil, &status); err != nil {
+		return err
+	}
+	file, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(status.Size, io.SeekStart); err != nil {
+		return err
+	}
+	name := filepath.Base(fname)
+	size := c.ChunkSize
+	if size <= 0 {
+		size = 8 << 20
+	}
+	chunk := make([]byte, size)
+	for offset := status.Size; offset < info.Size(); offset = status.Size {
+		n, err := io.ReadFull(file, chunk)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		vals := url.Values{}
+		vals.Set("file", name)
+		vals.Set("offset", strconv.FormatInt(offset, 10))
+		path := fmt.Sprintf("/uploads/%s?%s", uploadID, vals.Encode())
+		if err := c.call(ctx, "PUT", path, "application/octet-stream", chunk[:n], &status); err != nil {
+			return err
+		}
+		if status.Size != offset+int64(n) {
+			return fmt.Errorf("upload of %s is inconsistent, expected %d bytes got %d", fname, offset+int64(n), status.Size)
+		}
+		c.progress(name, status.Size, info.Size())
+	}
+	return nil
+}
+
+// Download downloads ML bundle into given file, partially downloaded file is resumed
+func (c *Client) Download(ctx context.Context, model, mlType, version, fname string) error {
+	var offset int64
+	if info, err := os.Stat(fname); err == nil {
+		offset = info.Size()
+	}
+	path := "/models/" + url.PathEscape(model) + modelQuery(mlType, version)
+	req, err := c.newRequest(ctx, "GET", path, "", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Del("Accept")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	rsp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	flags := os.O_CREATE | os.O_WRONLY
+	switch rsp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusRequestedRangeNotSatisfiable:
+		// file is already downloaded
+		return nil
+	case http.StatusOK:
+		offset = 0
+		flags |= os.O_TRUNC
+	default:
+		data, _ := io.ReadAll(rsp.Body)
+		return decodeError(rsp, data)
+	}
+	file, err := os.OpenFile(fname, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := &progressReader{
+		reader: rsp.Body,
+		client: c,
+		name:   filepath.Base(fname),
+		done:   offset,
+		total:  offset + rsp.ContentLength,
+	}
+	_, err = io.Copy(file, reader)
+	return err
+}
+
+// Export writes MLHub backup archive of all ML models and their aliases into
+// given file, it requires MLHub administrator privileges
+func (c *Client) Export(ctx context.Context, fname string) error {
+	req, err := c.newRequest(ctx, "GET", "/export", "", nil)
+	if err != nil {
+		return err
+	}
+	rsp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(rsp.Body)
+		return decodeError(rsp, data)
+	}
+	file, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	reader := &progressReader{
+		reader: rsp.Body,
+		client: c,
+		name:   filepath.Base(fname),
+		total:  rsp.ContentLength,
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Import imports ML models and aliases from MLHub backup archive with given
+// conflict policy, skip or overwrite, it requires MLHub administrator privileges
+func (c *Client) Import(ctx context.Context, fname, conflict string) (mlhub.ImportReport, error) {
+	var report mlhub.ImportReport
+	file, err := os.Open(fname)
+	if err != nil {
+		return report, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return report, err
+	}
+	reader := &progressReader{
+		reader: file,
+		client: c,
+		name:   filepath.Base(fname),
+		total:  info.Size(),
+	}
+	path := "/import?conflict=" + url.QueryEscape(conflict)
+	req, err := c.newRequest(ctx, "POST", path, "application/zip", reader)
+	if err != nil {
+		return report, err
+	}
+	req.ContentLength = info.Size()
+	rsp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return report, err
+	}
+	defer rsp.Body.Close()
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return report, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return report, decodeError(rsp, data)
+	}
+	err = json.Unmarshal(data, &report)
+	return report, err
+}
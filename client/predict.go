@@ -0,0 +1,143 @@
+package client
+
+// predict module provides MLHub client APIs to get ML predictions
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	mlhub "github.com/CHESSComputing/MLHub/mlhub"
+)
+
+// ProvenanceHeader defines HTTP header of MLHub prediction provenance
+const ProvenanceHeader = "X-MLHub-Provenance"
+
+// VersionHeader defines HTTP header of ML model version which served prediction
+const VersionHeader = "X-MLHub-Version"
+
+// PredictInput represents input of ML prediction, it is either JSON input
+// or input file, e.g. image
+type PredictInput struct {
+	Input any    // JSON input, e.g. []float64{1, 2, 3}
+	File  string // input file name
+	Field string // form field name of input file, default image
+}
+
+// Prediction represents output of ML prediction
+type Prediction struct {
+	Data        []byte // prediction output
+	ContentType string // content type of prediction output
+	Provenance  string // provenance ID of prediction (if it was recorded)
+	Version     string // ML model version which served prediction
+}
+
+// Predict provides ML prediction for given ML model specification (model,
+// type, version and backend) and input
+func (c *Client) Predict(ctx context.Context, spec mlhub.Record, input PredictInput) (Prediction, error) {
+	var pred Prediction
+	var body []byte
+	var ctype string
+	var err error
+	if input.File != "" {
+		body, ctype, err = predictForm(spec, input)
+	} else {
+		spec.Input = input.Input
+		body, err = json.Marshal(spec)
+		ctype = "application/json"
+	}
+	if err != nil {
+		return pred, err
+	}
+	rsp, err := c.do(ctx, "POST", "/predict", ctype, body)
+	if err != nil {
+		return pred, err
+	}
+	defer rsp.Body.Close()
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return pred, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return pred, decodeError(rsp, data)
+	}
+	pred.Data = data
+	pred.ContentType = rsp.Header.Get("Content-Type")
+	pred.Provenance = rsp.Header.Get(ProvenanceHeader)
+	pred.Version = rsp.Header.Get(VersionHeader)
+	return pred, nil
+}
+
+// PredictBatch provides ML predictions for given inputs using given number of
+// concurrent requests. The predictions are returned in order of inputs along
+// with joined errors of failed predictions
+func (c *Client) PredictBatch(ctx context.Context, spec mlhub.Record, inputs []PredictInput, workers int) ([]Prediction, error) {
+	if workers <= 0 {
+		workers = 1
+	}
+	preds := make([]Prediction, len(inputs))
+	errs := make([]error, len(inputs))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for idx, input := range inputs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx int, input PredictInput) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			pred, err := c.Predict(ctx, spec, input)
+			if err != nil {
+				name := input.File
+				if name == "" {
+					name = fmt.Sprintf("input #%d", idx)
+				}
+				err = fmt.Errorf("%s: %w", name, err)
+			}
+			preds[idx] = pred
+			errs[idx] = err
+		}(idx, input)
+	}
+	wg.Wait()
+	return preds, errors.Join(errs...)
+}
+
+// helper function to build multipart form of prediction request for given input file
+func predictForm(spec mlhub.Record, input PredictInput) ([]byte, string, error) {
+	file, err := os.Open(input.File)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for key, val := range map[string]string{
+		"model": spec.Model, "type": spec.Type, "version": spec.Version, "backend": spec.Backend,
+	} {
+		if val != "" {
+			writer.WriteField(key, val)
+		}
+	}
+	field := input.Field
+	if field == "" {
+		field = "image"
+	}
+	fw, err := writer.CreateFormFile(field, filepath.Base(input.File))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := io.Copy(fw, file); err != nil {
+		return nil, "", err
+	}
+	writer.Close()
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
@@ -0,0 +1,669 @@
+package main
+
+// commands module provides implementation of mlhub commands
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/CHESSComputing/MLHub/client"
+	mlhub "github.com/CHESSComputing/MLHub/mlhub"
+)
+
+// helper function to print JSON representation of given object
+func printJSON(obj any) error {
+	data, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// helper function to print ML records as a table
+func printRecords(records []mlhub.Record) {
+	fmt.Printf("%-30s %-12s %-12s %-12s %-12s %s\n", "MODEL", "VERSION", "TYPE", "BACKEND", "DISCIPLINE", "ORIGIN")
+	for _, rec := range records {
+		origin := rec.Origin
+		if origin == "" {
+			origin = "local"
+		}
+		fmt.Printf("%-30s %-12s %-12s %-12s %-12s %s\n", rec.Model, rec.Version, rec.Type, rec.Backend, rec.Discipline, origin)
+	}
+}
+
+// helper function to get single model argument of the command
+func modelArg(fs interface{ Args() []string }) (string, error) {
+	if len(fs.Args()) != 1 {
+		return "", errors.New("please provide ML model name")
+	}
+	return fs.Args()[0], nil
+}
+
+// loginCommand asks user for access token and stores it in token file
+func loginCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("login", &opts)
+	fs.Parse(args)
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	fmt.Printf("Please paste FOXDEN access token of %s here: ", opts.URL)
+	reader := bufio.NewReader(os.Stdin)
+	token, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return storeToken(strings.TrimSpace(token))
+}
+
+// helper function to store access token in token file
+func storeToken(token string) error {
+	if token == "" {
+		return errors.New("empty access token")
+	}
+	if err := os.WriteFile(tokenFile(), []byte(token), 0600); err != nil {
+		return err
+	}
+	fmt.Println("access token is stored in", tokenFile())
+	return nil
+}
+
+// tokenCommand shows current access token or stores given one
+func tokenCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("token", &opts)
+	fs.Parse(args)
+	if len(fs.Args()) == 1 {
+		return storeToken(fs.Args()[0])
+	}
+	if opts.Token == "" {
+		return errors.New("no access token found, please use mlhub login")
+	}
+	fmt.Println(opts.Token)
+	return nil
+}
+
+// helper function to list ML models matching given query and filters
+func queryModels(name, query string, args []string) error {
+	var opts Options
+	fs := commandFlags(name, &opts)
+	mlType := fs.String("type", "", "ML model type")
+	backend := fs.String("backend", "", "ML backend name")
+	discipline := fs.String("discipline", "", "ML model discipline")
+	user := fs.String("user", "", "ML model author")
+	origin := fs.String("origin", "", "URL of remote MLHub of mirrored ML models")
+	source := fs.String("source", "", "source ML model of converted ML models, e.g. ONNX exports")
+	idx := fs.Int("idx", 0, "index of first record")
+	limit := fs.Int("limit", 0, "number of records, default all")
+	asJSON := fs.Bool("json", false, "print records in JSON format")
+	fs.Parse(args)
+	if name == "search" {
+		if len(fs.Args()) == 0 {
+			return errors.New("please provide search query")
+		}
+		query = strings.Join(fs.Args(), " ")
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	q := client.ModelQuery{
+		Query: query, Type: *mlType, Backend: *backend, Discipline: *discipline,
+		User: *user, Origin: *origin, Source: *source, Idx: *idx, Limit: *limit,
+	}
+	records, err := opts.client().ListModels(context.Background(), q)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(records)
+	}
+	printRecords(records)
+	return nil
+}
+
+// listCommand lists ML models
+func listCommand(args []string) error {
+	return queryModels("list", "", args)
+}
+
+// searchCommand searches ML models
+func searchCommand(args []string) error {
+	return queryModels("search", "", args)
+}
+
+// infoCommand shows ML model meta-data
+func infoCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("info", &opts)
+	mlType := fs.String("type", "", "ML model type")
+	version := fs.String("version", "", "ML model version or alias")
+	fs.Parse(args)
+	model, err := modelArg(fs)
+	if err != nil {
+		return err
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	rec, err := opts.client().GetModel(context.Background(), model, *mlType, *version)
+	if err != nil {
+		return err
+	}
+	return printJSON(rec)
+}
+
+// uploadCommand uploads ML model bundle, the upload is performed in chunks
+// and interrupted upload is resumed when command is repeated
+func uploadCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("upload", &opts)
+	model := fs.String("model", "", "ML model name")
+	mlType := fs.String("type", "", "ML model type, e.g. TensorFlow, inferred for MLflow and ONNX models")
+	backend := fs.String("backend", "", "ML backend name, inferred for MLflow and ONNX models")
+	version := fs.String("version", "latest", "ML model version")
+	discipline := fs.String("discipline", "", "ML model discipline")
+	description := fs.String("description", "", "ML model description")
+	reference := fs.String("reference", "", "ML model reference URL")
+	datasets := fs.String("datasets", "", "comma separated DIDs of training datasets")
+	evaluation := fs.String("evaluation", "", "comma separated DIDs of evaluation datasets")
+	commit := fs.String("commit", "", "code commit used for training")
+	parentModel := fs.String("parentmodel", "", "parent ML model name")
+	parentVersion := fs.String("parentversion", "", "parent ML model version")
+	parameters := fs.String("parameters", "", "training parameters in JSON format")
+	sourceModel := fs.String("sourcemodel", "", "source ML model of ONNX export")
+	sourceType := fs.String("sourcetype", "", "source ML model type, e.g. PyTorch")
+	sourceVersion := fs.String("sourceversion", "", "source ML model version")
+	converter := fs.String("converter", "", "converter of ONNX export, e.g. tf2onnx 1.16.1")
+	chunk := fs.Int64("chunk", 8<<20, "size of upload chunk in bytes")
+	fs.Parse(args)
+	if len(fs.Args()) != 1 {
+		return errors.New("please provide ML bundle file or MLflow model directory")
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	fname := fs.Args()[0]
+	if info, err := os.Stat(fname); err == nil && info.IsDir() {
+		// MLflow model directory is uploaded as tar.gz ML bundle
+		bundle, err := packDir(fname)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(bundle)
+		fname = bundle
+	}
+	rec := mlhub.Record{
+		Model:       *model,
+		Type:        *mlType,
+		Backend:     *backend,
+		Version:     *version,
+		Discipline:  *discipline,
+		Description: *description,
+		Reference:   *reference,
+		Provenance: mlhub.Provenance{
+			Datasets:      splitList(*datasets),
+			Evaluation:    splitList(*evaluation),
+			Commit:        *commit,
+			ParentModel:   *parentModel,
+			ParentVersion: *parentVersion,
+		},
+	}
+	if *parameters != "" {
+		if err := json.Unmarshal([]byte(*parameters), &rec.Provenance.Parameters); err != nil {
+			return fmt.Errorf("invalid training parameters: %w", err)
+		}
+	}
+	if *sourceModel != "" {
+		rec.Conversion = &mlhub.Conversion{
+			SourceModel:   *sourceModel,
+			SourceType:    *sourceType,
+			SourceVersion: *sourceVersion,
+			Converter:     *converter,
+		}
+	}
+	c := opts.client()
+	c.ChunkSize = *chunk
+	if err := c.Upload(context.Background(), rec, fname); err != nil {
+		return err
+	}
+	fmt.Printf("ML model %s version %s is uploaded\n", *model, *version)
+	return nil
+}
+
+// helper function to pack directory into tar.gz archive in temporary area,
+// the archive keeps directory as its top-level directory
+func packDir(dir string) (string, error) {
+	dir = filepath.Clean(dir)
+	fname := filepath.Join(os.TempDir(), filepath.Base(dir)+".tar.gz")
+	file, err := os.Create(fname)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	gw := gzip.NewWriter(file)
+	tw := tar.NewWriter(gw)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(filepath.Dir(dir), path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gw.Close()
+	}
+	if err != nil {
+		os.Remove(fname)
+		return "", err
+	}
+	return fname, nil
+}
+
+// helper function to split comma separated list
+func splitList(val string) []string {
+	var out []string
+	for _, v := range strings.Split(val, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// downloadCommand downloads ML model bundle, partially downloaded file is resumed
+func downloadCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("download", &opts)
+	mlType := fs.String("type", "", "ML model type")
+	version := fs.String("version", "", "ML model version or alias")
+	output := fs.String("o", "", "output file name, default ML bundle name")
+	fs.Parse(args)
+	model, err := modelArg(fs)
+	if err != nil {
+		return err
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	c := opts.client()
+	ctx := context.Background()
+	fname := *output
+	if fname == "" {
+		rec, err := c.GetModel(ctx, model, *mlType, *version)
+		if err != nil {
+			return err
+		}
+		// ML bundle name comes from server, use only its base name to
+		// write into current directory
+		fname = filepath.Base(rec.Bundle)
+		if fname == "." || fname == ".." || fname == string(filepath.Separator) {
+			return fmt.Errorf("invalid ML bundle name %q, please provide -o option", rec.Bundle)
+		}
+	}
+	if err := c.Download(ctx, model, *mlType, *version, fname); err != nil {
+		return err
+	}
+	fmt.Printf("ML model %s is downloaded to %s\n", model, fname)
+	return nil
+}
+
+// predictCommand provides predictions for JSON input, file or directory of files
+func predictCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("predict", &opts)
+	mlType := fs.String("type", "", "ML model type")
+	version := fs.String("version", "", "ML model version or alias")
+	backend := fs.String("backend", "", "ML backend name")
+	input := fs.String("input", "", "JSON input, e.g. [1,2,3], or @file.json")
+	fname := fs.String("file", "", "input file, e.g. image.png")
+	dir := fs.String("dir", "", "directory of input files")
+	field := fs.String("field", "image", "form field name of input files")
+	workers := fs.Int("workers", 4, "number of concurrent predictions of input files")
+	fs.Parse(args)
+	model, err := modelArg(fs)
+	if err != nil {
+		return err
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	c := opts.client()
+	ctx := context.Background()
+	spec := mlhub.Record{Model: model, Type: *mlType, Version: *version, Backend: *backend}
+	if *input != "" {
+		data := []byte(*input)
+		if strings.HasPrefix(*input, "@") {
+			if data, err = os.ReadFile(strings.TrimPrefix(*input, "@")); err != nil {
+				return err
+			}
+		}
+		var in client.PredictInput
+		if err := json.Unmarshal(data, &in.Input); err != nil {
+			return fmt.Errorf("invalid JSON input: %w", err)
+		}
+		pred, err := c.Predict(ctx, spec, in)
+		if err != nil {
+			return err
+		}
+		fmt.Println(strings.TrimSpace(string(pred.Data)))
+		return nil
+	}
+	var inputs []client.PredictInput
+	if *fname != "" {
+		inputs = append(inputs, client.PredictInput{File: *fname, Field: *field})
+	}
+	if *dir != "" {
+		entries, err := os.ReadDir(*dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				inputs = append(inputs, client.PredictInput{File: filepath.Join(*dir, entry.Name()), Field: *field})
+			}
+		}
+	}
+	if len(inputs) == 0 {
+		return errors.New("please provide -input, -file or -dir option")
+	}
+	preds, err := c.PredictBatch(ctx, spec, inputs, *workers)
+	for idx, pred := range preds {
+		if pred.Data == nil {
+			continue
+		}
+		if len(inputs) > 1 {
+			fmt.Printf("%s: ", inputs[idx].File)
+		}
+		fmt.Println(strings.TrimSpace(string(pred.Data)))
+	}
+	return err
+}
+
+// deleteCommand moves ML model to trash or deletes it permanently
+func deleteCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("delete", &opts)
+	mlType := fs.String("type", "", "ML model type")
+	version := fs.String("version", "", "ML model version")
+	purge := fs.Bool("purge", false, "delete ML model permanently instead of moving it to trash")
+	fs.Parse(args)
+	model, err := modelArg(fs)
+	if err != nil {
+		return err
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	c := opts.client()
+	ctx := context.Background()
+	if err := c.Delete(ctx, model, *mlType, *version); err != nil {
+		return err
+	}
+	if !*purge {
+		fmt.Printf("ML model %s version %s is moved to trash\n", model, *version)
+		return nil
+	}
+	if err := c.Purge(ctx, model, *mlType, *version); err != nil {
+		return err
+	}
+	fmt.Printf("ML model %s version %s is deleted\n", model, *version)
+	return nil
+}
+
+// trashCommand lists ML models in trash
+func trashCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("trash", &opts)
+	model := fs.String("model", "", "ML model name")
+	mlType := fs.String("type", "", "ML model type")
+	fs.Parse(args)
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	records, err := opts.client().Trash(context.Background(), *model, *mlType)
+	if err != nil {
+		return err
+	}
+	return printJSON(records)
+}
+
+// restoreCommand restores ML model from trash
+func restoreCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("restore", &opts)
+	mlType := fs.String("type", "", "ML model type")
+	version := fs.String("version", "", "ML model version")
+	fs.Parse(args)
+	model, err := modelArg(fs)
+	if err != nil {
+		return err
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if err := opts.client().Restore(context.Background(), model, *mlType, *version); err != nil {
+		return err
+	}
+	fmt.Printf("ML model %s version %s is restored\n", model, *version)
+	return nil
+}
+
+// checkCommand checks consistency of MLHub storage, meta-data and ML backends
+func checkCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("check", &opts)
+	repair := fs.Bool("repair", false, "repair found issues instead of only reporting them")
+	fs.Parse(args)
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	policy := "report"
+	if *repair {
+		policy = "repair"
+	}
+	report, err := opts.client().CheckConsistency(context.Background(), policy)
+	if err != nil {
+		return err
+	}
+	return printJSON(report)
+}
+
+// exportCommand exports ML models and their aliases into backup archive
+func exportCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("export", &opts)
+	output := fs.String("o", "", "output file name, default mlhub-<date>.zip")
+	fs.Parse(args)
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	fname := *output
+	if fname == "" {
+		fname = fmt.Sprintf("mlhub-%s.zip", time.Now().Format("20060102-150405"))
+	}
+	if err := opts.client().Export(context.Background(), fname); err != nil {
+		return err
+	}
+	fmt.Printf("MLHub backup is exported to %s\n", fname)
+	return nil
+}
+
+// importCommand imports ML models and their aliases from backup archive
+func importCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("import", &opts)
+	overwrite := fs.Bool("overwrite", false, "overwrite existing ML model versions and aliases instead of skipping them")
+	fs.Parse(args)
+	if len(fs.Args()) != 1 {
+		return errors.New("please provide backup archive file name")
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	conflict := "skip"
+	if *overwrite {
+		conflict = "overwrite"
+	}
+	report, err := opts.client().Import(context.Background(), fs.Args()[0], conflict)
+	if err != nil {
+		return err
+	}
+	return printJSON(report)
+}
+
+// mirrorCommand pulls ML models from remote MLHub instances or shows their mirroring state
+func mirrorCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("mirror", &opts)
+	remote := fs.String("remote", "", "name of remote MLHub, default all remote MLHub instances")
+	status := fs.Bool("status", false, "show mirroring state of remote MLHub instances instead of pulling ML models")
+	fs.Parse(args)
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	c := opts.client()
+	if *status {
+		states, err := c.Mirrors(context.Background())
+		if err != nil {
+			return err
+		}
+		return printJSON(states)
+	}
+	reports, err := c.Pull(context.Background(), *remote)
+	if err != nil {
+		return err
+	}
+	return printJSON(reports)
+}
+
+// hfImportCommand imports ML model from Hugging Face-style repository
+func hfImportCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("hf-import", &opts)
+	endpoint := fs.String("endpoint", "", "Hugging Face Hub compatible endpoint, default https://huggingface.co")
+	revision := fs.String("revision", "", "repository revision, default main")
+	dir := fs.String("dir", "", "local directory with repository layout on MLHub server (admin only)")
+	model := fs.String("model", "", "ML model name, default repository name")
+	version := fs.String("version", "", "ML model version, default latest")
+	mlType := fs.String("type", "", "ML model type, inferred from repository by default")
+	backend := fs.String("backend", "", "ML backend name, inferred from ML model type by default")
+	discipline := fs.String("discipline", "", "ML model discipline")
+	fs.Parse(args)
+	var repo string
+	if len(fs.Args()) == 1 {
+		repo = fs.Args()[0]
+	} else if *dir == "" {
+		return errors.New("please provide repository name, e.g. org/name, or local directory")
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	imp := mlhub.HFImport{
+		Repo:       repo,
+		Endpoint:   *endpoint,
+		Revision:   *revision,
+		Token:      os.Getenv("HF_TOKEN"),
+		Dir:        *dir,
+		Model:      *model,
+		Version:    *version,
+		Type:       *mlType,
+		Backend:    *backend,
+		Discipline: *discipline,
+	}
+	rec, err := opts.client().ImportHF(context.Background(), imp)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ML model %s type %s version %s is imported to %s backend\n", rec.Model, rec.Type, rec.Version, rec.Backend)
+	return nil
+}
+
+// promoteCommand assigns alias to ML model version
+func promoteCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("promote", &opts)
+	mlType := fs.String("type", "", "ML model type")
+	version := fs.String("version", "", "ML model version")
+	alias := fs.String("alias", "production", "alias of ML model version")
+	fs.Parse(args)
+	model, err := modelArg(fs)
+	if err != nil {
+		return err
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	spec := mlhub.Alias{Model: model, Type: *mlType, Version: *version, Alias: *alias}
+	if err := opts.client().Promote(context.Background(), spec); err != nil {
+		return err
+	}
+	fmt.Printf("ML model %s version %s is promoted to %s\n", model, *version, *alias)
+	return nil
+}
+
+// citeCommand provides citation of ML model
+func citeCommand(args []string) error {
+	var opts Options
+	fs := commandFlags("cite", &opts)
+	mlType := fs.String("type", "", "ML model type")
+	version := fs.String("version", "", "ML model version or alias")
+	format := fs.String("format", "bibtex", "citation format: bibtex or text")
+	fs.Parse(args)
+	model, err := modelArg(fs)
+	if err != nil {
+		return err
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	c := opts.client()
+	rec, err := c.GetModel(context.Background(), model, *mlType, *version)
+	if err != nil {
+		return err
+	}
+	cite, err := mlhub.Citation(rec, c.ModelURL(rec.Model, rec.Type, rec.Version), *format)
+	if err != nil {
+		return err
+	}
+	fmt.Print(cite)
+	return nil
+}
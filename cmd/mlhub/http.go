@@ -0,0 +1,69 @@
+package main
+
+// http module provides HTTP helper functions of mlhub client
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/CHESSComputing/MLHub/client"
+)
+
+// helper function to validate common options
+func (o *Options) validate() error {
+	if o.URL == "" {
+		return errors.New("MLHub server URL is not set, please use -url option or $MLHUB_URL")
+	}
+	o.URL = strings.TrimSuffix(o.URL, "/")
+	return nil
+}
+
+// helper function to create MLHub client for common options
+func (o *Options) client() *client.Client {
+	c := client.New(o.URL, o.Token)
+	c.Progress = (&progress{}).report
+	if o.Verbose > 0 {
+		c.HTTPClient.Transport = &verboseTransport{http.DefaultTransport}
+	}
+	return c
+}
+
+// verboseTransport represents HTTP transport which logs requests
+type verboseTransport struct {
+	http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper interface
+func (t *verboseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	log.Printf("%s %s", req.Method, req.URL)
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// progress represents progress report of data transfer on stderr
+type progress struct {
+	last int64
+	done bool
+}
+
+// helper function to report progress of data transfer
+func (p *progress) report(name string, done, total int64) {
+	if total <= 0 || p.done {
+		return
+	}
+	pct := 100 * done / total
+	if pct != p.last || done >= total {
+		p.last = pct
+		fmt.Fprintf(os.Stderr, "\r%s: %3d%% (%d/%d bytes)", name, pct, done, total)
+		if done >= total {
+			p.done = true
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+// mlhub command line client of MLHub service
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// Options represents common options of mlhub commands
+type Options struct {
+	URL     string // MLHub server URL
+	Token   string // access token
+	Verbose int    // verbosity level
+}
+
+// helper function to get location of token file
+func tokenFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".mlhub.token"
+	}
+	return filepath.Join(home, ".mlhub.token")
+}
+
+// helper function to get MLHub server URL, it is taken from $MLHUB_URL
+// environment or from FOXDEN configuration
+func serverURL() string {
+	if rurl := os.Getenv("MLHUB_URL"); rurl != "" {
+		return rurl
+	}
+	if fname := os.Getenv("FOXDEN_CONFIG"); fname != "" {
+		if cobj, err := srvConfig.ParseConfig(fname); err == nil {
+			return cobj.Services.MLHubURL
+		}
+	}
+	return ""
+}
+
+// helper function to get access token, it is taken from $MLHUB_TOKEN
+// environment or from token file created by login command
+func accessToken() string {
+	if token := os.Getenv("MLHUB_TOKEN"); token != "" {
+		return token
+	}
+	data, err := os.ReadFile(tokenFile())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// helper function to create flag set of given command with common options
+func commandFlags(name string, opts *Options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&opts.URL, "url", serverURL(), "MLHub server URL, default $MLHUB_URL or FOXDEN config")
+	fs.StringVar(&opts.Token, "token", accessToken(), "access token, default $MLHUB_TOKEN or ~/.mlhub.token")
+	fs.IntVar(&opts.Verbose, "verbose", 0, "verbosity level")
+	return fs
+}
+
+// command represents mlhub command
+type command struct {
+	Name        string
+	Description string
+	Run         func(args []string) error
+}
+
+// commands defines list of supported mlhub commands
+var commands = []command{
+	{"login", "obtain and store access token", loginCommand},
+	{"token", "show or store access token", tokenCommand},
+	{"list", "list ML models", listCommand},
+	{"search", "search ML models", searchCommand},
+	{"info", "show ML model meta-data", infoCommand},
+	{"upload", "upload ML model bundle or MLflow model directory (with progress and resume)", uploadCommand},
+	{"download", "download ML model bundle (with progress and resume)", downloadCommand},
+	{"predict", "get predictions for JSON input, file or directory of files", predictCommand},
+	{"delete", "move ML model to trash or delete it permanently", deleteCommand},
+	{"trash", "list ML models in trash", trashCommand},
+	{"restore", "restore ML model from trash", restoreCommand},
+	{"check", "check consistency of storage, meta-data and ML backends (admin only)", checkCommand},
+	{"export", "export ML models and aliases into backup archive (admin only)", exportCommand},
+	{"import", "import ML models and aliases from backup archive (admin only)", importCommand},
+	{"mirror", "pull ML models from remote MLHub instances (admin only)", mirrorCommand},
+	{"hf-import", "import ML model from Hugging Face-style repository", hfImportCommand},
+	{"promote", "assign alias, e.g. production, to ML model version", promoteCommand},
+	{"cite", "provide citation of ML model", citeCommand},
+}
+
+func usage() {
+	fmt.Println("Usage: mlhub <command> [options] [arguments]")
+	fmt.Println("Commands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-10s %s\n", cmd.Name, cmd.Description)
+	}
+	fmt.Println("Use mlhub <command> -help to get help about specific command")
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-help" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		usage()
+		return
+	}
+	name := os.Args[1]
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			if err := cmd.Run(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+	fmt.Printf("unknown command '%s'\n", name)
+	usage()
+	os.Exit(1)
+}
@@ -0,0 +1,361 @@
+package main
+
+// config module holds MLHub specific configuration which complements
+// MLHub section of FOXDEN configuration
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Configuration represents MLHub specific configuration
+type Configuration struct {
+	Admins      []string          `json:"admins"`      // user names of MLHub administrators
+	Predictions PredictionsConfig `json:"predictions"` // predictions provenance settings
+	Domains     DomainsConfig     `json:"domains"`     // scientific domains settings
+	Aliases     AliasesConfig     `json:"aliases"`     // ML model aliases settings
+	Uploads     UploadsConfig     `json:"uploads"`     // resumable uploads settings
+	Routing     RoutingConfig     `json:"routing"`     // canary and A/B routing settings
+	Shadow      ShadowConfig      `json:"shadow"`      // shadow deployments settings
+	Audit       AuditConfig       `json:"audit"`       // audit log settings
+	Webhooks    WebhooksConfig    `json:"webhooks"`    // webhooks settings
+	Trash       TrashConfig       `json:"trash"`       // trash of deleted ML models settings
+	Consistency ConsistencyConfig `json:"consistency"` // consistency checks settings
+	Federation  FederationConfig  `json:"federation"`  // mirroring of ML models from remote MLHub instances
+	HuggingFace HuggingFaceConfig `json:"huggingface"` // import of ML models from Hugging Face-style repositories
+	Tracing     TracingConfig     `json:"tracing"`     // OpenTelemetry tracing settings
+	GRPC        GRPCConfig        `json:"grpc"`        // gRPC service settings
+	Health      HealthConfig      `json:"health"`      // ML backends health probes settings
+	Transport   TransportConfig   `json:"transport"`   // default HTTP transport settings of ML backends
+
+	// HTTP transport settings of ML backends keyed by ML backend name,
+	// non-zero values override default transport settings
+	Transports map[string]TransportConfig `json:"transports"`
+
+	// replicas of ML backends keyed by ML backend name
+	Replicas map[string]ReplicasConfig `json:"replicas"`
+}
+
+// PredictionsConfig represents configuration of predictions provenance
+type PredictionsConfig struct {
+	Record     bool   `json:"record"`     // record provenance of every prediction
+	Collection string `json:"collection"` // MongoDB collection of provenance records
+}
+
+// DomainsConfig represents configuration of scientific domains taxonomy
+type DomainsConfig struct {
+	Collection string `json:"collection"` // MongoDB collection of domains
+}
+
+// AliasesConfig represents configuration of ML model aliases
+type AliasesConfig struct {
+	Collection string `json:"collection"` // MongoDB collection of aliases
+}
+
+// RoutingConfig represents configuration of canary and A/B routing rules
+type RoutingConfig struct {
+	Collection string `json:"collection"` // MongoDB collection of routing rules
+	Outcomes   string `json:"outcomes"`   // MongoDB collection of routed predictions outcomes
+}
+
+// ShadowConfig represents configuration of shadow deployments
+type ShadowConfig struct {
+	Collection string  `json:"collection"` // MongoDB collection of shadow rules
+	Results    string  `json:"results"`    // MongoDB collection of shadow predictions results
+	Workers    int     `json:"workers"`    // max number of concurrent shadow predictions
+	Tolerance  float64 `json:"tolerance"`  // relative tolerance of numeric outputs comparison
+}
+
+// AuditConfig represents configuration of audit log of ML model lifecycle events
+type AuditConfig struct {
+	Collection string `json:"collection"` // MongoDB collection of audit events
+}
+
+// WebhooksConfig represents configuration of webhooks and delivery of their events
+type WebhooksConfig struct {
+	Collection string `json:"collection"` // MongoDB collection of webhooks
+	Deliveries string `json:"deliveries"` // MongoDB collection of delivery log
+	Workers    int    `json:"workers"`    // number of delivery workers
+	Queue      int    `json:"queue"`      // size of delivery queue
+	Retries    int    `json:"retries"`    // max number of retries of failed delivery
+	Backoff    int    `json:"backoff"`    // initial backoff of retries in seconds, doubled on every retry
+	Timeout    int    `json:"timeout"`    // timeout of delivery request in seconds
+
+	// hosts which webhooks of users may target, webhooks of MLHub
+	// administrators may target any host
+	Hosts []string `json:"hosts"`
+}
+
+// TrashConfig represents configuration of trash of deleted ML models
+type TrashConfig struct {
+	Dir       string `json:"dir"`       // trash area of ML bundles, default is trash directory next to storage
+	Retention int    `json:"retention"` // number of days deleted ML models are kept in trash
+	Interval  int    `json:"interval"`  // interval between purges of expired ML models in seconds
+}
+
+// ConsistencyConfig represents configuration of consistency checks of
+// storage, meta-data and ML backends
+type ConsistencyConfig struct {
+	Interval int    `json:"interval"` // interval between scheduled checks in seconds, 0 disables them
+	Policy   string `json:"policy"`   // policy of scheduled checks: report or repair
+	Grace    int    `json:"grace"`    // age in seconds of ML bundles below which they are not checked
+}
+
+// FederationConfig represents configuration of mirroring of ML models from
+// public catalogs of remote MLHub instances
+type FederationConfig struct {
+	Remotes    []RemoteConfig `json:"remotes"`    // remote MLHub instances to mirror ML models from
+	Interval   int            `json:"interval"`   // interval between scheduled pulls in seconds, 0 disables them
+	Timeout    int            `json:"timeout"`    // timeout of requests to remote MLHub in seconds
+	Collection string         `json:"collection"` // MongoDB collection of mirroring state
+}
+
+// RemoteConfig represents remote MLHub instance and its mirrored ML models
+type RemoteConfig struct {
+	Name       string   `json:"name"`       // remote name
+	URL        string   `json:"url"`        // remote MLHub URL
+	Token      string   `json:"token"`      // optional access token of remote MLHub
+	Models     []string `json:"models"`     // names of mirrored ML models, empty means all
+	Discipline string   `json:"discipline"` // mirror only ML models of given discipline
+	Backend    string   `json:"backend"`    // local ML backend of mirrored ML models, default is remote one
+}
+
+// HuggingFaceConfig represents configuration of import of ML models from
+// Hugging Face Hub compatible endpoints
+type HuggingFaceConfig struct {
+	Endpoints []string `json:"endpoints"` // allowed endpoints, default is Hugging Face Hub
+	Timeout   int      `json:"timeout"`   // timeout of requests to endpoints in seconds
+	MaxSize   int64    `json:"maxSize"`   // max size of downloaded repository in bytes
+}
+
+// TracingConfig represents configuration of OpenTelemetry tracing
+type TracingConfig struct {
+	Exporter string  `json:"exporter"` // otlp, stdout or empty to disable export of traces
+	Endpoint string  `json:"endpoint"` // OTLP HTTP collector endpoint, e.g. localhost:4318
+	Insecure bool    `json:"insecure"` // use plain HTTP to OTLP collector
+	Ratio    float64 `json:"ratio"`    // sampling ratio of new traces, default 1
+	Service  string  `json:"service"`  // service name of traces, default MLHub
+}
+
+// UploadsConfig represents configuration of resumable uploads
+type UploadsConfig struct {
+	Dir       string `json:"dir"`       // directory to keep partially uploaded ML bundles
+	Retention int    `json:"retention"` // number of hours stale uploads are kept since their last chunk
+	Interval  int    `json:"interval"`  // interval between removals of stale uploads in seconds
+}
+
+// GRPCConfig represents configuration of MLHub gRPC service
+type GRPCConfig struct {
+	Port           int    `json:"port"`           // gRPC port, zero disables gRPC service
+	ServerCert     string `json:"serverCert"`     // server certificate file for TLS
+	ServerKey      string `json:"serverKey"`      // server key file for TLS
+	MaxMessageSize int    `json:"maxMessageSize"` // max size of gRPC message in bytes
+}
+
+// HealthConfig represents configuration of ML backends health probes
+type HealthConfig struct {
+	Interval  int    `json:"interval"`  // interval between probes in seconds
+	Timeout   int    `json:"timeout"`   // timeout of single probe in seconds
+	Path      string `json:"path"`      // probe path relative to backend URI
+	Threshold int    `json:"threshold"` // number of failed probes to mark backend unhealthy
+}
+
+// ReplicasConfig represents configuration of ML backend replicas
+type ReplicasConfig struct {
+	Endpoints []string `json:"endpoints"` // URIs of ML backend replicas
+	Strategy  string   `json:"strategy"`  // round-robin, least-outstanding or consistent-hash
+}
+
+// TransportConfig represents configuration of HTTP transport of ML backend
+type TransportConfig struct {
+	ConnectTimeout  int `json:"connectTimeout"`  // connect timeout in seconds
+	ReadTimeout     int `json:"readTimeout"`     // timeout of waiting for response headers in seconds
+	Retries         int `json:"retries"`         // number of retries of idempotent requests
+	Backoff         int `json:"backoff"`         // initial backoff between retries in milliseconds
+	MaxConns        int `json:"maxConns"`        // max number of idle (pooled) connections per replica
+	BreakerFailures int `json:"breakerFailures"` // number of consecutive failures to open circuit breaker
+	BreakerReset    int `json:"breakerReset"`    // time in seconds before open circuit breaker allows trial request
+}
+
+// HubConfig represents MLHub specific configuration
+var HubConfig Configuration
+
+// helper function to set default values of MLHub configuration
+func (c *Configuration) defaults() {
+	if c.Predictions.Collection == "" {
+		c.Predictions.Collection = "predictions"
+	}
+	if c.Domains.Collection == "" {
+		c.Domains.Collection = "domains"
+	}
+	if c.Aliases.Collection == "" {
+		c.Aliases.Collection = "aliases"
+	}
+	if c.Routing.Collection == "" {
+		c.Routing.Collection = "routing"
+	}
+	if c.Routing.Outcomes == "" {
+		c.Routing.Outcomes = "outcomes"
+	}
+	if c.Shadow.Collection == "" {
+		c.Shadow.Collection = "shadows"
+	}
+	if c.Shadow.Results == "" {
+		c.Shadow.Results = "shadowresults"
+	}
+	if c.Shadow.Workers == 0 {
+		c.Shadow.Workers = 8
+	}
+	if c.Shadow.Tolerance == 0 {
+		c.Shadow.Tolerance = 1e-6
+	}
+	if c.Audit.Collection == "" {
+		c.Audit.Collection = "audit"
+	}
+	if c.Webhooks.Collection == "" {
+		c.Webhooks.Collection = "webhooks"
+	}
+	if c.Webhooks.Deliveries == "" {
+		c.Webhooks.Deliveries = "deliveries"
+	}
+	if c.Webhooks.Workers == 0 {
+		c.Webhooks.Workers = 4
+	}
+	if c.Webhooks.Queue == 0 {
+		c.Webhooks.Queue = 1000
+	}
+	if c.Webhooks.Retries == 0 {
+		c.Webhooks.Retries = 5
+	}
+	if c.Webhooks.Backoff == 0 {
+		c.Webhooks.Backoff = 1
+	}
+	if c.Webhooks.Timeout == 0 {
+		c.Webhooks.Timeout = 10
+	}
+	if c.Trash.Retention == 0 {
+		c.Trash.Retention = 30
+	}
+	if c.Trash.Interval == 0 {
+		c.Trash.Interval = 3600
+	}
+	if c.Consistency.Policy == "" {
+		c.Consistency.Policy = ReportPolicy
+	}
+	if c.Consistency.Grace == 0 {
+		c.Consistency.Grace = 3600
+	}
+	if c.Federation.Timeout == 0 {
+		c.Federation.Timeout = 300
+	}
+	if c.Federation.Collection == "" {
+		c.Federation.Collection = "mirrors"
+	}
+	if len(c.HuggingFace.Endpoints) == 0 {
+		c.HuggingFace.Endpoints = []string{HFEndpoint}
+	}
+	if c.HuggingFace.Timeout == 0 {
+		c.HuggingFace.Timeout = 600
+	}
+	if c.HuggingFace.MaxSize == 0 {
+		c.HuggingFace.MaxSize = 10 << 30
+	}
+	if c.Tracing.Ratio == 0 {
+		c.Tracing.Ratio = 1
+	}
+	if c.Tracing.Service == "" {
+		c.Tracing.Service = "MLHub"
+	}
+	if c.Uploads.Dir == "" {
+		c.Uploads.Dir = filepath.Join(os.TempDir(), "mlhub-uploads")
+	}
+	if c.Uploads.Retention == 0 {
+		c.Uploads.Retention = 24
+	}
+	if c.Uploads.Interval == 0 {
+		c.Uploads.Interval = 3600
+	}
+	if c.Health.Interval == 0 {
+		c.Health.Interval = 30
+	}
+	if c.Health.Timeout == 0 {
+		c.Health.Timeout = 5
+	}
+	if c.Health.Threshold == 0 {
+		c.Health.Threshold = 2
+	}
+	if c.Transport.ConnectTimeout == 0 {
+		c.Transport.ConnectTimeout = 5
+	}
+	if c.Transport.ReadTimeout == 0 {
+		c.Transport.ReadTimeout = 30
+	}
+	if c.Transport.Retries == 0 {
+		c.Transport.Retries = 2
+	}
+	if c.Transport.Backoff == 0 {
+		c.Transport.Backoff = 100
+	}
+	if c.Transport.MaxConns == 0 {
+		c.Transport.MaxConns = 16
+	}
+	if c.Transport.BreakerFailures == 0 {
+		c.Transport.BreakerFailures = 5
+	}
+	if c.Transport.BreakerReset == 0 {
+		c.Transport.BreakerReset = 30
+	}
+	if c.GRPC.MaxMessageSize == 0 {
+		c.GRPC.MaxMessageSize = 64 << 20
+	}
+}
+
+// ParseHubConfig parses MLHub specific configuration file, if file name
+// is empty the default configuration is returned
+func ParseHubConfig(fname string) (Configuration, error) {
+	var config Configuration
+	if fname != "" {
+		data, err := os.ReadFile(fname)
+		if err != nil {
+			return config, fmt.Errorf("[MLHub.main.ParseHubConfig] os.ReadFile error: %w", err)
+		}
+		err = json.Unmarshal(data, &config)
+		if err != nil {
+			return config, fmt.Errorf("[MLHub.main.ParseHubConfig] json.Unmarshal error: %w", err)
+		}
+	}
+	config.defaults()
+	switch config.Tracing.Exporter {
+	case "", OTLPExporter, StdoutExporter:
+	default:
+		return config, fmt.Errorf("[MLHub.main.ParseHubConfig] unsupported tracing exporter '%s'", config.Tracing.Exporter)
+	}
+	switch config.Consistency.Policy {
+	case ReportPolicy, RepairPolicy:
+	default:
+		return config, fmt.Errorf("[MLHub.main.ParseHubConfig] unsupported consistency policy '%s'", config.Consistency.Policy)
+	}
+	remotes := make(map[string]bool)
+	for _, remote := range config.Federation.Remotes {
+		if remote.Name == "" || remote.URL == "" {
+			return config, fmt.Errorf("[MLHub.main.ParseHubConfig] remote MLHub requires name and url")
+		}
+		if remotes[remote.Name] {
+			return config, fmt.Errorf("[MLHub.main.ParseHubConfig] duplicate remote MLHub '%s'", remote.Name)
+		}
+		remotes[remote.Name] = true
+	}
+	for name, rconfig := range config.Replicas {
+		switch rconfig.Strategy {
+		case "", RoundRobin, LeastOutstanding, ConsistentHash:
+		default:
+			return config, fmt.Errorf("[MLHub.main.ParseHubConfig] unsupported load balancing strategy '%s' of %s backend", rconfig.Strategy, name)
+		}
+	}
+	return config, nil
+}
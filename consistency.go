@@ -0,0 +1,263 @@
+package main
+
+// consistency module provides reconciliation of MLHub storage, meta-data and
+// ML backends, it finds orphaned ML bundles, records without ML bundles and
+// records whose ML backend no longer has ML model, and reports or repairs them
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// policies of consistency checks
+const (
+	ReportPolicy = "report"
+	RepairPolicy = "repair"
+)
+
+// kinds of consistency issues
+const (
+	OrphanedBundle    = "orphaned-bundle"
+	MissingBundle     = "missing-bundle"
+	MissingDeployment = "missing-deployment"
+)
+
+// activeUploads holds ML model versions which are being uploaded, their
+// storage and meta-data are not checked until upload is completed
+var activeUploads sync.Map
+
+// consistencyRun serializes consistency checks
+var consistencyRun sync.Mutex
+
+// consistency holds the last consistency report
+var consistency struct {
+	sync.Mutex
+	report *ConsistencyReport
+}
+
+// helper function to get key of ML model version
+func versionKey(rec Record) string {
+	return strings.Join([]string{rec.Type, rec.Model, rec.Version}, "/")
+}
+
+// helper function to get names of ML models loaded by replica of ML backend
+func replicaModels(ctx context.Context, replica srvConfig.MLBackend) (map[string]bool, error) {
+	if replica.Type == "ONNX" {
+		return replicaModelsOIP(ctx, replica)
+	}
+	return replicaModelsTFaaS(ctx, replica)
+}
+
+// helper function to get name of ML model version on its ML backend
+func deployedName(rec Record) string {
+	if rec.Type == "ONNX" {
+		return oipModelName(rec)
+	}
+	return rec.Model
+}
+
+// helper function to get ML models loaded by TFaaS replica, TFaaS provides
+// parameters of its ML models via /models API
+func replicaModelsTFaaS(ctx context.Context, replica srvConfig.MLBackend) (map[string]bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", replica.URI+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	rsp, err := httpClient(replica.Name).Do(req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TFaaS response status %s", rsp.Status)
+	}
+	var params []map[string]any
+	if err := json.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	models := make(map[string]bool)
+	for _, p := range params {
+		if name, ok := p["name"].(string); ok {
+			models[name] = true
+		}
+	}
+	return models, nil
+}
+
+// checkConsistency reconciles MLHub storage, meta-data and ML backends
+// according to given policy, i.e. it either reports found issues or also
+// repairs them. Orphaned ML bundles are removed, records without ML bundles
+// are moved to trash and ML models missing on ML backend replicas are
+// redeployed from their ML bundles
+func checkConsistency(ctx context.Context, policy string) (ConsistencyReport, error) {
+	report := ConsistencyReport{Policy: policy, Started: time.Now().Unix()}
+	if policy != ReportPolicy && policy != RepairPolicy {
+		msg := fmt.Sprintf("unsupported policy '%s', should be %s or %s", policy, ReportPolicy, RepairPolicy)
+		return report, errors.New(msg)
+	}
+	if !consistencyRun.TryLock() {
+		return report, errors.New("consistency check is already running")
+	}
+	defer consistencyRun.Unlock()
+
+	records, err := metaRecords("", "", "")
+	if err != nil {
+		return report, fmt.Errorf("[MLHub.main.checkConsistency] metaRecords error: %w", err)
+	}
+	report.Records = len(records)
+	known := make(map[string]bool)
+	for _, rec := range records {
+		known[versionKey(rec)] = true
+	}
+	grace := time.Now().Add(-time.Duration(HubConfig.Consistency.Grace) * time.Second)
+
+	// ML bundles are stored as StorageDir/type/model/version/bundle
+	dirs, _ := filepath.Glob(filepath.Join(StorageDir, "*", "*", "*"))
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		report.Bundles++
+		rel, _ := filepath.Rel(StorageDir, dir)
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		rec := Record{Type: parts[0], Model: parts[1], Version: parts[2]}
+		if _, ok := activeUploads.Load(versionKey(rec)); ok || known[versionKey(rec)] || info.ModTime().After(grace) {
+			continue
+		}
+		issue := ConsistencyIssue{Kind: OrphanedBundle, Model: rec.Model, Type: rec.Type, Version: rec.Version, Path: dir}
+		if policy == RepairPolicy {
+			repairIssue(&issue, os.RemoveAll(dir))
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	models := make(map[string]map[string]bool)
+	for _, rec := range records {
+		if _, ok := activeUploads.Load(versionKey(rec)); ok {
+			continue
+		}
+		fname := filepath.Join(versionDir(StorageDir, rec), rec.Bundle)
+		if _, err := os.Stat(fname); err != nil {
+			issue := ConsistencyIssue{Kind: MissingBundle, Model: rec.Model, Type: rec.Type, Version: rec.Version, Path: fname}
+			if policy == RepairPolicy {
+				repairIssue(&issue, trashModel(ctx, rec))
+			}
+			report.Issues = append(report.Issues, issue)
+			continue
+		}
+		report.Issues = append(report.Issues, checkDeployment(ctx, rec, fname, policy, models)...)
+	}
+	report.Finished = time.Now().Unix()
+	consistency.Lock()
+	consistency.report = &report
+	consistency.Unlock()
+	log.Printf("consistency check with %s policy found %d issues in %d records and %d bundles",
+		policy, len(report.Issues), report.Records, report.Bundles)
+	return report, nil
+}
+
+// helper function to check that healthy ML backend replicas which should hold
+// ML model have it, models caches ML models of replicas during single check
+func checkDeployment(ctx context.Context, rec Record, fname, policy string, models map[string]map[string]bool) []ConsistencyIssue {
+	var issues []ConsistencyIssue
+	if rec.Type != "TensorFlow" && rec.Type != "ONNX" {
+		// other ML backends do not support upload of ML models
+		return issues
+	}
+	backend, err := mlBackend(rec.Backend, rec.Type)
+	if err != nil {
+		issue := ConsistencyIssue{Kind: MissingDeployment, Model: rec.Model, Type: rec.Type, Version: rec.Version, Error: err.Error()}
+		return append(issues, issue)
+	}
+	for _, replica := range backendReplicas(backend) {
+		if !placedOn(rec, replica.URI) || !backendHealth(replica).Healthy {
+			continue
+		}
+		loaded, ok := models[replica.URI]
+		metricsCache("replica_models", ok)
+		if !ok {
+			loaded, err = replicaModels(ctx, replica)
+			if err != nil {
+				log.Printf("WARNING: unable to get ML models of replica %s, error %v", replica.URI, err)
+			}
+			models[replica.URI] = loaded
+		}
+		if loaded == nil || loaded[deployedName(rec)] {
+			continue
+		}
+		issue := ConsistencyIssue{Kind: MissingDeployment, Model: rec.Model, Type: rec.Type, Version: rec.Version, Path: fname, Replica: replica.URI}
+		if policy == RepairPolicy {
+			repairIssue(&issue, redeployReplica(ctx, replica, rec, FileBundle(fname)))
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// helper function to redeploy ML bundle to given replica of ML backend, ONNX
+// model is extracted from ML bundle if necessary
+func redeployReplica(ctx context.Context, replica srvConfig.MLBackend, rec Record, bf BundleFile) error {
+	if rec.Type == "ONNX" {
+		model, cleanup, err := onnxFile(bf)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		bf = model
+	}
+	return uploadReplica(ctx, replica, rec, bf)
+}
+
+// helper function to record outcome of issue repair
+func repairIssue(issue *ConsistencyIssue, err error) {
+	if err != nil {
+		issue.Error = err.Error()
+		log.Printf("ERROR: unable to repair %s of %s type %s version %s, error %v", issue.Kind, issue.Model, issue.Type, issue.Version, err)
+		return
+	}
+	issue.Repaired = true
+	log.Printf("repaired %s of %s type %s version %s", issue.Kind, issue.Model, issue.Type, issue.Version)
+}
+
+// helper function to get the last consistency report
+func lastConsistencyReport() (ConsistencyReport, bool) {
+	consistency.Lock()
+	defer consistency.Unlock()
+	if consistency.report == nil {
+		return ConsistencyReport{}, false
+	}
+	return *consistency.report, true
+}
+
+// ConsistencyChecks periodically checks consistency of MLHub storage,
+// meta-data and ML backends with configured policy
+func ConsistencyChecks() {
+	interval := time.Duration(HubConfig.Consistency.Interval) * time.Second
+	for {
+		time.Sleep(interval)
+		if _, err := checkConsistency(context.Background(), HubConfig.Consistency.Policy); err != nil {
+			log.Printf("ERROR: consistency check failed, error %v", err)
+		}
+	}
+}
@@ -5,20 +5,105 @@ package main
 // Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
 //
 
+import (
+	mlhub "github.com/CHESSComputing/MLHub/mlhub"
+)
+
 // Record define ML meta record
-type Record struct {
-	Model       string `json:"model"`       // model name
-	Type        string `json:"type"`        // model type
-	Backend     string `json:"backend"`     // ML backend name
-	Version     string `json:"version"`     // ML version
-	Description string `json:"description"` // ML model description
-	Reference   string `json:"reference"`   // ML reference URL
-	Discipline  string `json:"discipline"`  // ML discipline
-	Bundle      string `json:"bundle"`      // ML bundle file
-	UserName    string `json:"username"`    // user name
-	Input       any    `json:"input"`       // prediction input
-	Data        []byte `json:"data"`        // input data, e.g. image.png
-}
+type Record = mlhub.Record
+
+// Provenance defines ML model provenance
+type Provenance = mlhub.Provenance
+
+// Lineage defines lineage of ML model
+type Lineage = mlhub.Lineage
+
+// PredictionRecord defines provenance record of ML prediction
+type PredictionRecord = mlhub.PredictionRecord
+
+// Domain defines scientific domain (discipline) of ML models
+type Domain = mlhub.Domain
+
+// DomainNode defines scientific domain within domains taxonomy
+type DomainNode = mlhub.DomainNode
+
+// Alias defines alias of ML model version
+type Alias = mlhub.Alias
+
+// UploadStatus defines status of resumable upload of ML bundle
+type UploadStatus = mlhub.UploadStatus
+
+// BackendStatus defines health status of ML backend
+type BackendStatus = mlhub.BackendStatus
+
+// RoutingArm defines arm of routing rule
+type RoutingArm = mlhub.RoutingArm
+
+// RoutingRule defines canary or A/B split of predictions between ML model versions
+type RoutingRule = mlhub.RoutingRule
+
+// RoutingOutcome defines outcome of ML prediction routed by routing rule
+type RoutingOutcome = mlhub.RoutingOutcome
+
+// RoutingReport defines evaluation of routing rule arm
+type RoutingReport = mlhub.RoutingReport
+
+// ShadowRule defines shadow deployment of ML model
+type ShadowRule = mlhub.ShadowRule
+
+// ShadowResult defines outputs of primary and shadow versions of ML model
+type ShadowResult = mlhub.ShadowResult
+
+// ShadowReport defines comparison of primary and shadow versions of ML model
+type ShadowReport = mlhub.ShadowReport
+
+// AuditEvent defines event of ML model lifecycle in MLHub audit log
+type AuditEvent = mlhub.AuditEvent
+
+// Webhook defines subscription of downstream system to events of ML models
+type Webhook = mlhub.Webhook
+
+// WebhookEvent defines payload of event delivered to webhook
+type WebhookEvent = mlhub.WebhookEvent
+
+// WebhookDelivery defines attempt to deliver event to webhook
+type WebhookDelivery = mlhub.WebhookDelivery
+
+// ConsistencyIssue defines inconsistency between MLHub storage, meta-data and ML backends
+type ConsistencyIssue = mlhub.ConsistencyIssue
+
+// ConsistencyReport defines result of consistency check of MLHub
+type ConsistencyReport = mlhub.ConsistencyReport
+
+// BackupManifest defines manifest of MLHub backup archive
+type BackupManifest = mlhub.BackupManifest
+
+// BackupEntry defines ML model version in backup archive
+type BackupEntry = mlhub.BackupEntry
+
+// ImportResult defines outcome of import of ML model version or alias
+type ImportResult = mlhub.ImportResult
+
+// ImportReport defines outcome of import of MLHub backup archive
+type ImportReport = mlhub.ImportReport
+
+// Mirror defines state of mirroring of ML models from remote MLHub
+type Mirror = mlhub.Mirror
+
+// MirrorReport defines outcome of pull of ML models from remote MLHub
+type MirrorReport = mlhub.MirrorReport
+
+// HFImport defines import of ML model from Hugging Face-style repository
+type HFImport = mlhub.HFImport
+
+// Signature defines ML model signature, i.e. schema of its inputs and outputs
+type Signature = mlhub.Signature
+
+// TensorSpec defines name, data type and shape of ML model tensor
+type TensorSpec = mlhub.TensorSpec
+
+// Conversion defines link of converted ML model to its source ML model
+type Conversion = mlhub.Conversion
 
 // MLTypes defines supported ML data types
-var MLTypes = []string{"TensorFlow", "PyTorch", "ScikitLearn"}
+var MLTypes = mlhub.MLTypes
@@ -0,0 +1,112 @@
+package main
+
+// digest module provides digests of ML bundles and prediction data
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// helper function to format digest of given hash
+func hashDigest(h hash.Hash) string {
+	return fmt.Sprintf("sha256:%s", hex.EncodeToString(h.Sum(nil)))
+}
+
+// dataDigest returns digest of given data
+func dataDigest(data []byte) string {
+	h := sha256.New()
+	h.Write(data)
+	return hashDigest(h)
+}
+
+// fileDigest returns digest of given file
+func fileDigest(fname string) (string, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return "", fmt.Errorf("[MLHub.main.fileDigest] os.Open error: %w", err)
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("[MLHub.main.fileDigest] io.Copy error: %w", err)
+	}
+	return hashDigest(h), nil
+}
+
+// bundleDigest returns digest of ML bundle of given record, if record
+// does not have it the digest is computed from bundle in our storage
+func bundleDigest(rec Record) (string, error) {
+	if rec.Digest != "" {
+		return rec.Digest, nil
+	}
+	fname := filepath.Join(StorageDir, rec.Type, rec.Model, rec.Version, rec.Bundle)
+	return fileDigest(fname)
+}
+
+// bundleFileDigest returns digest of given ML bundle file
+func bundleFileDigest(bf BundleFile) (string, error) {
+	file, err := bf.Open()
+	if err != nil {
+		return "", fmt.Errorf("[MLHub.main.bundleFileDigest] bf.Open error: %w", err)
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("[MLHub.main.bundleFileDigest] io.Copy error: %w", err)
+	}
+	return hashDigest(h), nil
+}
+
+// inputDigest returns digest of prediction input, i.e. either JSON input of
+// given record or form values and files of HTTP request
+func inputDigest(rec Record, r *http.Request) (string, error) {
+	if r.MultipartForm == nil {
+		data, err := json.Marshal(rec.Input)
+		if err != nil {
+			return "", fmt.Errorf("[MLHub.main.inputDigest] json.Marshal error: %w", err)
+		}
+		return dataDigest(data), nil
+	}
+	h := sha256.New()
+	var keys []string
+	for k := range r.MultipartForm.Value {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range r.MultipartForm.Value[k] {
+			fmt.Fprintf(h, "%s=%s\n", k, v)
+		}
+	}
+	keys = []string{}
+	for k := range r.MultipartForm.File {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, fh := range r.MultipartForm.File[k] {
+			file, err := fh.Open()
+			if err != nil {
+				return "", fmt.Errorf("[MLHub.main.inputDigest] fh.Open error: %w", err)
+			}
+			fmt.Fprintf(h, "%s=%s\n", k, fh.Filename)
+			_, err = io.Copy(h, file)
+			file.Close()
+			if err != nil {
+				return "", fmt.Errorf("[MLHub.main.inputDigest] io.Copy error: %w", err)
+			}
+		}
+	}
+	return hashDigest(h), nil
+}
@@ -0,0 +1,197 @@
+package main
+
+// domains module provides taxonomy of scientific domains of ML models
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	mongo "github.com/CHESSComputing/golib/mongo"
+)
+
+// domainRecords retrieves scientific domains from underlying MLHub database
+func domainRecords() ([]Domain, error) {
+	results := mongo.Get(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Domains.Collection,
+		map[string]any{}, 0, -1)
+	var domains []Domain
+	for _, rec := range results {
+		var d Domain
+		delete(rec, "_id")
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return domains, fmt.Errorf("[MLHub.main.domainRecords] json.Marshal error: %w", err)
+		}
+		err = json.Unmarshal(data, &d)
+		if err != nil {
+			return domains, fmt.Errorf("[MLHub.main.domainRecords] json.Unmarshal error: %w", err)
+		}
+		domains = append(domains, d)
+	}
+	return domains, nil
+}
+
+// domainUpsert inserts or updates scientific domain in MLHub database
+func domainUpsert(d Domain) error {
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" {
+		return errors.New("domain name is empty")
+	}
+	domains, err := domainRecords()
+	if err != nil {
+		return err
+	}
+	if d.Parent != "" {
+		parent, ok := findDomain(domains, d.Parent)
+		if !ok {
+			msg := fmt.Sprintf("parent domain %s does not exist", d.Parent)
+			return errors.New(msg)
+		}
+		d.Parent = parent.Name
+		// make sure that we do not create cycle in taxonomy
+		for p, i := parent, 0; i <= len(domains); i++ {
+			if strings.EqualFold(p.Name, d.Name) {
+				msg := fmt.Sprintf("domain %s can not be sub-domain of itself", d.Name)
+				return errors.New(msg)
+			}
+			if p, ok = findDomain(domains, p.Parent); !ok {
+				break
+			}
+		}
+	}
+	spec := map[string]any{"name": d.Name}
+	meta := map[string]any{"name": d.Name, "parent": d.Parent, "description": d.Description}
+	if Verbose > 0 {
+		log.Printf("upsert domain %+v", d)
+	}
+	err = mongo.UpsertRecord(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Domains.Collection,
+		spec,
+		meta)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.domainUpsert] mongo.UpsertRecord error: %w", err)
+	}
+	return nil
+}
+
+// domainRemove removes scientific domain from MLHub database, domains which
+// have sub-domains can not be removed
+func domainRemove(name string) error {
+	domains, err := domainRecords()
+	if err != nil {
+		return err
+	}
+	domain, ok := findDomain(domains, name)
+	if !ok {
+		msg := fmt.Sprintf("domain %s does not exist", name)
+		return errors.New(msg)
+	}
+	for _, d := range domains {
+		if strings.EqualFold(d.Parent, domain.Name) {
+			msg := fmt.Sprintf("domain %s has sub-domain %s", domain.Name, d.Name)
+			return errors.New(msg)
+		}
+	}
+	err = mongo.Remove(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Domains.Collection,
+		map[string]any{"name": domain.Name})
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.domainRemove] mongo.Remove error: %w", err)
+	}
+	return nil
+}
+
+// helper function to find domain by its name (case insensitive)
+func findDomain(domains []Domain, name string) (Domain, bool) {
+	name = strings.TrimSpace(name)
+	for _, d := range domains {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return Domain{}, false
+}
+
+// validateDiscipline validates given discipline against domains taxonomy and
+// returns its canonical name. If taxonomy is not defined any discipline is accepted
+func validateDiscipline(discipline string) (string, error) {
+	if discipline == "" {
+		return discipline, nil
+	}
+	domains, err := domainRecords()
+	if err != nil {
+		return discipline, err
+	}
+	if len(domains) == 0 {
+		return discipline, nil
+	}
+	if d, ok := findDomain(domains, discipline); ok {
+		return d.Name, nil
+	}
+	msg := fmt.Sprintf("unknown discipline '%s', please use one of MLHub domains", discipline)
+	return discipline, errors.New(msg)
+}
+
+// domainTree builds flat list of domains in taxonomy order along with
+// ML models of every domain
+func domainTree() ([]DomainNode, error) {
+	var nodes []DomainNode
+	domains, err := domainRecords()
+	if err != nil {
+		return nodes, err
+	}
+	records, err := metaRecords("", "", "")
+	if err != nil {
+		return nodes, err
+	}
+	// every ML model is listed once regardless of number of its versions
+	models := make(map[string][]string)
+	for _, rec := range records {
+		if d, ok := findDomain(domains, rec.Discipline); ok && !slices.Contains(models[d.Name], rec.Model) {
+			models[d.Name] = append(models[d.Name], rec.Model)
+		}
+	}
+	for _, names := range models {
+		sort.Strings(names)
+	}
+	children := make(map[string][]Domain)
+	for _, d := range domains {
+		parent := d.Parent
+		if _, ok := findDomain(domains, parent); !ok {
+			parent = ""
+		}
+		children[parent] = append(children[parent], d)
+	}
+	var walk func(parent string, depth int) int
+	walk = func(parent string, depth int) int {
+		total := 0
+		subs := children[parent]
+		sort.Slice(subs, func(i, j int) bool { return subs[i].Name < subs[j].Name })
+		for _, d := range subs {
+			idx := len(nodes)
+			nodes = append(nodes, DomainNode{
+				Domain: d,
+				Depth:  depth,
+				Count:  len(models[d.Name]),
+				Models: models[d.Name],
+			})
+			nodes[idx].Total = nodes[idx].Count + walk(d.Name, depth+1)
+			total += nodes[idx].Total
+		}
+		return total
+	}
+	walk("", 0)
+	return nodes, nil
+}
@@ -0,0 +1,279 @@
+package main
+
+// fair module provides FAIR representations of ML meta records, i.e.
+// schema.org JSON-LD and RO-Crate archives
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// helper function to build base URL of MLHub service from HTTP request
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	base := srvConfig.Config.MLHub.WebServer.Base
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, base)
+}
+
+// helper function to build query of ML model version
+func recordQuery(rec Record) string {
+	vals := url.Values{}
+	if rec.Type != "" {
+		vals.Set("type", rec.Type)
+	}
+	if rec.Version != "" {
+		vals.Set("version", rec.Version)
+	}
+	return vals.Encode()
+}
+
+// helper function to build URL of ML model page
+func modelURL(rec Record, base string) string {
+	return fmt.Sprintf("%s/model/%s?%s", base, url.PathEscape(rec.Model), recordQuery(rec))
+}
+
+// helper function to build URL of ML model bundle
+func bundleURL(rec Record, base string) string {
+	return fmt.Sprintf("%s/models/%s?%s", base, url.PathEscape(rec.Model), recordQuery(rec))
+}
+
+// JSONLD builds schema.org JSON-LD representation of ML meta record
+func JSONLD(rec Record, base string) map[string]any {
+	page := modelURL(rec, base)
+	doc := map[string]any{
+		"@context":            "https://schema.org",
+		"@type":               []string{"SoftwareSourceCode", "Dataset"},
+		"@id":                 page,
+		"url":                 page,
+		"identifier":          fmt.Sprintf("%s/%s/%s", rec.Type, rec.Model, rec.Version),
+		"name":                rec.Model,
+		"version":             rec.Version,
+		"description":         rec.Description,
+		"applicationCategory": "Machine Learning Model",
+		"runtimePlatform":     rec.Type,
+		"provider": map[string]any{
+			"@type": "Organization",
+			"name":  "MLHub",
+			"url":   base,
+		},
+	}
+	keywords := []string{"machine learning", rec.Type}
+	if rec.Discipline != "" {
+		doc["about"] = rec.Discipline
+		keywords = append(keywords, rec.Discipline)
+	}
+	doc["keywords"] = keywords
+	if rec.Reference != "" {
+		doc["citation"] = rec.Reference
+	}
+	if rec.UserName != "" {
+		doc["author"] = map[string]any{"@type": "Person", "name": rec.UserName}
+	}
+	var basedOn []string
+	basedOn = append(basedOn, rec.Provenance.Datasets...)
+	if rec.Provenance.ParentModel != "" {
+		parent := Record{
+			Model:   rec.Provenance.ParentModel,
+			Type:    rec.Type,
+			Version: rec.Provenance.ParentVersion,
+		}
+		basedOn = append(basedOn, modelURL(parent, base))
+	}
+	if conv := rec.Conversion; conv != nil {
+		source := Record{
+			Model:   conv.SourceModel,
+			Type:    conv.SourceType,
+			Version: conv.SourceVersion,
+		}
+		basedOn = append(basedOn, modelURL(source, base))
+	}
+	if len(basedOn) > 0 {
+		doc["isBasedOn"] = basedOn
+	}
+	if rec.Bundle != "" {
+		doc["distribution"] = map[string]any{
+			"@type":          "DataDownload",
+			"name":           rec.Bundle,
+			"contentUrl":     bundleURL(rec, base),
+			"encodingFormat": bundleFormat(rec.Bundle),
+		}
+	}
+	return doc
+}
+
+// helper function to guess encoding format of ML bundle file
+func bundleFormat(fname string) string {
+	switch {
+	case strings.HasSuffix(fname, ".tar.gz"), strings.HasSuffix(fname, ".tgz"):
+		return "application/gzip"
+	case strings.HasSuffix(fname, ".zip"):
+		return "application/zip"
+	case strings.HasSuffix(fname, ".tar"):
+		return "application/x-tar"
+	}
+	return "application/octet-stream"
+}
+
+// ModelCard builds markdown model card for given ML meta record
+func ModelCard(rec Record) string {
+	var card strings.Builder
+	card.WriteString(fmt.Sprintf("# %s\n\n", rec.Model))
+	if rec.Description != "" {
+		card.WriteString(fmt.Sprintf("%s\n\n", rec.Description))
+	}
+	card.WriteString("| Attribute | Value |\n|---|---|\n")
+	card.WriteString(fmt.Sprintf("| Model | %s |\n", rec.Model))
+	card.WriteString(fmt.Sprintf("| Version | %s |\n", rec.Version))
+	card.WriteString(fmt.Sprintf("| Type | %s |\n", rec.Type))
+	card.WriteString(fmt.Sprintf("| Backend | %s |\n", rec.Backend))
+	card.WriteString(fmt.Sprintf("| Discipline | %s |\n", rec.Discipline))
+	card.WriteString(fmt.Sprintf("| Reference | %s |\n", rec.Reference))
+	card.WriteString(fmt.Sprintf("| Bundle | %s |\n", rec.Bundle))
+	card.WriteString(fmt.Sprintf("| Author | %s |\n", rec.UserName))
+	if conv := rec.Conversion; conv != nil {
+		card.WriteString(fmt.Sprintf("| Converted from | %s %s version %s |\n", conv.SourceModel, conv.SourceType, conv.SourceVersion))
+		card.WriteString(fmt.Sprintf("| Converter | %s |\n", conv.Converter))
+	}
+	return card.String()
+}
+
+// ROCrateMetadata builds ro-crate-metadata.json content for given ML meta record,
+// see https://www.researchobject.org/ro-crate/1.1/
+func ROCrateMetadata(rec Record, base string) map[string]any {
+	parts := []map[string]string{
+		{"@id": "README.md"},
+		{"@id": "metadata.json"},
+	}
+	graph := []map[string]any{
+		{
+			"@type":      "CreativeWork",
+			"@id":        "ro-crate-metadata.json",
+			"conformsTo": map[string]string{"@id": "https://w3id.org/ro/crate/1.1"},
+			"about":      map[string]string{"@id": "./"},
+		},
+		{
+			"@id":            "README.md",
+			"@type":          "File",
+			"name":           "Model card",
+			"encodingFormat": "text/markdown",
+		},
+		{
+			"@id":            "metadata.json",
+			"@type":          "File",
+			"name":           "MLHub meta-data record",
+			"encodingFormat": "application/json",
+		},
+	}
+	if rec.Bundle != "" {
+		parts = append(parts, map[string]string{"@id": rec.Bundle})
+		graph = append(graph, map[string]any{
+			"@id":            rec.Bundle,
+			"@type":          "File",
+			"name":           fmt.Sprintf("%s model bundle", rec.Type),
+			"encodingFormat": bundleFormat(rec.Bundle),
+		})
+	}
+	root := map[string]any{
+		"@id":           "./",
+		"@type":         "Dataset",
+		"name":          rec.Model,
+		"version":       rec.Version,
+		"description":   rec.Description,
+		"datePublished": time.Now().UTC().Format(time.RFC3339),
+		"url":           modelURL(rec, base),
+		"hasPart":       parts,
+	}
+	if rec.Discipline != "" {
+		root["about"] = rec.Discipline
+	}
+	if rec.Reference != "" {
+		root["citation"] = rec.Reference
+	}
+	if rec.UserName != "" {
+		root["author"] = map[string]any{"@type": "Person", "name": rec.UserName}
+	}
+	graph = append([]map[string]any{graph[0], root}, graph[1:]...)
+	return map[string]any{
+		"@context": "https://w3id.org/ro/crate/1.1/context",
+		"@graph":   graph,
+	}
+}
+
+// WriteROCrate writes RO-Crate zip archive of given ML meta record
+// into provided writer. The archive contains the RO-Crate metadata,
+// model card, MLHub meta-data record and ML bundle file
+func WriteROCrate(w io.Writer, rec Record, base string) error {
+	// open bundle file first to not produce partial archive if it does not exist
+	var file *os.File
+	if rec.Bundle != "" {
+		fname := filepath.Join(StorageDir, rec.Type, rec.Model, rec.Version, rec.Bundle)
+		var err error
+		file, err = os.Open(fname)
+		if err != nil {
+			return fmt.Errorf("[MLHub.main.WriteROCrate] os.Open error: %w", err)
+		}
+		defer file.Close()
+	}
+	zw := zip.NewWriter(w)
+	meta, err := json.MarshalIndent(ROCrateMetadata(rec, base), "", "  ")
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.WriteROCrate] json.Marshal error: %w", err)
+	}
+	if err := zipContent(zw, "ro-crate-metadata.json", meta); err != nil {
+		return err
+	}
+	if err := zipContent(zw, "README.md", []byte(ModelCard(rec))); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.WriteROCrate] json.Marshal error: %w", err)
+	}
+	if err := zipContent(zw, "metadata.json", data); err != nil {
+		return err
+	}
+	if file != nil {
+		fw, err := zw.Create(rec.Bundle)
+		if err != nil {
+			return fmt.Errorf("[MLHub.main.WriteROCrate] zip.Create error: %w", err)
+		}
+		if _, err := io.Copy(fw, file); err != nil {
+			return fmt.Errorf("[MLHub.main.WriteROCrate] io.Copy error: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("[MLHub.main.WriteROCrate] zip.Close error: %w", err)
+	}
+	return nil
+}
+
+// helper function to write given content to zip archive
+func zipContent(zw *zip.Writer, name string, content []byte) error {
+	fw, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.zipContent] zip.Create error: %w", err)
+	}
+	if _, err := fw.Write(content); err != nil {
+		return fmt.Errorf("[MLHub.main.zipContent] zip.Write error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package main
+
+// federation module provides mirroring of ML models from public catalogs of
+// remote MLHub instances. Mirrored ML models keep URL of their remote MLHub
+// as origin, and remote ML models are pulled incrementally using their
+// change timestamps
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	mongo "github.com/CHESSComputing/golib/mongo"
+)
+
+// federationRun serializes pulls from remote MLHub instances
+var federationRun sync.Mutex
+
+// helper function to find configuration of remote MLHub
+func remoteConfig(name string) (RemoteConfig, error) {
+	for _, remote := range HubConfig.Federation.Remotes {
+		if remote.Name == name {
+			return remote, nil
+		}
+	}
+	msg := fmt.Sprintf("remote MLHub '%s' is not configured", name)
+	return RemoteConfig{}, errors.New(msg)
+}
+
+// helper function to get URL of remote MLHub which is used as origin of its ML models
+func remoteURL(remote RemoteConfig) string {
+	return strings.TrimSuffix(remote.URL, "/")
+}
+
+// mirrorState retrieves mirroring state of given remote MLHub
+func mirrorState(remote RemoteConfig) (Mirror, error) {
+	mirror := Mirror{Remote: remote.Name, URL: remoteURL(remote)}
+	results := mongo.Get(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Federation.Collection,
+		map[string]any{"remote": remote.Name}, 0, 1)
+	for _, rec := range results {
+		delete(rec, "_id")
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return mirror, fmt.Errorf("[MLHub.main.mirrorState] json.Marshal error: %w", err)
+		}
+		err = json.Unmarshal(data, &mirror)
+		if err != nil {
+			return mirror, fmt.Errorf("[MLHub.main.mirrorState] json.Unmarshal error: %w", err)
+		}
+	}
+	// remote MLHub may be moved to another URL
+	mirror.URL = remoteURL(remote)
+	return mirror, nil
+}
+
+// helper function to store mirroring state of remote MLHub
+func setMirrorState(mirror Mirror) error {
+	spec := map[string]any{"remote": mirror.Remote}
+	meta := map[string]any{
+		"remote": mirror.Remote,
+		"url":    mirror.URL,
+		"synced": mirror.Synced,
+		"pulled": mirror.Pulled,
+		"error":  mirror.Error,
+	}
+	err := mongo.UpsertRecord(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Federation.Collection,
+		spec,
+		meta)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.setMirrorState] mongo.UpsertRecord error: %w", err)
+	}
+	return nil
+}
+
+// mirrors provides mirroring state of all configured remote MLHub instances
+func mirrors() ([]Mirror, error) {
+	var states []Mirror
+	for _, remote := range HubConfig.Federation.Remotes {
+		mirror, err := mirrorState(remote)
+		if err != nil {
+			return states, err
+		}
+		states = append(states, mirror)
+	}
+	return states, nil
+}
+
+// helper function to perform HTTP GET request to remote MLHub
+func remoteGet(ctx context.Context, remote RemoteConfig, path, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", remoteURL(remote)+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if remote.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+remote.Token)
+	}
+	client := &http.Client{Timeout: time.Duration(HubConfig.Federation.Timeout) * time.Second}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		rsp.Body.Close()
+		return nil, fmt.Errorf("remote MLHub %s responded with status %s", remote.Name, rsp.Status)
+	}
+	return rsp, nil
+}
+
+// remoteRecords fetches records of remote MLHub catalog changed since given
+// timestamp, ML models mirrored by remote MLHub itself are not mirrored again
+func remoteRecords(ctx context.Context, remote RemoteConfig, since int64) ([]Record, error) {
+	vals := url.Values{}
+	if since > 0 {
+		vals.Set("since", strconv.FormatInt(since, 10))
+	}
+	if remote.Discipline != "" {
+		vals.Set("discipline", remote.Discipline)
+	}
+	rsp, err := remoteGet(ctx, remote, "/models?"+vals.Encode(), "application/json")
+	if err != nil {
+		return nil, fmt.Errorf("[MLHub.main.remoteRecords] remoteGet error: %w", err)
+	}
+	defer rsp.Body.Close()
+	var records []Record
+	if err := json.NewDecoder(rsp.Body).Decode(&records); err != nil {
+		return nil, fmt.Errorf("[MLHub.main.remoteRecords] json.Decode error: %w", err)
+	}
+	var selected []Record
+	for _, rec := range records {
+		if rec.Origin != "" {
+			continue
+		}
+		if len(remote.Models) > 0 && !slices.Contains(remote.Models, rec.Model) {
+			continue
+		}
+		selected = append(selected, rec)
+	}
+	// records are mirrored in order of their changes to advance synced timestamp
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Updated < selected[j].Updated
+	})
+	return selected, nil
+}
+
+// helper function to download ML bundle of remote record into temporary
+// file, the ML bundle is verified against digest of remote record
+func remoteBundle(ctx context.Context, remote RemoteConfig, rec Record) (string, error) {
+	if rec.Digest == "" {
+		return "", errors.New("remote record does not provide digest of ML bundle")
+	}
+	path := fmt.Sprintf("/bundles/%s/%s/%s/%s",
+		url.PathEscape(rec.Type), url.PathEscape(rec.Model), url.PathEscape(rec.Version), url.PathEscape(rec.Bundle))
+	rsp, err := remoteGet(ctx, remote, path, "")
+	if err != nil {
+		return "", fmt.Errorf("[MLHub.main.remoteBundle] remoteGet error: %w", err)
+	}
+	defer rsp.Body.Close()
+	file, err := os.CreateTemp("", "mlhub-mirror-*")
+	if err != nil {
+		return "", fmt.Errorf("[MLHub.main.remoteBundle] os.CreateTemp error: %w", err)
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, h), rsp.Body)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("[MLHub.main.remoteBundle] io.Copy error: %w", err)
+	}
+	if digest := hashDigest(h); digest != rec.Digest {
+		os.Remove(file.Name())
+		msg := fmt.Sprintf("ML bundle digest %s does not match remote digest %s", digest, rec.Digest)
+		return "", errors.New(msg)
+	}
+	return file.Name(), nil
+}
+
+// helper function to check ML record of remote MLHub, its name, type and
+// version are used as storage path components and its bundle name should
+// be local to ML model storage area
+func checkRemoteRecord(rec Record) error {
+	for _, val := range []string{rec.Model, rec.Type, rec.Version} {
+		if val == "" || val == "." || val == ".." || strings.ContainsAny(val, `/\`) {
+			return fmt.Errorf("remote ML model %q type %q version %q is not valid", rec.Model, rec.Type, rec.Version)
+		}
+	}
+	if !filepath.IsLocal(rec.Bundle) || strings.ContainsAny(rec.Bundle, `/\`) {
+		return fmt.Errorf("remote ML bundle %q is not valid", rec.Bundle)
+	}
+	return nil
+}
+
+// helper function to mirror single ML model version of remote MLHub, ML
+// models which already exist locally are skipped unless they are mirrored
+// from the same remote MLHub and their ML bundle was changed
+func mirrorRecord(ctx context.Context, remote RemoteConfig, rec Record) ImportResult {
+	result := ImportResult{Model: rec.Model, Type: rec.Type, Version: rec.Version, Status: ImportFailed}
+	if err := checkRemoteRecord(rec); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	origin := remoteURL(remote)
+	status := ImportImported
+	records, err := metaRecords(rec.Model, rec.Type, rec.Version)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	for _, r := range records {
+		if r.Origin != origin {
+			result.Error = fmt.Sprintf("ML model %s type %s version %s already exists and it is not mirrored from %s", rec.Model, rec.Type, rec.Version, origin)
+			return result
+		}
+		if r.Digest == rec.Digest {
+			result.Status = ImportSkipped
+			return result
+		}
+		status = ImportOverwritten
+	}
+	if remote.Backend != "" {
+		rec.Backend = remote.Backend
+	}
+	if _, err := mlBackend(rec.Backend, rec.Type); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	rec, err = validateUpload(rec)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	fname, err := remoteBundle(ctx, remote, rec)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer os.Remove(fname)
+	for _, r := range records {
+		if err := trashModel(ctx, r); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+	rec.Origin = origin
+	rec.Replicas = nil
+	rec.Deleted = 0
+	bf := BundleFile{
+		Name: rec.Bundle,
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(fname)
+		},
+	}
+	if err := Upload(ctx, rec, bf); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = status
+	return result
+}
+
+// pullRemote mirrors ML models of remote MLHub which were changed since its
+// last pull. The synced timestamp is not advanced beyond ML models which
+// failed to mirror, therefore they are retried on next pull
+func pullRemote(ctx context.Context, remote RemoteConfig) (MirrorReport, error) {
+	report := MirrorReport{Remote: remote.Name, URL: remoteURL(remote), Started: time.Now().Unix()}
+	if !federationRun.TryLock() {
+		return report, errors.New("pull from remote MLHub is already running")
+	}
+	defer federationRun.Unlock()
+	mirror, err := mirrorState(remote)
+	if err != nil {
+		return report, err
+	}
+	report.Since = mirror.Synced
+	report.Synced = mirror.Synced
+	mirror.Pulled = report.Started
+	records, err := remoteRecords(ctx, remote, mirror.Synced)
+	if err != nil {
+		mirror.Error = err.Error()
+		if serr := setMirrorState(mirror); serr != nil {
+			log.Printf("ERROR: unable to store mirroring state of %s, error %v", remote.Name, serr)
+		}
+		return report, err
+	}
+	failed := false
+	mirror.Error = ""
+	for _, rec := range records {
+		result := mirrorRecord(ctx, remote, rec)
+		if result.Status == ImportFailed {
+			log.Printf("ERROR: unable to mirror %s type %s version %s from %s, error %s",
+				rec.Model, rec.Type, rec.Version, remote.Name, result.Error)
+			failed = true
+			mirror.Error = result.Error
+		} else if !failed && rec.Updated > report.Synced {
+			report.Synced = rec.Updated
+		}
+		report.Results = append(report.Results, result)
+	}
+	mirror.Synced = report.Synced
+	report.Finished = time.Now().Unix()
+	if err := setMirrorState(mirror); err != nil {
+		return report, err
+	}
+	log.Printf("pulled %d ML models from %s since %d, synced up to %d", len(records), remote.Name, report.Since, report.Synced)
+	return report, nil
+}
+
+// Federation periodically pulls ML models from all configured remote MLHub instances
+func Federation() {
+	interval := time.Duration(HubConfig.Federation.Interval) * time.Second
+	for {
+		for _, remote := range HubConfig.Federation.Remotes {
+			if _, err := pullRemote(context.Background(), remote); err != nil {
+				log.Printf("ERROR: unable to pull ML models from %s, error %v", remote.Name, err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+// grpc module provides gRPC service of MLHub, it shares meta-data and ML
+// backend layers with HTTP handlers
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/CHESSComputing/MLHub/mlhubpb"
+	authz "github.com/CHESSComputing/golib/authz"
+	srvConfig "github.com/CHESSComputing/golib/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcScopes defines token scopes required by gRPC methods, methods which
+// are not listed here only require valid token
+var grpcScopes = map[string]string{
+	mlhubpb.MLHub_Predict_FullMethodName:       "read",
+	mlhubpb.MLHub_PredictStream_FullMethodName: "read",
+	mlhubpb.MLHub_UploadBundle_FullMethodName:  "write",
+}
+
+// grpcServer implements MLHub gRPC service
+type grpcServer struct {
+	mlhubpb.UnimplementedMLHubServer
+}
+
+// helper function to get bearer token from gRPC metadata
+func grpcToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, val := range md.Get("authorization") {
+		if len(val) > 7 && strings.EqualFold(val[:7], "bearer ") {
+			return strings.TrimSpace(val[7:])
+		}
+	}
+	return ""
+}
+
+// helper function to check if token scopes, separated by spaces, commas or
+// pluses, contain given scope
+func hasScope(scopes, scope string) bool {
+	fields := strings.FieldsFunc(scopes, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ',' || r == '+'
+	})
+	return slices.Contains(fields, scope)
+}
+
+// helper function to authorize gRPC call for given method
+func grpcAuth(ctx context.Context, method string) error {
+	token := grpcToken(ctx)
+	if token == "" {
+		return status.Error(codes.Unauthenticated, "no bearer token in authorization metadata")
+	}
+	claims, err := authz.TokenClaims(token, srvConfig.Config.Authz.ClientID)
+	if err != nil {
+		return status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+	}
+	if scope, ok := grpcScopes[method]; ok && !hasScope(claims.CustomClaims.Scope, scope) {
+		return status.Errorf(codes.PermissionDenied, "token does not have %s scope", scope)
+	}
+	return nil
+}
+
+// helper function to authorize unary gRPC calls
+func grpcUnaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := grpcAuth(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// helper function to authorize streaming gRPC calls
+func grpcStreamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := grpcAuth(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// helper function to build HTTP request for shared layers of HTTP handlers,
+// e.g. Predict, from gRPC call
+func grpcRequest(ctx context.Context, method, target, ctype string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if ctype != "" {
+		req.Header.Set("Content-Type", ctype)
+	}
+	if token := grpcToken(ctx); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if keys := md.Get(strings.ToLower(RoutingKeyHeader)); len(keys) > 0 {
+			req.Header.Set(RoutingKeyHeader, keys[0])
+		}
+	}
+	return req, nil
+}
+
+// helper function to convert ML record to its protobuf representation
+func recordProto(rec Record) *mlhubpb.Record {
+	prov := &mlhubpb.Provenance{
+		Datasets:      rec.Provenance.Datasets,
+		Evaluation:    rec.Provenance.Evaluation,
+		Commit:        rec.Provenance.Commit,
+		ParentModel:   rec.Provenance.ParentModel,
+		ParentVersion: rec.Provenance.ParentVersion,
+	}
+	if len(rec.Provenance.Parameters) > 0 {
+		if data, err := json.Marshal(rec.Provenance.Parameters); err == nil {
+			prov.Parameters = string(data)
+		}
+	}
+	return &mlhubpb.Record{
+		Model:       rec.Model,
+		Type:        rec.Type,
+		Backend:     rec.Backend,
+		Version:     rec.Version,
+		Description: rec.Description,
+		Reference:   rec.Reference,
+		Discipline:  rec.Discipline,
+		Bundle:      rec.Bundle,
+		Digest:      rec.Digest,
+		UserName:    rec.UserName,
+		Provenance:  prov,
+	}
+}
+
+// helper function to convert protobuf representation of ML record
+func protoRecord(pbr *mlhubpb.Record) (Record, error) {
+	rec := Record{
+		Model:       pbr.GetModel(),
+		Type:        pbr.GetType(),
+		Backend:     pbr.GetBackend(),
+		Version:     pbr.GetVersion(),
+		Description: pbr.GetDescription(),
+		Reference:   pbr.GetReference(),
+		Discipline:  pbr.GetDiscipline(),
+	}
+	if prov := pbr.GetProvenance(); prov != nil {
+		rec.Provenance = Provenance{
+			Datasets:      prov.GetDatasets(),
+			Evaluation:    prov.GetEvaluation(),
+			Commit:        prov.GetCommit(),
+			ParentModel:   prov.GetParentModel(),
+			ParentVersion: prov.GetParentVersion(),
+		}
+		if params := prov.GetParameters(); params != "" {
+			if err := json.Unmarshal([]byte(params), &rec.Provenance.Parameters); err != nil {
+				return rec, fmt.Errorf("unable to parse training parameters: %w", err)
+			}
+		}
+	}
+	return rec, nil
+}
+
+// ListModels implements MLHub gRPC ListModels method
+func (s *grpcServer) ListModels(ctx context.Context, req *mlhubpb.ListModelsRequest) (*mlhubpb.ListModelsResponse, error) {
+	vals := url.Values{}
+	for key, val := range map[string]string{
+		"q": req.GetQuery(), "type": req.GetType(), "backend": req.GetBackend(),
+		"discipline": req.GetDiscipline(), "user": req.GetUser(),
+	} {
+		if val != "" {
+			vals.Set(key, val)
+		}
+	}
+	vals.Set("idx", strconv.Itoa(int(req.GetIdx())))
+	vals.Set("limit", strconv.Itoa(int(req.GetLimit())))
+	r, err := grpcRequest(ctx, "GET", "/models?"+vals.Encode(), "", nil)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	idx, limit := pagination(r)
+	records, err := metaPage(modelsSpec(r), idx, limit)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	rsp := &mlhubpb.ListModelsResponse{}
+	for _, rec := range records {
+		rsp.Records = append(rsp.Records, recordProto(rec))
+	}
+	return rsp, nil
+}
+
+// GetModel implements MLHub gRPC GetModel method
+func (s *grpcServer) GetModel(ctx context.Context, req *mlhubpb.GetModelRequest) (*mlhubpb.Record, error) {
+	spec := Record{Model: req.GetModel(), Type: req.GetType(), Version: req.GetVersion()}
+	rec, err := modelRecord(ctx, spec)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	grpcMetricsRecord(ctx, rec)
+	return recordProto(rec), nil
+}
+
+// helper function to build HTTP prediction request from gRPC prediction request
+func predictRequest(ctx context.Context, req *mlhubpb.PredictRequest) (Record, *http.Request, error) {
+	spec := Record{
+		Model:   req.GetModel(),
+		Type:    req.GetType(),
+		Version: req.GetVersion(),
+		Backend: req.GetBackend(),
+	}
+	var r *http.Request
+	var err error
+	switch input := req.GetInput().(type) {
+	case *mlhubpb.PredictRequest_Json:
+		if err := json.Unmarshal([]byte(input.Json), &spec.Input); err != nil {
+			return spec, nil, fmt.Errorf("invalid JSON input: %w", err)
+		}
+		r, err = grpcRequest(ctx, "POST", "/predict", "application/json", nil)
+		if err != nil {
+			return spec, nil, err
+		}
+		r.Header.Set("Accept", "application/json")
+	case *mlhubpb.PredictRequest_Data:
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for key, val := range map[string]string{
+			"model": spec.Model, "type": spec.Type, "version": spec.Version, "backend": spec.Backend,
+		} {
+			if val != "" {
+				writer.WriteField(key, val)
+			}
+		}
+		field := req.GetField()
+		if field == "" {
+			field = "image"
+		}
+		fname := req.GetFileName()
+		if fname == "" {
+			fname = field
+		}
+		fw, err := writer.CreateFormFile(field, fname)
+		if err != nil {
+			return spec, nil, err
+		}
+		fw.Write(input.Data)
+		writer.Close()
+		r, err = grpcRequest(ctx, "POST", "/predict", writer.FormDataContentType(), body.Bytes())
+		if err != nil {
+			return spec, nil, err
+		}
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return spec, nil, err
+		}
+		r.Header.Set("Accept", "application/octet-stream")
+	default:
+		return spec, nil, errors.New("prediction request does not provide input")
+	}
+	if req.GetRecordProvenance() {
+		r.Header.Set(ProvenanceHeader, "true")
+	}
+	return spec, r, nil
+}
+
+// helper function to perform ML prediction of gRPC prediction request
+func (s *grpcServer) predict(ctx context.Context, req *mlhubpb.PredictRequest) (*mlhubpb.PredictResponse, error) {
+	spec, r, err := predictRequest(ctx, req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	rec, routed, err := routedRecord(spec, r)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	grpcMetricsRecord(ctx, rec)
+	time0 := time.Now()
+	data, mtype, err := Predict(rec, r)
+	latency := time.Since(time0)
+	if routed {
+		recordOutcome(rec, r, predictStatus(err), latency, err)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	shadowPredict(rec, r, data, latency)
+	rsp := &mlhubpb.PredictResponse{Output: data, ContentType: mtype, Version: rec.Version}
+	if recordProvenance(r) {
+		if pid, err := recordPrediction(rec, r, data); err == nil {
+			rsp.Provenance = pid
+		} else {
+			log.Printf("ERROR: unable to record prediction provenance, error %v", err)
+		}
+	}
+	return rsp, nil
+}
+
+// Predict implements MLHub gRPC Predict method
+func (s *grpcServer) Predict(ctx context.Context, req *mlhubpb.PredictRequest) (*mlhubpb.PredictResponse, error) {
+	return s.predict(ctx, req)
+}
+
+// PredictStream implements MLHub gRPC PredictStream method, failed predictions
+// are reported in error field of response and do not terminate the stream
+func (s *grpcServer) PredictStream(stream mlhubpb.MLHub_PredictStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rsp, err := s.predict(stream.Context(), req)
+		if err != nil {
+			rsp = &mlhubpb.PredictResponse{Error: status.Convert(err).Message()}
+		}
+		if err := stream.Send(rsp); err != nil {
+			return err
+		}
+	}
+}
+
+// UploadBundle implements MLHub gRPC UploadBundle method, bundle chunks are
+// staged as resumable upload and then uploaded via common Upload function
+func (s *grpcServer) UploadBundle(stream mlhubpb.MLHub_UploadBundleServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	header := req.GetHeader()
+	if header == nil || header.GetRecord() == nil {
+		return status.Error(codes.InvalidArgument, "first message should provide ML model record")
+	}
+	rec, err := protoRecord(header.GetRecord())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	r, err := grpcRequest(stream.Context(), "POST", "/upload", "", nil)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	rec.UserName = requestUser(r)
+
+	// stage bundle chunks
+	uploadID := newID()
+	defer func() {
+		if err := removeStagedUpload(uploadID); err != nil {
+			log.Printf("WARNING: unable to remove staged upload %s, error %v", uploadID, err)
+		}
+	}()
+	var size int64
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		chunk := req.GetChunk()
+		upload, err := stageChunk(uploadID, rec.UserName, header.GetFileName(), size, bytes.NewReader(chunk))
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		size = upload.Size
+	}
+	bf, err := stagedBundle(uploadID, rec.UserName)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	rec.Bundle = bf.Name
+	// ML record is completed from MLflow or ONNX model of staged ML bundle
+	// the same way as for HTTP uploads
+	rec, _, err = mlflowRecord(rec, bf)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	rec, _, err = onnxRecord(rec, bf)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	rec, err = validateUpload(rec)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	grpcMetricsRecord(stream.Context(), rec)
+	event := uploadEvent(rec)
+	err = Upload(stream.Context(), rec, bf)
+	auditEvent(event, rec, r, grpcClientIP(stream.Context()), "", err)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return stream.SendAndClose(&mlhubpb.UploadBundleResponse{Record: recordProto(rec), Size: size})
+}
+
+// helper function to create MLHub gRPC server
+func newGRPCServer(config GRPCConfig) (*grpc.Server, error) {
+	opts := []grpc.ServerOption{
+		// metrics are collected only for authorized calls
+		grpc.ChainUnaryInterceptor(grpcUnaryTracing, grpcUnaryAuth, grpcUnaryMetrics),
+		grpc.ChainStreamInterceptor(grpcStreamTracing, grpcStreamAuth, grpcStreamMetrics),
+		grpc.MaxRecvMsgSize(config.MaxMessageSize),
+		grpc.MaxSendMsgSize(config.MaxMessageSize),
+	}
+	if config.ServerCert != "" && config.ServerKey != "" {
+		creds, err := credentials.NewServerTLSFromFile(config.ServerCert, config.ServerKey)
+		if err != nil {
+			return nil, fmt.Errorf("[MLHub.main.newGRPCServer] credentials.NewServerTLSFromFile error: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	srv := grpc.NewServer(opts...)
+	mlhubpb.RegisterMLHubServer(srv, &grpcServer{})
+	return srv, nil
+}
+
+// GRPCServer starts MLHub gRPC service on port defined in MLHub configuration
+func GRPCServer() {
+	config := HubConfig.GRPC
+	srv, err := newGRPCServer(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", config.Port))
+	if err != nil {
+		log.Fatalf("unable to listen on gRPC port %d, error %v", config.Port, err)
+	}
+	log.Printf("start gRPC server on port %d", config.Port)
+	if err := srv.Serve(lis); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -6,11 +6,17 @@ package main
 //
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	authz "github.com/CHESSComputing/golib/authz"
 	srvConfig "github.com/CHESSComputing/golib/config"
@@ -49,10 +55,12 @@ func PredictHandler(c *gin.Context) {
 		model := r.FormValue("model")
 		mlType := r.FormValue("type")
 		backend := r.FormValue("backend")
+		version := r.FormValue("version")
 		spec = Record{
 			Model:   model,
 			Type:    mlType,
 			Backend: backend,
+			Version: version,
 		}
 		r.Header.Set("Accept", "application/octet-stream")
 	} else {
@@ -65,17 +73,33 @@ func PredictHandler(c *gin.Context) {
 		}
 	}
 
-	rec, err := modelRecord(spec)
+	rec, routed, err := routedRecord(spec, r)
 	if err != nil {
 		rec := services.Response("MLHub", http.StatusBadRequest, services.GenericError, err)
 		c.JSON(http.StatusBadRequest, rec)
 		return
 	}
+	metricsRecord(c, rec)
 	if Verbose > 0 {
 		log.Printf("InferenceHandler found %+v", rec)
 	}
+	time0 := time.Now()
 	data, mtype, err := Predict(rec, r)
+	latency := time.Since(time0)
+	status := predictStatus(err)
+	if routed {
+		recordOutcome(rec, r, status, latency, err)
+	}
+	c.Header(VersionHeader, rec.Version)
 	if err == nil {
+		shadowPredict(rec, r, data, latency)
+		if recordProvenance(r) {
+			if pid, err := recordPrediction(rec, r, data); err == nil {
+				c.Header(ProvenanceHeader, pid)
+			} else {
+				log.Printf("ERROR: unable to record prediction provenance, error %v", err)
+			}
+		}
 		if mtype == "application/json" {
 			c.JSON(http.StatusOK, data)
 		} else {
@@ -83,8 +107,11 @@ func PredictHandler(c *gin.Context) {
 		}
 		return
 	}
-	resp := services.Response("MLHub", http.StatusBadRequest, services.PredictError, err)
-	c.JSON(http.StatusBadRequest, resp)
+	if status == http.StatusServiceUnavailable {
+		c.Header("Retry-After", strconv.Itoa(HubConfig.Health.Interval))
+	}
+	resp := services.Response("MLHub", status, services.PredictError, err)
+	c.JSON(status, resp)
 }
 
 // DownloadHandler handles download action of ML model from back-end server via
@@ -98,28 +125,35 @@ func DownloadHandler(c *gin.Context) {
 	}
 	model := doc.Name
 	mlType := c.Request.FormValue("type")
-	version := c.Request.FormValue("version")
+	version := resolveVersion(model, mlType, c.Request.FormValue("version"))
 	// check if record exist in MetaData database
+	spec := Record{Model: model, Type: mlType, Version: version}
 	records, err := metaRecords(model, mlType, version)
 	if err != nil {
+		auditRequest(c, AuditDownload, spec, "", err)
 		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
 		c.JSON(http.StatusBadRequest, rec)
 		return
 	}
 	if len(records) != 1 {
 		msg := fmt.Sprintf("Too many records for provide model=%s type=%s version=%s", model, mlType, version)
+		auditRequest(c, AuditDownload, spec, "", errors.New(msg))
 		rec := services.Response("MLHub", http.StatusBadRequest, services.GenericError, errors.New(msg))
 		c.JSON(http.StatusBadRequest, rec)
 		return
 	}
 	// form link to download model bundle file
 	rec := records[0]
+	metricsRecord(c, rec)
+	_, span := startSpan(c.Request.Context(), "findModelFile")
 	fname := findModelFile(rec.Bundle, mlType, version)
+	span.End()
 	bname := strings.Replace(fname, StorageDir, "", -1)
 	downloadURL := fmt.Sprintf("/bundles%s", bname)
 	if Verbose > 0 {
 		log.Println("download", downloadURL)
 	}
+	auditRequest(c, AuditDownload, rec, "", nil)
 	c.Redirect(http.StatusSeeOther, downloadURL)
 }
 
@@ -139,46 +173,34 @@ func UploadHandler(c *gin.Context) {
 	model := r.FormValue("model")
 	mlType := r.FormValue("type")
 	backend := r.FormValue("backend")
-	bundle := r.FormValue("file")
 	version := r.FormValue("version")
-	if version == "" {
-		version = "latest"
-	}
 	reference := r.FormValue("reference")
 	discipline := r.FormValue("discipline")
 	description := r.FormValue("description")
-	if mlType == "" || backend == "" || model == "" {
-		msg := "Unable to upload your ML model"
-		if mlType == "" {
-			msg += ", ML type parameter is empty"
-		} else if backend == "" {
-			msg += ", ML backend parameter is empty"
-		} else if model == "" {
-			msg += ", ML model parameter is empty"
-		}
-		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, errors.New(msg))
-		c.JSON(http.StatusBadRequest, rec)
-		return
-	}
 
 	if Verbose > 0 {
-		log.Printf("model=%v type=%v bundle=%v version=%v ref=%v dis=%v desc=%v", model, mlType, bundle, version, reference, discipline, description)
+		log.Printf("model=%v type=%v version=%v ref=%v dis=%v desc=%v", model, mlType, version, reference, discipline, description)
 	}
 
-	// get file name bundle
-	if bundle == "" {
-		// parse incoming HTTP request multipart form
-		err := r.ParseMultipartForm(32 << 20) // maxMemory
-		if err != nil {
-			rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, err)
-			c.JSON(http.StatusBadRequest, rec)
-			return
-		}
-		for _, vals := range r.MultipartForm.File {
-			for _, fh := range vals {
-				bundle = fh.Filename
-			}
+	// get ML bundle either from resumable upload or from HTTP request form
+	var bf BundleFile
+	var err error
+	uploadID := r.FormValue("upload")
+	if uploadID != "" {
+		// staged ML bundle is locked until it is uploaded and removed
+		defer lockUpload(uploadID)()
+		bf, err = stagedBundle(uploadID, requestUser(r))
+	} else {
+		bf, err = FormBundle(r)
+	}
+	if err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, errUploadOwner) {
+			code = http.StatusForbidden
 		}
+		rec := services.Response("MLHub", code, services.FormDataError, err)
+		c.JSON(code, rec)
+		return
 	}
 
 	// we got HTML form request
@@ -190,7 +212,38 @@ func UploadHandler(c *gin.Context) {
 		Description: description,
 		Discipline:  discipline,
 		Reference:   reference,
-		Bundle:      bundle,
+		Bundle:      bf.Name,
+	}
+	rec.Provenance, err = parseProvenance(r)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	rec.Conversion = parseConversion(r)
+	// MLflow model provides its type, framework version, signature and input
+	// example, and ML backend is chosen by its type unless it is provided
+	rec, mlflow, err := mlflowRecord(rec, bf)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	if mlflow && Verbose > 0 {
+		log.Printf("MLflow model %s type %s framework %s backend %s", rec.Model, rec.Type, rec.Framework, rec.Backend)
+	}
+	// ONNX model is validated and provides its inputs and outputs
+	rec, _, err = onnxRecord(rec, bf)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	rec, err = validateUpload(rec)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
 	}
 	token := authz.BearerToken(r)
 	claims, err := authz.TokenClaims(token, srvConfig.Config.Authz.ClientID)
@@ -201,25 +254,94 @@ func UploadHandler(c *gin.Context) {
 	}
 	rec.UserName = claims.CustomClaims.User
 
-	// perform upload action
-	err = Upload(rec, r)
+	// perform upload action, upload of existing version is audited as its update
+	metricsRecord(c, rec)
+	event := uploadEvent(rec)
+	err = Upload(r.Context(), rec, bf)
+	auditRequest(c, event, rec, "", err)
 	if err != nil {
 		rec := services.Response("MLHub", http.StatusBadRequest, services.UploadError, err)
 		c.JSON(http.StatusBadRequest, rec)
 		return
 	}
+	if uploadID != "" {
+		if err := removeStagedUpload(uploadID); err != nil {
+			log.Printf("WARNING: unable to remove staged upload %s, error %v", uploadID, err)
+		}
+	}
 	c.JSON(http.StatusOK, services.Response("MLHub", http.StatusOK, 0, nil))
 }
 
-// GetHandler handles GET HTTP requests, this request will
-// delete ML model in backend and MetaData database
+// helper function to get audit event of ML model upload, i.e. update if
+// version of ML model already exists and upload otherwise
+func uploadEvent(rec Record) string {
+	if records, err := metaRecords(rec.Model, rec.Type, rec.Version); err == nil && len(records) > 0 {
+		return AuditUpdate
+	}
+	return AuditUpload
+}
+
+// helper function to check mandatory parameters of ML model upload
+func checkUploadSpec(model, mlType, backend string) error {
+	if mlType == "" || backend == "" || model == "" {
+		msg := "Unable to upload your ML model"
+		if mlType == "" {
+			msg += ", ML type parameter is empty"
+		} else if backend == "" {
+			msg += ", ML backend parameter is empty"
+		} else if model == "" {
+			msg += ", ML model parameter is empty"
+		}
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// helper function to validate ML record of upload request, it returns
+// record with default version and canonical discipline name
+func validateUpload(rec Record) (Record, error) {
+	if err := checkUploadSpec(rec.Model, rec.Type, rec.Backend); err != nil {
+		return rec, err
+	}
+	if rec.Version == "" {
+		rec.Version = "latest"
+	}
+	discipline, err := validateDiscipline(rec.Discipline)
+	if err != nil {
+		return rec, err
+	}
+	rec.Discipline = discipline
+	if err := validateProvenance(rec.Provenance); err != nil {
+		return rec, err
+	}
+	rec.Conversion, err = validateConversion(rec)
+	if err != nil {
+		return rec, err
+	}
+	return rec, nil
+}
+
+// DeleteHandler handles DELETE HTTP requests, this request moves ML model to
+// trash, i.e. it undeploys ML model from its backend and keeps its bundle and
+// meta-data until ML model is restored or purged. The purge=true query
+// parameter deletes ML model permanently
 func DeleteHandler(c *gin.Context) {
 	// parse input JSON payload
 	var spec Record
-	c.BindJSON(&spec)
+	if err := c.BindJSON(&spec); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
 	model := spec.Model
 	mlType := spec.Type
 	version := spec.Version
+	if model == "" {
+		msg := "HTTP request does not provide ML model name"
+		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, errors.New(msg))
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
 	if version == "" {
 		msg := "HTTP request does not provide ML model version"
 		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, errors.New(msg))
@@ -232,8 +354,12 @@ func DeleteHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, rec)
 		return
 	}
+	if !ownerRequest(c, model, mlType, version) {
+		return
+	}
+	purge := c.Query("purge") == "true"
 	if Verbose > 0 {
-		log.Printf("request to delete ML model %s type %s version %s", model, mlType, version)
+		log.Printf("request to delete ML model %s type %s version %s purge %v", model, mlType, version, purge)
 	}
 	records, err := metaRecords(model, mlType, version)
 	if err != nil {
@@ -242,28 +368,116 @@ func DeleteHandler(c *gin.Context) {
 		return
 	}
 	for _, rec := range records {
-		log.Printf("Remove %+v", rec)
-		err = removeBundle(rec)
+		metricsRecord(c, rec)
+		err = trashModel(c.Request.Context(), rec)
+		if err == nil && purge {
+			err = purgeModel(rec)
+		}
+		details := "trash"
+		if purge {
+			details = "purge"
+		}
+		auditRequest(c, AuditDelete, rec, details, err)
 		if err != nil {
 			rec := services.Response("MLHub", http.StatusInternalServerError, services.StorageError, err)
 			c.JSON(http.StatusInternalServerError, rec)
 			return
 		}
-		spec := map[string]any{"model": model, "type": mlType, "version": version}
-		err := metaRemove(spec)
-		if err != nil {
-			rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
-			c.JSON(http.StatusInternalServerError, rec)
-			return
+		notifyWebhooks(AuditDelete, rec, details)
+	}
+	c.JSON(http.StatusOK, services.Response("MLHub", http.StatusOK, 0, nil))
+}
+
+// TrashHandler provides ML models in trash via /trash?model=mnist&type=TensorFlow&idx=0&limit=10
+func TrashHandler(c *gin.Context) {
+	spec := map[string]any{}
+	for _, key := range []string{"model", "type", "version"} {
+		if val := c.Request.FormValue(key); val != "" {
+			spec[key] = val
 		}
 	}
+	idx, limit := pagination(c.Request)
+	records, err := trashRecords(spec, idx, limit)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+// helper function to get ML model version of trash request, it writes HTTP
+// response and returns false if request does not provide model, type and version
+func trashSpec(c *gin.Context) (Record, bool) {
+	var spec Record
+	if err := c.BindJSON(&spec); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return spec, false
+	}
+	if spec.Model == "" || spec.Type == "" || spec.Version == "" {
+		msg := "HTTP request should provide ML model name, type and version"
+		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, errors.New(msg))
+		c.JSON(http.StatusBadRequest, rec)
+		return spec, false
+	}
+	return spec, true
+}
+
+// RestoreHandler restores ML model from trash and redeploys it to its
+// backend, the HTTP request should provide JSON record
+// {"model": "mnist", "type": "TensorFlow", "version": "v1"}
+func RestoreHandler(c *gin.Context) {
+	spec, ok := trashSpec(c)
+	if !ok {
+		return
+	}
+	if _, ok := trashOwnerRequest(c, spec); !ok {
+		return
+	}
+	rec, err := restoreModel(c.Request.Context(), spec.Model, spec.Type, spec.Version)
+	auditRequest(c, AuditRestore, spec, "", err)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.StorageError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	metricsRecord(c, rec)
+	notifyWebhooks(AuditRestore, rec, "")
+	c.JSON(http.StatusOK, services.Response("MLHub", http.StatusOK, 0, nil))
+}
+
+// PurgeHandler permanently removes ML model from trash, the HTTP request
+// should provide JSON record {"model": "mnist", "type": "TensorFlow", "version": "v1"}
+func PurgeHandler(c *gin.Context) {
+	spec, ok := trashSpec(c)
+	if !ok {
+		return
+	}
+	rec, ok := trashOwnerRequest(c, spec)
+	if !ok {
+		return
+	}
+	err := purgeModel(rec)
+	auditRequest(c, AuditPurge, spec, "", err)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.StorageError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
 	c.JSON(http.StatusOK, services.Response("MLHub", http.StatusOK, 0, nil))
 }
 
-// ModelsHandler provides information about registered ML models
+// ModelsHandler provides information about registered ML models via
+// /models?q=query&type=TensorFlow&backend=TFaaS&discipline=physics&user=name&idx=0&limit=10
+// It renders ML models catalog page for HTML requests
 func ModelsHandler(c *gin.Context) {
-	// TODO: Add parameters for /models endpoint, eg q=query, limit, idx for pagination
-	mRecords, err := metaRecords("", "", "")
+	if htmlRequest(c.Request) {
+		modelsPage(c, "ML models")
+		return
+	}
+	idx, limit := pagination(c.Request)
+	mRecords, err := metaPage(modelsSpec(c.Request), idx, limit)
 	if err != nil {
 		msg := fmt.Sprintf("unable to get meta-data, error=%v", err)
 		rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, errors.New(msg))
@@ -288,8 +502,819 @@ func DocsHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, rec)
 		return
 	}
-	tmpl := make(map[string]any)
-	header := server.TmplPage(StaticFs, "header.tmpl", tmpl)
-	footer := server.TmplPage(StaticFs, "footer.tmpl", tmpl)
-	c.Data(http.StatusOK, "application/html", []byte(header+content+footer))
+	tmpl := tmplData()
+	tmpl["Content"] = template.HTML(content)
+	renderPage(c, "content.tmpl", tmpl)
+}
+
+// helper function to get ML meta record for /model/:name?type=TensorFlow&version=123
+// HTTP requests
+func pageRecord(c *gin.Context) (Record, int, error) {
+	var doc DocParams
+	if err := c.ShouldBindUri(&doc); err != nil {
+		return Record{}, services.BindError, err
+	}
+	spec := Record{
+		Model:   doc.Name,
+		Type:    c.Request.FormValue("type"),
+		Version: c.Request.FormValue("version"),
+	}
+	rec, err := modelRecord(c.Request.Context(), spec)
+	if err != nil {
+		return rec, services.MetaError, err
+	}
+	return rec, 0, nil
+}
+
+// ModelPageHandler provides HTML page of ML model via
+// /model/:name?type=TensorFlow&version=123
+// The page embeds schema.org JSON-LD of ML model for web crawlers, while
+// non HTML requests get ML meta record
+func ModelPageHandler(c *gin.Context) {
+	rec, code, err := pageRecord(c)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errNoRecords) {
+			status = http.StatusNotFound
+		}
+		resp := services.Response("MLHub", status, code, err)
+		c.JSON(status, resp)
+		return
+	}
+	if !htmlRequest(c.Request) {
+		c.JSON(http.StatusOK, rec)
+		return
+	}
+	data, err := json.Marshal(JSONLD(rec, baseURL(c.Request)))
+	if err != nil {
+		resp := services.Response("MLHub", http.StatusInternalServerError, services.GenericError, err)
+		c.JSON(http.StatusInternalServerError, resp)
+		return
+	}
+	tmpl := tmplData()
+	tmpl["Model"] = rec.Model
+	tmpl["Type"] = rec.Type
+	tmpl["Framework"] = rec.Framework
+	tmpl["Backend"] = rec.Backend
+	tmpl["Version"] = rec.Version
+	tmpl["Description"] = rec.Description
+	tmpl["Discipline"] = rec.Discipline
+	tmpl["Reference"] = rec.Reference
+	tmpl["Bundle"] = rec.Bundle
+	tmpl["UserName"] = rec.UserName
+	tmpl["Origin"] = rec.Origin
+	tmpl["Conversion"] = rec.Conversion
+	tmpl["JSONLD"] = template.JS(data)
+	renderPage(c, "model.tmpl", tmpl)
+}
+
+// JSONLDHandler provides schema.org JSON-LD representation of ML model via
+// /model/:name/jsonld?type=TensorFlow&version=123
+func JSONLDHandler(c *gin.Context) {
+	rec, code, err := pageRecord(c)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errNoRecords) {
+			status = http.StatusNotFound
+		}
+		resp := services.Response("MLHub", status, code, err)
+		c.JSON(status, resp)
+		return
+	}
+	data, err := json.Marshal(JSONLD(rec, baseURL(c.Request)))
+	if err != nil {
+		resp := services.Response("MLHub", http.StatusInternalServerError, services.GenericError, err)
+		c.JSON(http.StatusInternalServerError, resp)
+		return
+	}
+	c.Data(http.StatusOK, "application/ld+json", data)
+}
+
+// ROCrateHandler provides RO-Crate zip archive of ML model via
+// /model/:name/rocrate?type=TensorFlow&version=123
+func ROCrateHandler(c *gin.Context) {
+	rec, code, err := pageRecord(c)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errNoRecords) {
+			status = http.StatusNotFound
+		}
+		resp := services.Response("MLHub", status, code, err)
+		c.JSON(status, resp)
+		return
+	}
+	fname := fmt.Sprintf("%s-%s-%s.crate.zip", rec.Model, rec.Type, rec.Version)
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fname))
+	if err := WriteROCrate(c.Writer, rec, baseURL(c.Request)); err != nil {
+		log.Printf("unable to write RO-Crate for %+v, error %v", rec, err)
+		c.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
+
+// DatasetLineageHandler provides ML models trained or evaluated on given
+// FOXDEN dataset via /lineage/dataset?did=/beamline=3a/btr=123/cycle=2024-1
+func DatasetLineageHandler(c *gin.Context) {
+	did := c.Request.FormValue("did")
+	if did == "" {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, errors.New("did parameter is empty"))
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	records, err := datasetModels(did)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+// ModelLineageHandler provides lineage of ML model, i.e. ML models it was
+// derived from and ML models derived from it, via
+// /lineage/model/:name?type=TensorFlow&version=123
+func ModelLineageHandler(c *gin.Context) {
+	rec, code, err := pageRecord(c)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errNoRecords) {
+			status = http.StatusNotFound
+		}
+		resp := services.Response("MLHub", status, code, err)
+		c.JSON(status, resp)
+		return
+	}
+	lineage, err := modelLineage(rec)
+	if err != nil {
+		resp := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
+		c.JSON(http.StatusInternalServerError, resp)
+		return
+	}
+	c.JSON(http.StatusOK, lineage)
+}
+
+// ProvenanceHandler provides provenance record of ML prediction via
+// /provenance/:name where name is provenance ID returned by /predict
+func ProvenanceHandler(c *gin.Context) {
+	var doc DocParams
+	if err := c.ShouldBindUri(&doc); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	prec, err := predictionRecord(doc.Name)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusNotFound, services.MetaError, err)
+		c.JSON(http.StatusNotFound, rec)
+		return
+	}
+	c.JSON(http.StatusOK, prec)
+}
+
+// DomainsHandler provides scientific domains of ML models along with
+// number of ML models in every domain. It renders domains page for HTML requests
+func DomainsHandler(c *gin.Context) {
+	nodes, err := domainTree()
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return
+	}
+	if !htmlRequest(c.Request) {
+		c.JSON(http.StatusOK, nodes)
+		return
+	}
+	fname := fmt.Sprintf("%s/md/domains.md", StaticDir)
+	content, err := server.MDToHTML(StaticFs, fname)
+	if err != nil {
+		log.Printf("unable to render %s, error %v", fname, err)
+	}
+	tmpl := tmplData()
+	tmpl["Content"] = template.HTML(content)
+	tmpl["Domains"] = nodes
+	renderPage(c, "domains.tmpl", tmpl)
+}
+
+// DomainUpsertHandler creates or updates scientific domain, the HTTP request
+// should provide JSON record {"name": "Physics", "parent": "", "description": "..."}
+func DomainUpsertHandler(c *gin.Context) {
+	if !adminRequest(c) {
+		return
+	}
+	var domain Domain
+	if err := c.BindJSON(&domain); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	if err := domainUpsert(domain); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	c.JSON(http.StatusOK, services.Response("MLHub", http.StatusOK, 0, nil))
+}
+
+// DomainDeleteHandler removes scientific domain via /domains/:name
+func DomainDeleteHandler(c *gin.Context) {
+	if !adminRequest(c) {
+		return
+	}
+	var doc DocParams
+	if err := c.ShouldBindUri(&doc); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	if err := domainRemove(doc.Name); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	c.JSON(http.StatusOK, services.Response("MLHub", http.StatusOK, 0, nil))
+}
+
+// UploadStatusHandler provides status of resumable upload via /uploads/:name
+// where name is upload ID chosen by client
+func UploadStatusHandler(c *gin.Context) {
+	var doc DocParams
+	if err := c.ShouldBindUri(&doc); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	status, err := stagedUpload(doc.Name, requestUser(c.Request))
+	if err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, errUploadOwner) {
+			code = http.StatusForbidden
+		}
+		rec := services.Response("MLHub", code, services.UploadError, err)
+		c.JSON(code, rec)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// UploadChunkHandler appends chunk of ML bundle to resumable upload via
+// /uploads/:name?file=model.tar.gz&offset=123 where name is upload ID chosen
+// by client and offset is number of bytes uploaded so far. Once all chunks are
+// uploaded the client should call /upload with upload=<ID> form value
+func UploadChunkHandler(c *gin.Context) {
+	var doc DocParams
+	if err := c.ShouldBindUri(&doc); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	status, err := stageChunk(doc.Name, requestUser(c.Request), c.Query("file"), offset, c.Request.Body)
+	if err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, errUploadOffset) {
+			code = http.StatusConflict
+		} else if errors.Is(err, errUploadOwner) {
+			code = http.StatusForbidden
+		}
+		rec := services.Response("MLHub", code, services.UploadError, err)
+		c.JSON(code, rec)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// PromoteHandler assigns alias to ML model version, the HTTP request should
+// provide JSON record {"model": "mnist", "type": "TensorFlow", "version": "v1", "alias": "production"}
+func PromoteHandler(c *gin.Context) {
+	var alias Alias
+	if err := c.BindJSON(&alias); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	if !ownerRequest(c, alias.Model, alias.Type, alias.Version) {
+		return
+	}
+	err := promote(alias)
+	rec := Record{Model: alias.Model, Type: alias.Type, Version: alias.Version}
+	auditRequest(c, AuditPromote, rec, "alias="+alias.Alias, err)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	c.JSON(http.StatusOK, services.Response("MLHub", http.StatusOK, 0, nil))
+}
+
+// RoutingHandler provides routing rule of ML model via /routing/:name?type=TensorFlow
+func RoutingHandler(c *gin.Context) {
+	var doc DocParams
+	if err := c.ShouldBindUri(&doc); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	rule, err := routingRule(doc.Name, c.Request.FormValue("type"))
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// RoutingReportHandler provides evaluation of routing rule arms of ML model
+// via /routing/:name/report?type=TensorFlow
+func RoutingReportHandler(c *gin.Context) {
+	var doc DocParams
+	if err := c.ShouldBindUri(&doc); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	rule, err := routingRule(doc.Name, c.Request.FormValue("type"))
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	reports, err := routingReport(rule)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// RoutingUpsertHandler sets routing rule of ML model, the HTTP request should
+// provide JSON record {"model": "mnist", "type": "TensorFlow", "sticky": "caller",
+// "arms": [{"version": "production", "weight": 90}, {"version": "v2", "weight": 10}]}
+func RoutingUpsertHandler(c *gin.Context) {
+	var rule RoutingRule
+	if err := c.BindJSON(&rule); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	if !ownerRequest(c, rule.Model, rule.Type, "") {
+		return
+	}
+	if err := setRoutingRule(rule); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	c.JSON(http.StatusOK, services.Response("MLHub", http.StatusOK, 0, nil))
+}
+
+// RoutingDeleteHandler removes routing rule of ML model via /routing/:name?type=TensorFlow
+func RoutingDeleteHandler(c *gin.Context) {
+	var doc DocParams
+	if err := c.ShouldBindUri(&doc); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	mlType := c.Request.FormValue("type")
+	if mlType == "" {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.GenericError, errors.New("ML model type is required"))
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	if !ownerRequest(c, doc.Name, mlType, "") {
+		return
+	}
+	if err := removeRoutingRule(doc.Name, mlType); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	c.JSON(http.StatusOK, services.Response("MLHub", http.StatusOK, 0, nil))
+}
+
+// ShadowHandler provides shadow deployment of ML model via /shadow/:name?type=TensorFlow
+func ShadowHandler(c *gin.Context) {
+	var doc DocParams
+	if err := c.ShouldBindUri(&doc); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	rule, err := shadowRule(doc.Name, c.Request.FormValue("type"))
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// ShadowReportHandler provides disagreement rate and latency differences of
+// primary and shadow versions of ML model via /shadow/:name/report?type=TensorFlow
+func ShadowReportHandler(c *gin.Context) {
+	var doc DocParams
+	if err := c.ShouldBindUri(&doc); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	reports, err := shadowReport(doc.Name, c.Request.FormValue("type"))
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// ShadowUpsertHandler sets shadow deployment of ML model, the HTTP request should
+// provide JSON record {"model": "mnist", "type": "TensorFlow", "version": "v2", "sample": 0.5}
+func ShadowUpsertHandler(c *gin.Context) {
+	var rule ShadowRule
+	if err := c.BindJSON(&rule); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	if !ownerRequest(c, rule.Model, rule.Type, "") {
+		return
+	}
+	if err := setShadowRule(rule); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	c.JSON(http.StatusOK, services.Response("MLHub", http.StatusOK, 0, nil))
+}
+
+// ShadowDeleteHandler removes shadow deployment of ML model via /shadow/:name?type=TensorFlow
+func ShadowDeleteHandler(c *gin.Context) {
+	var doc DocParams
+	if err := c.ShouldBindUri(&doc); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	mlType := c.Request.FormValue("type")
+	if mlType == "" {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.GenericError, errors.New("ML model type is required"))
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	if !ownerRequest(c, doc.Name, mlType, "") {
+		return
+	}
+	if err := removeShadowRule(doc.Name, mlType); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	c.JSON(http.StatusOK, services.Response("MLHub", http.StatusOK, 0, nil))
+}
+
+// AuditHandler provides audit events of ML model lifecycle to MLHub
+// administrators via /audit?event=delete&user=name&model=mnist&since=123&idx=0&limit=10
+func AuditHandler(c *gin.Context) {
+	if !adminRequest(c) {
+		return
+	}
+	spec, err := auditSpec(c.Request)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	idx, limit := pagination(c.Request)
+	events, err := auditRecords(spec, idx, limit)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// AuditExportHandler exports audit events of ML model lifecycle as JSON Lines
+// via /audit/export, it accepts the same query parameters as /audit
+func AuditExportHandler(c *gin.Context) {
+	if !adminRequest(c) {
+		return
+	}
+	spec, err := auditSpec(c.Request)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	idx, limit := pagination(c.Request)
+	events, err := auditRecords(spec, idx, limit)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", "attachment; filename=audit.jsonl")
+	c.Status(http.StatusOK)
+	// json encoder terminates every record with new line as JSON Lines requires
+	encoder := json.NewEncoder(c.Writer)
+	for _, evt := range events {
+		if err := encoder.Encode(evt); err != nil {
+			log.Printf("ERROR: unable to export audit event %+v, error %v", evt, err)
+			return
+		}
+	}
+}
+
+// WebhooksHandler provides webhooks of the user via /webhooks, MLHub
+// administrators get all webhooks. Secrets of webhooks are never provided
+func WebhooksHandler(c *gin.Context) {
+	spec := map[string]any{}
+	if !isAdmin(c.Request) {
+		spec["user"] = requestUser(c.Request)
+	}
+	hooks, err := webhooks(spec)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return
+	}
+	for idx := range hooks {
+		hooks[idx].Secret = ""
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+// WebhookUpsertHandler creates webhook, the HTTP request should provide JSON
+// record {"url": "https://ci.host/hook", "secret": "...", "events": ["upload", "promote"], "model": "mnist"}
+// and the response provides webhook with its ID
+func WebhookUpsertHandler(c *gin.Context) {
+	var hook Webhook
+	if err := c.BindJSON(&hook); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	if !isAdmin(c.Request) && !webhookHostAllowed(hook.URL) {
+		msg := fmt.Sprintf("webhook URL '%s' targets host which is not allowed by MLHub configuration", hook.URL)
+		rec := services.Response("MLHub", http.StatusForbidden, services.AuthError, errors.New(msg))
+		c.JSON(http.StatusForbidden, rec)
+		return
+	}
+	hook.User = requestUser(c.Request)
+	hook, err := addWebhook(hook)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.MetaError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	hook.Secret = ""
+	c.JSON(http.StatusOK, hook)
+}
+
+// helper function to get webhook of HTTP request which belongs to the user or
+// user is MLHub administrator, it writes HTTP response and returns false otherwise
+func requestWebhook(c *gin.Context) (Webhook, bool) {
+	var hook Webhook
+	var doc DocParams
+	if err := c.ShouldBindUri(&doc); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return hook, false
+	}
+	hooks, err := webhooks(map[string]any{"id": doc.Name})
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return hook, false
+	}
+	if len(hooks) != 1 {
+		msg := fmt.Sprintf("webhook %s not found", doc.Name)
+		rec := services.Response("MLHub", http.StatusNotFound, services.GenericError, errors.New(msg))
+		c.JSON(http.StatusNotFound, rec)
+		return hook, false
+	}
+	hook = hooks[0]
+	if hook.User != requestUser(c.Request) && !isAdmin(c.Request) {
+		msg := fmt.Sprintf("webhook %s belongs to other user", doc.Name)
+		rec := services.Response("MLHub", http.StatusForbidden, services.AuthError, errors.New(msg))
+		c.JSON(http.StatusForbidden, rec)
+		return hook, false
+	}
+	return hook, true
+}
+
+// WebhookDeleteHandler removes webhook via /webhooks/:name where name is webhook ID
+func WebhookDeleteHandler(c *gin.Context) {
+	hook, ok := requestWebhook(c)
+	if !ok {
+		return
+	}
+	if err := removeWebhook(hook.ID); err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return
+	}
+	c.JSON(http.StatusOK, services.Response("MLHub", http.StatusOK, 0, nil))
+}
+
+// WebhookDeliveriesHandler provides delivery log of webhook via
+// /webhooks/:name/deliveries?idx=0&limit=10 where name is webhook ID
+func WebhookDeliveriesHandler(c *gin.Context) {
+	hook, ok := requestWebhook(c)
+	if !ok {
+		return
+	}
+	idx, limit := pagination(c.Request)
+	deliveries, err := webhookDeliveries(hook.ID, idx, limit)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// ConsistencyHandler provides the last consistency report of MLHub storage,
+// meta-data and ML backends via /consistency
+func ConsistencyHandler(c *gin.Context) {
+	if !adminRequest(c) {
+		return
+	}
+	report, ok := lastConsistencyReport()
+	if !ok {
+		rec := services.Response("MLHub", http.StatusNotFound, services.GenericError, errors.New("consistency check has not been performed yet"))
+		c.JSON(http.StatusNotFound, rec)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ConsistencyCheckHandler checks consistency of MLHub storage, meta-data and
+// ML backends via /consistency?policy=repair, policy is either report (default)
+// or repair
+func ConsistencyCheckHandler(c *gin.Context) {
+	if !adminRequest(c) {
+		return
+	}
+	policy := c.Query("policy")
+	if policy == "" {
+		policy = ReportPolicy
+	}
+	report, err := checkConsistency(c.Request.Context(), policy)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.GenericError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ExportHandler exports all active ML models and their aliases into MLHub
+// backup archive via /export
+func ExportHandler(c *gin.Context) {
+	if !adminRequest(c) {
+		return
+	}
+	fname := fmt.Sprintf("mlhub-%s.zip", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fname))
+	if err := WriteBackup(c.Writer, baseURL(c.Request)); err != nil {
+		log.Printf("ERROR: unable to export MLHub backup, error %v", err)
+		if c.Writer.Written() {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.Header("Content-Disposition", "")
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.StorageError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+	}
+}
+
+// ImportHandler imports ML models and aliases from MLHub backup archive
+// provided as HTTP request body via /import?conflict=overwrite, conflict
+// policy is either skip (default) or overwrite
+func ImportHandler(c *gin.Context) {
+	if !adminRequest(c) {
+		return
+	}
+	conflict := c.Query("conflict")
+	if conflict == "" {
+		conflict = SkipConflicts
+	}
+	// zip archive requires random access, therefore we keep it in temporary file
+	file, err := os.CreateTemp("", "mlhub-import-*.zip")
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.StorageError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return
+	}
+	defer os.Remove(file.Name())
+	_, err = io.Copy(file, c.Request.Body)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	report, err := importBackup(c.Request.Context(), file.Name(), conflict)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.GenericError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	for _, result := range report.Results {
+		if result.Alias != "" || result.Status == ImportSkipped {
+			continue
+		}
+		rec := Record{Model: result.Model, Type: result.Type, Version: result.Version}
+		var ierr error
+		if result.Error != "" {
+			ierr = errors.New(result.Error)
+		}
+		auditRequest(c, AuditUpload, rec, "import", ierr)
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// FederationHandler provides mirroring state of configured remote MLHub
+// instances via /federation
+func FederationHandler(c *gin.Context) {
+	if !adminRequest(c) {
+		return
+	}
+	states, err := mirrors()
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.MetaError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return
+	}
+	c.JSON(http.StatusOK, states)
+}
+
+// FederationPullHandler pulls ML models changed since the last pull from
+// remote MLHub via /federation?remote=name, all configured remote MLHub
+// instances are pulled if remote is not provided
+func FederationPullHandler(c *gin.Context) {
+	if !adminRequest(c) {
+		return
+	}
+	remotes := HubConfig.Federation.Remotes
+	if name := c.Query("remote"); name != "" {
+		remote, err := remoteConfig(name)
+		if err != nil {
+			rec := services.Response("MLHub", http.StatusBadRequest, services.FormDataError, err)
+			c.JSON(http.StatusBadRequest, rec)
+			return
+		}
+		remotes = []RemoteConfig{remote}
+	}
+	var reports []MirrorReport
+	for _, remote := range remotes {
+		report, err := pullRemote(c.Request.Context(), remote)
+		if err != nil {
+			rec := services.Response("MLHub", http.StatusBadRequest, services.GenericError, err)
+			c.JSON(http.StatusBadRequest, rec)
+			return
+		}
+		reports = append(reports, report)
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// HFImportHandler imports ML model from Hugging Face-style repository via
+// /import/huggingface, the HTTP request should provide JSON record
+// {"repo": "org/name", "revision": "main", "model": "name"}. Import from local
+// directory of MLHub server requires MLHub administrator privileges
+func HFImportHandler(c *gin.Context) {
+	var imp HFImport
+	if err := c.BindJSON(&imp); err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.BindError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	if imp.Dir != "" && !adminRequest(c) {
+		return
+	}
+	rec, err := importHF(c.Request.Context(), imp, requestUser(c.Request))
+	details := "huggingface repo=" + imp.Repo
+	if imp.Dir != "" {
+		details = "huggingface dir=" + imp.Dir
+	}
+	auditRequest(c, AuditUpload, rec, details, err)
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusBadRequest, services.UploadError, err)
+		c.JSON(http.StatusBadRequest, rec)
+		return
+	}
+	metricsRecord(c, rec)
+	c.JSON(http.StatusOK, rec)
 }
@@ -7,6 +7,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,21 +21,41 @@ import (
 	"time"
 
 	srvConfig "github.com/CHESSComputing/golib/config"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Predict function fetches prediction for given uri, model and client's
 // HTTP request. Code is based on the following example:
 // https://golangbyexample.com/http-mutipart-form-body-golang/
 func Predict(rec Record, r *http.Request) ([]byte, string, error) {
+	ctx, span := startSpan(r.Context(), "Predict", recordAttributes(rec)...)
+	data, mtype, err := predict(rec, r.WithContext(ctx))
+	endSpan(span, err)
+	return data, mtype, err
+}
+
+// helper function to fetch prediction from replica of ML backend
+func predict(rec Record, r *http.Request) ([]byte, string, error) {
 	mtype := ""
 	log.Printf("search ML backend for record: %+v", rec)
 	backend, err := mlBackend(rec.Backend, rec.Type)
 	if err != nil {
-		return []byte{}, mtype, fmt.Errorf("[MLHub.main.Predict] mlBackend error: %w", err)
+		return []byte{}, mtype, fmt.Errorf("[MLHub.main.predict] mlBackend error: %w", err)
 	}
 	if Verbose > 0 {
 		log.Printf("found ML backend %+v", backend)
 	}
+	_, span := startSpan(r.Context(), "selectReplica")
+	backend, done, err := selectReplica(backend, rec)
+	span.SetAttributes(attribute.String("mlhub.replica", backend.URI))
+	endSpan(span, err)
+	if err != nil {
+		return []byte{}, mtype, fmt.Errorf("[MLHub.main.predict] selectReplica error: %w", err)
+	}
+	defer done()
+	if Verbose > 0 {
+		log.Printf("selected ML backend replica %s", backend.URI)
+	}
 	uri := backend.URI
 	for _, rec := range backend.Apis {
 		if rec.Name == "predict" {
@@ -45,14 +66,22 @@ func Predict(rec Record, r *http.Request) ([]byte, string, error) {
 			uri = fmt.Sprintf("%s/%s", backend.URI, rec.Endpoint)
 		}
 	}
-	if r.Header.Get("Accept") == "application/json" {
-		return PredictJSONInput(uri, rec, r)
+	var data []byte
+	if rec.Type == "ONNX" {
+		// ONNX models are served via Open Inference Protocol
+		data, mtype, err = PredictOIP(backend.URI, rec, r)
+	} else if r.Header.Get("Accept") == "application/json" {
+		data, mtype, err = PredictJSONInput(uri, rec, r)
 	} else if r.Header.Get("Accept") == "application/octet-stream" {
-		return PredictMultipart(uri, rec, r)
+		data, mtype, err = PredictMultipart(uri, rec, r)
 	} else {
 		msg := fmt.Sprintf("Unsupported mtime '%s' for uri %s", r.Header.Get("Accept"), uri)
 		return []byte{}, mtype, errors.New(msg)
 	}
+	if err != nil {
+		backendFailure(backend, err)
+	}
+	return data, mtype, err
 }
 
 func PredictJSONInput(uri string, rec Record, r *http.Request) ([]byte, string, error) {
@@ -67,26 +96,28 @@ func PredictJSONInput(uri string, rec Record, r *http.Request) ([]byte, string,
 	}
 
 	// form HTTP request
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
+	client := httpClient(rec.Backend)
 	if Verbose > 0 {
 		log.Printf("POST request to %s with body\n%v", uri, string(data))
 	}
-	req, err := http.NewRequest("POST", uri, bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(r.Context(), "POST", uri, bytes.NewReader(data))
 	if err != nil {
 		return data, mtype, fmt.Errorf("[MLHub.main.PredictJSONInput] http.NewRequest error: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	rsp, err := client.Do(req)
+	// predictions have no side effects and therefore can be retried
+	rsp, err := client.Do(req, true)
+	if err != nil {
+		return []byte{}, mtype, fmt.Errorf("[MLHub.main.PredictJSONInput] client.Do error: %w", err)
+	}
+	defer rsp.Body.Close()
 	if rsp.StatusCode != http.StatusOK {
 		msg := fmt.Sprintf("request to %s failed with response code: %d", rec.Backend, rsp.StatusCode)
-		log.Println(msg, "error", err)
+		log.Println(msg)
 		return []byte{}, mtype, errors.New(msg)
 	}
 	mtype = rsp.Header.Get("Content-type")
-	defer rsp.Body.Close()
 	data, err = io.ReadAll(rsp.Body)
 	if Verbose > 1 {
 		log.Printf("backend %s return %s error %v", rec.Backend, string(data), err)
@@ -99,8 +130,13 @@ func PredictJSONInput(uri string, rec Record, r *http.Request) ([]byte, string,
 
 func PredictMultipart(uri string, rec Record, r *http.Request) ([]byte, string, error) {
 	mtype := ""
-	// parse incoming HTTP request multipart form
+	// parse incoming HTTP request multipart form and re-encode it for ML backend
+	_, span := startSpan(r.Context(), "PredictMultipart.encode")
 	err := r.ParseMultipartForm(32 << 20) // maxMemory
+	if err != nil {
+		endSpan(span, err)
+		return []byte{}, mtype, fmt.Errorf("[MLHub.main.PredictMultipart] r.ParseMultipartForm error: %w", err)
+	}
 
 	// new multipart writer.
 	body := &bytes.Buffer{}
@@ -137,6 +173,8 @@ func PredictMultipart(uri string, rec Record, r *http.Request) ([]byte, string,
 		}
 	}
 	writer.Close()
+	span.SetAttributes(attribute.Int("mlhub.body.size", body.Len()))
+	span.End()
 
 	// for TFaaS we need additional end-point path if we query image prediction
 	if r.FormValue("name") != "image" && rec.Type == "TensorFlow" {
@@ -148,23 +186,24 @@ func PredictMultipart(uri string, rec Record, r *http.Request) ([]byte, string,
 
 	// form HTTP request
 	var data []byte
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
+	client := httpClient(rec.Backend)
 	if Verbose > 0 {
 		log.Printf("POST request to %s with body\n%v", uri, string(body.Bytes()))
 	}
-	req, err := http.NewRequest("POST", uri, bytes.NewReader(body.Bytes()))
+	req, err := http.NewRequestWithContext(r.Context(), "POST", uri, bytes.NewReader(body.Bytes()))
 	if err != nil {
 		return data, mtype, fmt.Errorf("[MLHub.main.PredictMultipart] http.NewRequest error: %w", err)
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	rsp, err := client.Do(req)
+	rsp, err := client.Do(req, true)
+	if err != nil {
+		return data, mtype, fmt.Errorf("[MLHub.main.PredictMultipart] client.Do error: %w", err)
+	}
+	defer rsp.Body.Close()
 	if rsp.StatusCode != http.StatusOK {
 		log.Printf("Request failed with response code: %d", rsp.StatusCode)
 	}
 	mtype = rsp.Header.Get("Content-type")
-	defer rsp.Body.Close()
 	data, err = io.ReadAll(rsp.Body)
 	if err != nil {
 		return data, mtype, fmt.Errorf("[MLHub.main.PredictMultipart] io.ReadAll error: %w", err)
@@ -172,21 +211,87 @@ func PredictMultipart(uri string, rec Record, r *http.Request) ([]byte, string,
 	return data, mtype, nil
 }
 
+// BundleFile represents ML bundle file provided by client
+type BundleFile struct {
+	Name string                        // bundle file name
+	Open func() (io.ReadCloser, error) // function to open bundle content
+}
+
+// FormBundle returns ML bundle file provided in file field of HTTP request form
+func FormBundle(r *http.Request) (BundleFile, error) {
+	var bf BundleFile
+	// parse incoming HTTP request multipart form
+	err := r.ParseMultipartForm(32 << 20) // maxMemory
+	if err != nil {
+		return bf, fmt.Errorf("[MLHub.main.FormBundle] r.ParseMultipartForm error: %w", err)
+	}
+	// extract file from HTTP request form
+	_, handler, err := r.FormFile("file")
+	if err != nil {
+		return bf, fmt.Errorf("[MLHub.main.FormBundle] r.FormFile error: %w", err)
+	}
+	bf.Name = handler.Filename
+	bf.Open = func() (io.ReadCloser, error) {
+		return handler.Open()
+	}
+	return bf, nil
+}
+
+// FileBundle returns ML bundle file for given file on local file system
+func FileBundle(fname string) BundleFile {
+	return BundleFile{
+		Name: filepath.Base(fname),
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(fname)
+		},
+	}
+}
+
 // Upload function uploads record to MetaData database, then
 // uploads file to server storage, and finally to ML backend
-func Upload(rec Record, r *http.Request) error {
-	err := uploadRecord(rec)
+func Upload(ctx context.Context, rec Record, bf BundleFile) error {
+	ctx, span := startSpan(ctx, "Upload", recordAttributes(rec)...)
+	err := upload(ctx, rec, bf)
+	endSpan(span, err)
+	return err
+}
+
+// helper function to perform upload steps of ML model within traced context
+func upload(ctx context.Context, rec Record, bf BundleFile) error {
+	activeUploads.Store(versionKey(rec), true)
+	defer activeUploads.Delete(versionKey(rec))
+	event := uploadEvent(rec)
+	_, span := startSpan(ctx, "bundleFileDigest")
+	digest, err := bundleFileDigest(bf)
+	endSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.Upload] bundleFileDigest error: %w", err)
+	}
+	rec.Digest = digest
+	rec.Updated = time.Now().Unix()
+	_, span = startSpan(ctx, "uploadRecord")
+	err = uploadRecord(rec)
+	endSpan(span, err)
 	if err != nil {
 		return fmt.Errorf("[MLHub.main.Upload] uploadRecord error: %w", err)
 	}
-	err = bundle2Storage(rec, r)
+	_, span = startSpan(ctx, "bundle2Storage")
+	err = bundle2Storage(rec, bf)
+	endSpan(span, err)
 	if err != nil {
 		return fmt.Errorf("[MLHub.main.Upload] bundle2Storage error: %w", err)
 	}
-	err = uploadBundle(rec, r)
+	replicas, err := uploadBundle(ctx, rec, bf)
 	if err != nil {
 		return fmt.Errorf("[MLHub.main.Upload] uploadBundle error: %w", err)
 	}
+	_, span = startSpan(ctx, "metaSet")
+	err = metaSet(rec, map[string]any{"replicas": replicas})
+	endSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.Upload] metaSet error: %w", err)
+	}
+	notifyWebhooks(event, rec, "")
 	return nil
 }
 
@@ -203,57 +308,49 @@ func uploadRecord(rec Record) error {
 	return nil
 }
 
-// helper function to remove bundle from our storate
-func removeBundle(rec Record) error {
-	modelDir := fmt.Sprintf("%s/%s/%s/%s", StorageDir, rec.Type, rec.Model, rec.Version)
-	return os.RemoveAll(modelDir)
-}
-
 // helper function to put bundle to the server storage
-func bundle2Storage(rec Record, r *http.Request) error {
+func bundle2Storage(rec Record, bf BundleFile) error {
 	if Verbose > 0 {
 		log.Printf("bundle2Storage %+v", rec)
 	}
-	// parse incoming HTTP request multipart form
-	err := r.ParseMultipartForm(32 << 20) // maxMemory
+	file, err := bf.Open()
 	if err != nil {
-		return fmt.Errorf("[MLHub.main.bundle2Storage] r.ParseMultipartForm error: %w", err)
+		return fmt.Errorf("[MLHub.main.bundle2Storage] bf.Open error: %w", err)
 	}
-	// extract file from HTTP request form
-	file, handler, err := r.FormFile("file")
-	if err != nil {
-		return fmt.Errorf("[MLHub.main.bundle2Storage] r.FormFile error: %w", err)
-	}
-
 	defer file.Close()
 	modelDir := fmt.Sprintf("%s/%s/%s/%s", StorageDir, rec.Type, rec.Model, rec.Version)
 	err = os.MkdirAll(modelDir, 0755)
 	if err != nil {
 		return fmt.Errorf("[MLHub.main.bundle2Storage] os.MkdirAll error: %w", err)
 	}
-	fname := filepath.Join(modelDir, handler.Filename)
+	fname := filepath.Join(modelDir, bf.Name)
 	dst, err := os.Create(fname)
 	if err != nil {
 		return fmt.Errorf("[MLHub.main.bundle2Storage] os.Create error: %w", err)
 	}
 	defer dst.Close()
-	if _, err := io.Copy(dst, file); err != nil {
+	size, err := io.Copy(dst, file)
+	if err != nil {
 		return fmt.Errorf("[MLHub.main.bundle2Storage] io.Copy error: %w", err)
 	}
+	uploadBytes.WithLabelValues(recordLabels(rec)...).Observe(float64(size))
 	return nil
 }
 
-// helper function to upload bundle tarball to ML backend
-func uploadBundle(rec Record, r *http.Request) error {
+// helper function to upload bundle tarball to ML backend, it returns URIs
+// of ML backend replicas which loaded the ML model
+func uploadBundle(ctx context.Context, rec Record, bf BundleFile) ([]string, error) {
 	if rec.Type == "TensorFlow" {
-		return uploadBundleTFaaS(rec, r)
+		return uploadBundleReplicas(ctx, rec, bf)
+	} else if rec.Type == "ONNX" {
+		return uploadBundleOIP(ctx, rec, bf)
 	} else if rec.Type == "PyTorch" {
-		return uploadBundleTorch(rec, r)
+		return nil, uploadBundleTorch(rec, bf)
 	} else if rec.Type == "ScikitLearn" {
-		return uploadBundleScikit(rec, r)
+		return nil, uploadBundleScikit(rec, bf)
 	}
 	msg := fmt.Sprintf("upload for %s backend is not implemented", rec.Type)
-	return errors.New(msg)
+	return nil, errors.New(msg)
 }
 
 // helper function to find ML backend record
@@ -272,95 +369,141 @@ func mlBackend(name, rtype string) (srvConfig.MLBackend, error) {
 	return mlBackend, errors.New(msg)
 }
 
-// helper functiont to upload bundle to TFaaS backend
-func uploadBundleTFaaS(rec Record, r *http.Request) error {
+// helper function to get the first configured ML backend of given ML model type
+func defaultBackend(mlType string) (string, error) {
+	for _, b := range srvConfig.Config.MLHub.ML.MLBackends {
+		if b.Type == mlType {
+			return b.Name, nil
+		}
+	}
+	msg := fmt.Sprintf("no ML backend is configured for %s ML models", mlType)
+	return "", errors.New(msg)
+}
+
+// helper functiont to upload bundle to all healthy replicas of ML backend,
+// it returns URIs of replicas which loaded the ML model
+func uploadBundleReplicas(ctx context.Context, rec Record, bf BundleFile) ([]string, error) {
 	if Verbose > 0 {
-		log.Println("uploadBundleTFaaS", rec)
+		log.Println("uploadBundleReplicas", rec)
 	}
 	backend, err := mlBackend(rec.Backend, rec.Type)
 	if Verbose > 0 {
 		log.Println("ML backend", backend)
 	}
 	if err != nil {
-		return fmt.Errorf("[MLHub.main.uploadBundleTFaaS] mlBackend error: %w", err)
+		return nil, fmt.Errorf("[MLHub.main.uploadBundleReplicas] mlBackend error: %w", err)
+	}
+	var replicas []string
+	var errs []error
+	for _, replica := range backendReplicas(backend) {
+		if status := backendHealth(replica); !status.Healthy {
+			log.Printf("WARNING: skip upload of %s to unhealthy replica %s", rec.Model, replica.URI)
+			continue
+		}
+		if err := uploadReplica(ctx, replica, rec, bf); err != nil {
+			log.Printf("ERROR: unable to upload %s to replica %s, error %v", rec.Model, replica.URI, err)
+			errs = append(errs, err)
+			continue
+		}
+		replicas = append(replicas, replica.URI)
+	}
+	if len(replicas) == 0 {
+		if len(errs) == 0 {
+			errs = append(errs, errBackendUnavailable)
+		}
+		return nil, fmt.Errorf("[MLHub.main.uploadBundleReplicas] no replica of %s loaded ML model: %w", backend.Name, errors.Join(errs...))
+	}
+	return replicas, nil
+}
+
+// helper function to upload bundle to given replica of ML backend
+func uploadReplica(ctx context.Context, replica srvConfig.MLBackend, rec Record, bf BundleFile) error {
+	if rec.Type == "ONNX" {
+		return uploadReplicaOIP(ctx, replica, rec, bf)
 	}
+	return uploadReplicaTFaaS(ctx, replica, rec, bf)
+}
 
+// helper functiont to upload bundle to given TFaaS replica
+func uploadReplicaTFaaS(ctx context.Context, backend srvConfig.MLBackend, rec Record, bf BundleFile) error {
 	// form backe URI
 	uri := fmt.Sprintf("%s/upload", backend.URI)
 	if Verbose > 0 {
 		log.Printf("upload model %s bundle to %s", rec.Model, uri)
 	}
-	// parse incoming HTTP request multipart form
-	err = r.ParseMultipartForm(32 << 20) // maxMemory
-
 	// construct proper request body
-	var body io.Reader
-	for _, vals := range r.MultipartForm.File {
-		for _, fh := range vals {
-			file, err := fh.Open()
-			if err != nil {
-				return fmt.Errorf("[MLHub.main.uploadBundleTFaaS] fh.Open error: %w", err)
-			}
-			body = io.NopCloser(file)
-		}
+	body, err := bf.Open()
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.uploadReplicaTFaaS] bf.Open error: %w", err)
 	}
+	defer body.Close()
 
 	// make HTTP request to remote TFaaS server
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
-	req, err := http.NewRequest("POST", uri, body)
+	client := httpClient(backend.Name)
+	// upload should complete even if client of MLHub went away
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), "POST", uri, body)
 	if err != nil {
-		return fmt.Errorf("[MLHub.main.uploadBundleTFaaS] http.NewRequest error: %w", err)
+		return fmt.Errorf("[MLHub.main.uploadReplicaTFaaS] http.NewRequest error: %w", err)
 	}
 	req.Header.Set("Content-Encoding", "gzip")
 	req.Header.Set("Content-Type", "application/octet-stream")
 	if Verbose > 0 {
 		log.Printf("New request %+v", req)
 	}
-	rsp, err := client.Do(req)
+	// uploads are not retried since bundle is streamed to ML backend
+	rsp, err := client.Do(req, false)
 	if Verbose > 0 {
 		log.Println("TFaaS response", rsp)
 	}
-	if err == nil {
-		// check response status code
-		if rsp.StatusCode != http.StatusOK {
-			msg := fmt.Sprintf("TFaaS response status %s", rsp.Status)
-			return errors.New(msg)
-		}
+	if err != nil {
+		backendFailure(backend, err)
+		return fmt.Errorf("[MLHub.main.uploadReplicaTFaaS] client.Do error: %w", err)
+	}
+	defer rsp.Body.Close()
+	// check response status code
+	if rsp.StatusCode != http.StatusOK {
+		msg := fmt.Sprintf("TFaaS response status %s", rsp.Status)
+		return errors.New(msg)
 	}
-	return fmt.Errorf("[MLHub.main.uploadBundleTFaaS] client.Do error: %w", err)
+	return nil
 }
 
 // helper functiont to upload bundle to Torch backend
-func uploadBundleTorch(rec Record, r *http.Request) error {
+func uploadBundleTorch(rec Record, bf BundleFile) error {
 	return errors.New("upload for TorchServer backend is not implemented")
 }
 
 // helper functiont to upload bundle to Scikit backend
-func uploadBundleScikit(rec Record, r *http.Request) error {
+func uploadBundleScikit(rec Record, bf BundleFile) error {
 	return errors.New("upload for ScikitLearn backend is not implemented")
 }
 
+// errNoRecords is returned when ML records of request are not found
+var errNoRecords = errors.New("No ML records found for your request")
+
 // helper function to get ML record for given HTTP request
-func modelRecord(rec Record) (Record, error) {
+func modelRecord(ctx context.Context, rec Record) (Record, error) {
 	var record Record
 	model := rec.Model
-	version := rec.Version
 	mtype := rec.Type
+	_, span := startSpan(ctx, "resolveVersion")
+	version := resolveVersion(model, mtype, rec.Version)
+	span.End()
 
 	// get ML meta-data
+	_, span = startSpan(ctx, "metaRecords")
 	records, err := metaRecords(model, mtype, version)
+	endSpan(span, err)
 	if err != nil {
 		msg := fmt.Sprintf("unable to get meta-data, error=%v", err)
 		return rec, errors.New(msg)
 	}
 	// we should have only one record from MetaData
 	if len(records) != 1 {
-		msg := fmt.Sprintf("Ambiguous request, ML records %+v", records)
 		if len(records) == 0 {
-			msg = fmt.Sprintf("No ML records found for your request")
+			return rec, errNoRecords
 		}
+		msg := fmt.Sprintf("Ambiguous request, ML records %+v", records)
 		return rec, errors.New(msg)
 	}
 	record = records[0]
@@ -0,0 +1,450 @@
+package main
+
+// huggingface module provides import of ML models from Hugging Face-style
+// repositories, i.e. from Hugging Face Hub compatible endpoints or local
+// directories with the same layout. Repository files are packed into ML
+// bundle and ML model is uploaded through the normal upload path
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+)
+
+// default Hugging Face Hub endpoint and repository revision
+const (
+	HFEndpoint = "https://huggingface.co"
+	HFRevision = "main"
+)
+
+// ML model types of libraries declared in library_name of model cards
+var hfLibraries = map[string]string{
+	"keras":                 "TensorFlow",
+	"tf-keras":              "TensorFlow",
+	"tensorflow":            "TensorFlow",
+	"pytorch":               "PyTorch",
+	"timm":                  "PyTorch",
+	"diffusers":             "PyTorch",
+	"sentence-transformers": "PyTorch",
+	"sklearn":               "ScikitLearn",
+	"scikit-learn":          "ScikitLearn",
+	"onnx":                  "ONNX",
+}
+
+// ML model types of weight files in repositories, ordered by preference
+var hfWeights = []struct {
+	Suffix string
+	Type   string
+}{
+	{".safetensors", "PyTorch"},
+	{"pytorch_model.bin", "PyTorch"},
+	{".pt", "PyTorch"},
+	{".pth", "PyTorch"},
+	{"tf_model.h5", "TensorFlow"},
+	{"saved_model.pb", "TensorFlow"},
+	{".keras", "TensorFlow"},
+	{".h5", "TensorFlow"},
+	{".joblib", "ScikitLearn"},
+	{".skops", "ScikitLearn"},
+	{".pkl", "ScikitLearn"},
+	{".onnx", "ONNX"},
+}
+
+// hfModelInfo represents repository information provided by Hugging Face Hub API
+type hfModelInfo struct {
+	Sha      string `json:"sha"`
+	Siblings []struct {
+		Name string `json:"rfilename"`
+	} `json:"siblings"`
+}
+
+// helper function to perform HTTP GET request to Hugging Face Hub endpoint
+func hfGet(ctx context.Context, imp HFImport, rpath string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(imp.Endpoint, "/")+rpath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if imp.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+imp.Token)
+	}
+	client := &http.Client{Timeout: time.Duration(HubConfig.HuggingFace.Timeout) * time.Second}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		rsp.Body.Close()
+		return nil, fmt.Errorf("%s responded with status %s", imp.Endpoint, rsp.Status)
+	}
+	return rsp, nil
+}
+
+// helper function to check that endpoint is allowed by MLHub configuration
+func hfEndpoint(endpoint string) error {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	for _, e := range HubConfig.HuggingFace.Endpoints {
+		if strings.TrimSuffix(e, "/") == endpoint {
+			return nil
+		}
+	}
+	msg := fmt.Sprintf("endpoint %s is not allowed by MLHub configuration", endpoint)
+	return errors.New(msg)
+}
+
+// helper function to escape every element of given path
+func escapePath(name string) string {
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// hfDownload downloads files of Hugging Face Hub repository into given
+// directory, total size of repository files is limited by MLHub configuration
+func hfDownload(ctx context.Context, imp HFImport, dir string) error {
+	rsp, err := hfGet(ctx, imp, fmt.Sprintf("/api/models/%s/revision/%s", escapePath(imp.Repo), url.PathEscape(imp.Revision)))
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.hfDownload] hfGet error: %w", err)
+	}
+	var info hfModelInfo
+	err = json.NewDecoder(rsp.Body).Decode(&info)
+	rsp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.hfDownload] json.Decode error: %w", err)
+	}
+	limit := HubConfig.HuggingFace.MaxSize
+	for _, sibling := range info.Siblings {
+		// repository files should never be written outside of our directory
+		if !filepath.IsLocal(sibling.Name) {
+			msg := fmt.Sprintf("repository file name '%s' is not allowed", sibling.Name)
+			return errors.New(msg)
+		}
+		size, err := hfDownloadFile(ctx, imp, sibling.Name, filepath.Join(dir, sibling.Name), limit)
+		if err != nil {
+			return err
+		}
+		limit -= size
+	}
+	if Verbose > 0 {
+		log.Printf("downloaded %d files of %s revision %s from %s", len(info.Siblings), imp.Repo, imp.Revision, imp.Endpoint)
+	}
+	return nil
+}
+
+// helper function to download single file of Hugging Face Hub repository,
+// it returns size of downloaded file which should not exceed given limit
+func hfDownloadFile(ctx context.Context, imp HFImport, name, fname string, limit int64) (int64, error) {
+	rpath := fmt.Sprintf("/%s/resolve/%s/%s", escapePath(imp.Repo), url.PathEscape(imp.Revision), escapePath(name))
+	rsp, err := hfGet(ctx, imp, rpath)
+	if err != nil {
+		return 0, fmt.Errorf("[MLHub.main.hfDownloadFile] hfGet error: %w", err)
+	}
+	defer rsp.Body.Close()
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return 0, fmt.Errorf("[MLHub.main.hfDownloadFile] os.MkdirAll error: %w", err)
+	}
+	file, err := os.Create(fname)
+	if err != nil {
+		return 0, fmt.Errorf("[MLHub.main.hfDownloadFile] os.Create error: %w", err)
+	}
+	size, err := io.Copy(file, io.LimitReader(rsp.Body, limit+1))
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return size, fmt.Errorf("[MLHub.main.hfDownloadFile] io.Copy error: %w", err)
+	}
+	if size > limit {
+		msg := fmt.Sprintf("repository size exceeds %d bytes", HubConfig.HuggingFace.MaxSize)
+		return size, errors.New(msg)
+	}
+	return size, nil
+}
+
+// hfCard reads metadata and description of model card (README.md) in
+// repository directory, metadata is provided as YAML front matter of model card
+func hfCard(dir string) (map[string]any, string, error) {
+	meta := map[string]any{}
+	data, err := os.ReadFile(filepath.Join(dir, "README.md"))
+	if os.IsNotExist(err) {
+		return meta, "", nil
+	} else if err != nil {
+		return meta, "", fmt.Errorf("[MLHub.main.hfCard] os.ReadFile error: %w", err)
+	}
+	body := string(data)
+	if strings.HasPrefix(body, "---") {
+		parts := strings.SplitN(body, "\n---", 2)
+		if len(parts) == 2 {
+			front := strings.TrimPrefix(parts[0], "---")
+			if err := yaml.Unmarshal([]byte(front), &meta); err != nil {
+				return meta, "", fmt.Errorf("[MLHub.main.hfCard] yaml.Unmarshal error: %w", err)
+			}
+			body = parts[1]
+		}
+	}
+	// description is the first paragraph of model card which is not a heading
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "<!--") {
+			if len(lines) > 0 {
+				break
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return meta, strings.Join(lines, " "), nil
+}
+
+// hfConfig reads config.json of repository directory
+func hfConfig(dir string) (map[string]any, error) {
+	config := map[string]any{}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return config, fmt.Errorf("[MLHub.main.hfConfig] os.ReadFile error: %w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("[MLHub.main.hfConfig] json.Unmarshal error: %w", err)
+	}
+	return config, nil
+}
+
+// helper function to get files of repository directory relative to it,
+// git meta-data of repository is skipped
+func hfFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(fname string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(dir, fname)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return files, err
+}
+
+// hfType infers ML model type from library of model card and from weight
+// files of repository, transformers library provides weights of several
+// frameworks and therefore its type is inferred from weights
+func hfType(files []string, card map[string]any) (string, error) {
+	if library, ok := card["library_name"].(string); ok {
+		if mlType, ok := hfLibraries[strings.ToLower(library)]; ok {
+			return mlType, nil
+		}
+	}
+	for _, w := range hfWeights {
+		for _, fname := range files {
+			if strings.HasSuffix(fname, w.Suffix) && slices.Contains(MLTypes, w.Type) {
+				return w.Type, nil
+			}
+		}
+	}
+	return "", errors.New("unable to infer ML model type, repository does not provide known weight files")
+}
+
+// packBundle packs all files of repository directory into tar.gz ML bundle
+func packBundle(dir string, files []string, fname string) error {
+	file, err := os.Create(fname)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.packBundle] os.Create error: %w", err)
+	}
+	defer file.Close()
+	gw := gzip.NewWriter(file)
+	tw := tar.NewWriter(gw)
+	for _, name := range files {
+		if err := tarFile(tw, filepath.Join(dir, name), name); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("[MLHub.main.packBundle] tar.Close error: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("[MLHub.main.packBundle] gzip.Close error: %w", err)
+	}
+	return file.Close()
+}
+
+// helper function to add file to tar archive under given name
+func tarFile(tw *tar.Writer, fname, name string) error {
+	file, err := os.Open(fname)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.tarFile] os.Open error: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.tarFile] file.Stat error: %w", err)
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.tarFile] tar.FileInfoHeader error: %w", err)
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("[MLHub.main.tarFile] tar.WriteHeader error: %w", err)
+	}
+	if _, err := io.Copy(tw, file); err != nil {
+		return fmt.Errorf("[MLHub.main.tarFile] io.Copy error: %w", err)
+	}
+	return nil
+}
+
+// hfRecord creates ML record of repository directory, ML model type and
+// backend are inferred unless they are provided by import request
+func hfRecord(imp HFImport, dir string, files []string) (Record, error) {
+	rec := Record{
+		Model:      imp.Model,
+		Type:       imp.Type,
+		Backend:    imp.Backend,
+		Version:    imp.Version,
+		Discipline: imp.Discipline,
+	}
+	card, description, err := hfCard(dir)
+	if err != nil {
+		return rec, err
+	}
+	config, err := hfConfig(dir)
+	if err != nil {
+		return rec, err
+	}
+	if rec.Model == "" {
+		if imp.Repo != "" {
+			rec.Model = path.Base(imp.Repo)
+		} else {
+			rec.Model = filepath.Base(filepath.Clean(dir))
+		}
+	}
+	if rec.Type == "" {
+		if rec.Type, err = hfType(files, card); err != nil {
+			return rec, err
+		}
+	}
+	if rec.Backend == "" {
+		if rec.Backend, err = defaultBackend(rec.Type); err != nil {
+			return rec, err
+		}
+	}
+	if description == "" {
+		// describe ML model by its architecture if model card does not provide description
+		var buf bytes.Buffer
+		if archs, ok := config["architectures"].([]any); ok {
+			for _, a := range archs {
+				fmt.Fprintf(&buf, "%v ", a)
+			}
+		}
+		if mtype, ok := config["model_type"].(string); ok {
+			fmt.Fprintf(&buf, "%s ", mtype)
+		}
+		if buf.Len() > 0 {
+			description = strings.TrimSpace(buf.String()) + " model"
+		}
+	}
+	rec.Description = description
+	if imp.Repo != "" && imp.Dir == "" {
+		rec.Reference = fmt.Sprintf("%s/%s", strings.TrimSuffix(imp.Endpoint, "/"), imp.Repo)
+	}
+	rec.Bundle = rec.Model + ".tar.gz"
+	return rec, nil
+}
+
+// importHF imports ML model from Hugging Face-style repository, i.e. it
+// downloads repository files unless local directory is provided, creates
+// ML record from config.json and model card, packs repository files into
+// ML bundle and uploads ML model to MLHub storage and its ML backend
+func importHF(ctx context.Context, imp HFImport, user string) (Record, error) {
+	var rec Record
+	if imp.Repo == "" && imp.Dir == "" {
+		return rec, errors.New("import requires either repository name or local directory")
+	}
+	if imp.Endpoint == "" {
+		imp.Endpoint = HFEndpoint
+	}
+	if imp.Dir == "" {
+		if err := hfEndpoint(imp.Endpoint); err != nil {
+			return rec, err
+		}
+	}
+	if imp.Revision == "" {
+		imp.Revision = HFRevision
+	}
+	tmpDir, err := os.MkdirTemp("", "mlhub-hf-*")
+	if err != nil {
+		return rec, fmt.Errorf("[MLHub.main.importHF] os.MkdirTemp error: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	dir := imp.Dir
+	if dir == "" {
+		dir = filepath.Join(tmpDir, "repo")
+		if err := hfDownload(ctx, imp, dir); err != nil {
+			return rec, err
+		}
+	}
+	files, err := hfFiles(dir)
+	if err != nil {
+		return rec, fmt.Errorf("[MLHub.main.importHF] hfFiles error: %w", err)
+	}
+	rec, err = hfRecord(imp, dir, files)
+	if err != nil {
+		return rec, err
+	}
+	rec, err = validateUpload(rec)
+	if err != nil {
+		return rec, err
+	}
+	rec.UserName = user
+	fname := filepath.Join(tmpDir, rec.Bundle)
+	if err := packBundle(dir, files, fname); err != nil {
+		return rec, err
+	}
+	// ONNX model is validated and provides its inputs and outputs
+	if rec, _, err = onnxRecord(rec, FileBundle(fname)); err != nil {
+		return rec, err
+	}
+	if Verbose > 0 {
+		log.Printf("import %s type %s backend %s from %s%s", rec.Model, rec.Type, rec.Backend, imp.Repo, imp.Dir)
+	}
+	if err := Upload(ctx, rec, FileBundle(fname)); err != nil {
+		return rec, err
+	}
+	// provide record with digest and replicas set by upload
+	if records, err := metaRecords(rec.Model, rec.Type, rec.Version); err == nil && len(records) == 1 {
+		rec = records[0]
+	}
+	return rec, nil
+}
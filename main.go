@@ -22,6 +22,8 @@ func main() {
 	cfile := os.Getenv("FOXDEN_CONFIG")
 	var config string
 	flag.StringVar(&config, "config", cfile, "server config file, default $FOXDEN_CONFIG")
+	var hubConfig string
+	flag.StringVar(&hubConfig, "hubconfig", os.Getenv("MLHUB_CONFIG"), "MLHub config file, default $MLHUB_CONFIG")
 	flag.Parse()
 	if version {
 		fmt.Println("server version:", srvConfig.Info())
@@ -32,6 +34,11 @@ func main() {
 	} else {
 		log.Fatal(fmt.Sprintf("Unable to parse config='%s'\nerror: %v", config, err))
 	}
+	if cobj, err := ParseHubConfig(hubConfig); err == nil {
+		HubConfig = cobj
+	} else {
+		log.Fatal(fmt.Sprintf("Unable to parse hubconfig='%s'\nerror: %v", hubConfig, err))
+	}
 	if srvConfig.Config.MLHub.WebServer.Verbose > 0 {
 		log.SetFlags(log.Llongfile)
 	}
@@ -38,6 +38,30 @@ func metaUpdate(rec Record) error {
 	return nil
 }
 
+// metaSet sets given fields of ML model version record in MLHub database.
+// The record is matched by its deleted timestamp, therefore active record
+// is never confused with its copy in trash
+func metaSet(rec Record, fields map[string]any) error {
+	spec := map[string]any{"model": rec.Model, "type": rec.Type, "version": rec.Version}
+	if rec.Deleted > 0 {
+		spec["deleted"] = rec.Deleted
+	} else {
+		spec["deleted"] = map[string]any{"$not": map[string]any{"$gt": 0}}
+	}
+	if Verbose > 0 {
+		log.Printf("set %+v of meta-record for spec %+v", fields, spec)
+	}
+	err := mongo.UpsertRecord(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		srvConfig.Config.MLHub.MongoDB.DBColl,
+		spec,
+		fields)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.metaSet] mongo.UpsertRecord error: %w", err)
+	}
+	return nil
+}
+
 // metaRemove removes given model from MLHub database
 func metaRemove(spec map[string]any) error {
 	if Verbose > 0 {
@@ -65,10 +89,29 @@ func metaRecords(model, mlType, version string) ([]Record, error) {
 	if mlType != "" {
 		spec["type"] = mlType
 	}
+	return metaQuery(spec)
+}
+
+// metaQuery retrieves records matching given spec from underlying MLHub database
+func metaQuery(spec map[string]any) ([]Record, error) {
+	return metaPage(spec, 0, -1)
+}
+
+// metaPage retrieves given page of records matching given spec from underlying
+// MLHub database, negative limit means all records. Records of ML models in
+// trash are skipped unless spec explicitly asks for deleted records
+func metaPage(spec map[string]any, idx, limit int) ([]Record, error) {
+	if _, ok := spec["deleted"]; !ok {
+		active := map[string]any{"deleted": map[string]any{"$not": map[string]any{"$gt": 0}}}
+		for key, val := range spec {
+			active[key] = val
+		}
+		spec = active
+	}
 	results := mongo.Get(
 		srvConfig.Config.MLHub.MongoDB.DBName,
 		srvConfig.Config.MLHub.MongoDB.DBColl,
-		spec, 0, -1)
+		spec, idx, limit)
 	var records []Record
 	for _, rec := range results {
 		var r Record
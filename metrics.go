@@ -0,0 +1,270 @@
+package main
+
+// metrics module provides Prometheus metrics of MLHub APIs, ML backends and
+// storage, metrics of ML models use the same labels as ML record fields
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	server "github.com/CHESSComputing/golib/server"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// metricsRecordKey defines gin context key of ML record of HTTP request
+const metricsRecordKey = "mlhub.record"
+
+// labels of ML model metrics, they match JSON names of ML record fields
+var modelLabels = []string{"model", "type", "version", "backend"}
+
+// metricsRegistry holds all MLHub metrics
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mlhub_requests_total",
+		Help: "Number of MLHub requests per route, status code and ML model",
+	}, append([]string{"method", "route", "code"}, modelLabels...))
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mlhub_request_duration_seconds",
+		Help:    "Latency of MLHub requests per route and ML model",
+		Buckets: prometheus.DefBuckets,
+	}, append([]string{"method", "route"}, modelLabels...))
+
+	backendRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mlhub_backend_requests_total",
+		Help: "Number of requests to ML backends per replica and status code, code is error for connection failures and breaker for requests rejected by open circuit breaker",
+	}, []string{"backend", "replica", "code"})
+
+	backendDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mlhub_backend_request_duration_seconds",
+		Help:    "Latency of requests to ML backends per replica",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"backend", "replica"})
+
+	uploadBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mlhub_upload_bytes",
+		Help:    "Size of uploaded ML bundles per ML model",
+		Buckets: prometheus.ExponentialBuckets(1<<10, 4, 12),
+	}, modelLabels)
+
+	cacheRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mlhub_cache_requests_total",
+		Help: "Number of lookups of MLHub caches per cache and result, result is hit or miss",
+	}, []string{"cache", "result"})
+)
+
+// storageCollector provides storage usage of ML bundles per ML model type
+// and name, it walks storage area on every scrape
+type storageCollector struct {
+	desc *prometheus.Desc
+}
+
+// Describe implements prometheus.Collector interface
+func (s *storageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.desc
+}
+
+// Collect implements prometheus.Collector interface
+func (s *storageCollector) Collect(ch chan<- prometheus.Metric) {
+	usage := make(map[[2]string]int64)
+	// ML bundles are stored as StorageDir/type/model/version/bundle
+	filepath.Walk(StorageDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(StorageDir, path)
+		if err != nil {
+			return nil
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) < 3 {
+			return nil
+		}
+		usage[[2]string{parts[0], parts[1]}] += info.Size()
+		return nil
+	})
+	for key, size := range usage {
+		ch <- prometheus.MustNewConstMetric(s.desc, prometheus.GaugeValue, float64(size), key[0], key[1])
+	}
+}
+
+// backendsCollector provides health and circuit breaker state of ML backend replicas
+type backendsCollector struct {
+	up      *prometheus.Desc
+	breaker *prometheus.Desc
+	pending *prometheus.Desc
+}
+
+// Describe implements prometheus.Collector interface
+func (b *backendsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- b.up
+	ch <- b.breaker
+	ch <- b.pending
+}
+
+// Collect implements prometheus.Collector interface
+func (b *backendsCollector) Collect(ch chan<- prometheus.Metric) {
+	if srvConfig.Config == nil {
+		return
+	}
+	for _, backend := range srvConfig.Config.MLHub.ML.MLBackends {
+		for _, replica := range backendReplicas(backend) {
+			var up float64
+			if backendHealth(replica).Healthy {
+				up = 1
+			}
+			ch <- prometheus.MustNewConstMetric(b.up, prometheus.GaugeValue, up, replica.Name, replica.Type, replica.URI)
+			state := breakerState(replica)
+			for _, s := range []string{BreakerClosed, BreakerOpen, BreakerHalfOpen} {
+				var val float64
+				if s == state {
+					val = 1
+				}
+				ch <- prometheus.MustNewConstMetric(b.breaker, prometheus.GaugeValue, val, replica.Name, replica.Type, replica.URI, s)
+			}
+			ch <- prometheus.MustNewConstMetric(b.pending, prometheus.GaugeValue,
+				float64(outstandingRequests(replica.URI)), replica.Name, replica.Type, replica.URI)
+		}
+	}
+}
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsTotal,
+		requestDuration,
+		backendRequestsTotal,
+		backendDuration,
+		uploadBytes,
+		cacheRequestsTotal,
+		&storageCollector{
+			desc: prometheus.NewDesc("mlhub_storage_bytes",
+				"Storage usage of ML bundles per ML model type and name",
+				[]string{"type", "model"}, nil),
+		},
+		&backendsCollector{
+			up: prometheus.NewDesc("mlhub_backend_up",
+				"Health of ML backend replica, 1 if replica passes its health probes",
+				[]string{"backend", "type", "replica"}, nil),
+			breaker: prometheus.NewDesc("mlhub_backend_breaker_state",
+				"State of circuit breaker of ML backend replica, 1 for its current state",
+				[]string{"backend", "type", "replica", "state"}, nil),
+			pending: prometheus.NewDesc("mlhub_backend_outstanding_requests",
+				"Number of outstanding requests of ML backend replica",
+				[]string{"backend", "type", "replica"}, nil),
+		},
+	)
+}
+
+// helper function to get values of ML model labels of given record
+func recordLabels(rec Record) []string {
+	return []string{rec.Model, rec.Type, rec.Version, rec.Backend}
+}
+
+// helper function to attach ML record to HTTP request metrics, handlers
+// call it once they know which ML model the request is about
+func metricsRecord(c *gin.Context, rec Record) {
+	c.Set(metricsRecordKey, rec)
+}
+
+// helper function to wrap route handler with collection of its metrics
+func metricsHandler(route server.Route) gin.HandlerFunc {
+	handler := route.Handler
+	return func(c *gin.Context) {
+		time0 := time.Now()
+		handler(c)
+		var rec Record
+		if val, ok := c.Get(metricsRecordKey); ok {
+			rec, _ = val.(Record)
+		}
+		labels := append([]string{route.Method, route.Path}, recordLabels(rec)...)
+		requestDuration.WithLabelValues(labels...).Observe(time.Since(time0).Seconds())
+		code := strconv.Itoa(c.Writer.Status())
+		labels = append([]string{route.Method, route.Path, code}, recordLabels(rec)...)
+		requestsTotal.WithLabelValues(labels...).Inc()
+	}
+}
+
+// helper function to observe request to ML backend replica
+func observeBackend(backend, replica, code string, latency time.Duration) {
+	backendRequestsTotal.WithLabelValues(backend, replica, code).Inc()
+	if code != "breaker" {
+		backendDuration.WithLabelValues(backend, replica).Observe(latency.Seconds())
+	}
+}
+
+// grpcModelRequest represents gRPC request of specific ML model
+type grpcModelRequest interface {
+	GetModel() string
+	GetType() string
+	GetVersion() string
+	GetBackend() string
+}
+
+// grpcRecordKey defines context key of ML record of gRPC call
+type grpcRecordKey struct{}
+
+// helper function to attach ML record to gRPC call metrics, gRPC methods
+// call it once ML record is resolved from MLHub metadata
+func grpcMetricsRecord(ctx context.Context, rec Record) {
+	if holder, ok := ctx.Value(grpcRecordKey{}).(*Record); ok {
+		*holder = rec
+	}
+}
+
+// helper function to observe gRPC call of given method and ML record
+func observeGRPC(method string, rec Record, err error, latency time.Duration) {
+	labels := append([]string{"GRPC", method}, recordLabels(rec)...)
+	requestDuration.WithLabelValues(labels...).Observe(latency.Seconds())
+	labels = append([]string{"GRPC", method, status.Code(err).String()}, recordLabels(rec)...)
+	requestsTotal.WithLabelValues(labels...).Inc()
+}
+
+// helper function to collect metrics of unary gRPC calls
+func grpcUnaryMetrics(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	time0 := time.Now()
+	rec := &Record{}
+	rsp, err := handler(context.WithValue(ctx, grpcRecordKey{}, rec), req)
+	observeGRPC(info.FullMethod, *rec, err, time.Since(time0))
+	return rsp, err
+}
+
+// helper function to collect metrics of streaming gRPC calls
+func grpcStreamMetrics(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	time0 := time.Now()
+	rec := &Record{}
+	ctx := context.WithValue(ss.Context(), grpcRecordKey{}, rec)
+	err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	observeGRPC(info.FullMethod, *rec, err, time.Since(time0))
+	return err
+}
+
+// MetricsHandler provides MLHub metrics in Prometheus format via /metrics
+func MetricsHandler(c *gin.Context) {
+	promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}).ServeHTTP(c.Writer, c.Request)
+}
+
+// helper function to record lookup of given cache
+func metricsCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequestsTotal.WithLabelValues(cache, result).Inc()
+}
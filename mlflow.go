@@ -0,0 +1,264 @@
+package main
+
+// mlflow module provides ingestion of ML models in MLflow format, i.e. ML
+// bundles which contain MLmodel file along with flavor directories. MLmodel
+// file provides ML model type, framework version, signature and input example
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// MLmodel is name of MLflow model file
+const MLmodel = "MLmodel"
+
+// max size of MLflow files we read from ML bundle
+const mlflowMaxSize = 1 << 20
+
+// ML model types of MLflow flavors, ordered by preference
+var mlflowFlavors = []struct {
+	Flavor string
+	Type   string
+}{
+	{"tensorflow", "TensorFlow"},
+	{"keras", "TensorFlow"},
+	{"pytorch", "PyTorch"},
+	{"sklearn", "ScikitLearn"},
+	{"onnx", "ONNX"},
+}
+
+// mlflowModel represents MLmodel file of MLflow model
+type mlflowModel struct {
+	ArtifactPath  string                    `yaml:"artifact_path"`
+	Flavors       map[string]map[string]any `yaml:"flavors"`
+	MLflowVersion string                    `yaml:"mlflow_version"`
+	RunID         string                    `yaml:"run_id"`
+	Signature     map[string]any            `yaml:"signature"`
+	InputExample  map[string]any            `yaml:"saved_input_example_info"`
+}
+
+// helper function to read files of ML bundle archive, i.e. tar, tar.gz or
+// zip archive, read function is called for every regular file of archive
+// and it stops reading when it returns false
+func readArchive(bf BundleFile, read func(name string, size int64, r io.Reader) (bool, error)) error {
+	name := strings.ToLower(bf.Name)
+	compressed := strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+	if !compressed && !strings.HasSuffix(name, ".tar") && !strings.HasSuffix(name, ".zip") {
+		// other ML bundles are not archives
+		return nil
+	}
+	file, err := bf.Open()
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.readArchive] bf.Open error: %w", err)
+	}
+	defer file.Close()
+	if strings.HasSuffix(name, ".zip") {
+		return readZip(file, read)
+	}
+	var reader io.Reader = file
+	if compressed {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("[MLHub.main.readArchive] gzip.NewReader error: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("[MLHub.main.readArchive] tar.Next error: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		more, err := read(path.Clean(hdr.Name), hdr.Size, tr)
+		if err != nil || !more {
+			return err
+		}
+	}
+}
+
+// helper function to read files of zip archive, zip archive requires random
+// access and therefore it is copied to temporary file if necessary
+func readZip(file io.Reader, read func(name string, size int64, r io.Reader) (bool, error)) error {
+	ra, ok := file.(interface {
+		io.ReaderAt
+		io.Seeker
+	})
+	if !ok {
+		tmp, err := os.CreateTemp("", "mlhub-bundle-*.zip")
+		if err != nil {
+			return fmt.Errorf("[MLHub.main.readZip] os.CreateTemp error: %w", err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if _, err := io.Copy(tmp, file); err != nil {
+			return fmt.Errorf("[MLHub.main.readZip] io.Copy error: %w", err)
+		}
+		ra = tmp
+	}
+	size, err := ra.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.readZip] Seek error: %w", err)
+	}
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.readZip] zip.NewReader error: %w", err)
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		reader, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("[MLHub.main.readZip] zip.Open error: %w", err)
+		}
+		more, err := read(path.Clean(f.Name), int64(f.UncompressedSize64), reader)
+		reader.Close()
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// helper function to read content of archive file with size limit
+func readLimited(name string, size int64, r io.Reader) ([]byte, error) {
+	if size > mlflowMaxSize {
+		msg := fmt.Sprintf("%s is larger than %d bytes", name, mlflowMaxSize)
+		return nil, errors.New(msg)
+	}
+	return io.ReadAll(io.LimitReader(r, mlflowMaxSize))
+}
+
+// readMLmodel reads MLmodel file of MLflow model from ML bundle, it returns
+// false if ML bundle is not MLflow model. MLmodel file is expected either
+// at the top of archive or in its top-level directory
+func readMLmodel(bf BundleFile) (mlflowModel, string, bool, error) {
+	var model mlflowModel
+	var dir string
+	found := false
+	err := readArchive(bf, func(name string, size int64, r io.Reader) (bool, error) {
+		if path.Base(name) != MLmodel || strings.Count(name, "/") > 1 {
+			return true, nil
+		}
+		data, err := readLimited(name, size, r)
+		if err != nil {
+			return false, err
+		}
+		if err := yaml.Unmarshal(data, &model); err != nil {
+			return false, fmt.Errorf("[MLHub.main.readMLmodel] yaml.Unmarshal error: %w", err)
+		}
+		dir = path.Dir(name)
+		found = true
+		return false, nil
+	})
+	return model, dir, found, err
+}
+
+// helper function to read input example of MLflow model from ML bundle
+func readInputExample(bf BundleFile, fname string) (any, error) {
+	var example any
+	err := readArchive(bf, func(name string, size int64, r io.Reader) (bool, error) {
+		if name != fname {
+			return true, nil
+		}
+		data, err := readLimited(name, size, r)
+		if err != nil {
+			return false, err
+		}
+		if err := json.Unmarshal(data, &example); err != nil {
+			return false, fmt.Errorf("[MLHub.main.readInputExample] json.Unmarshal error: %w", err)
+		}
+		return false, nil
+	})
+	return example, err
+}
+
+// helper function to decode MLflow signature, its inputs, outputs and params
+// are stored in MLmodel file as JSON strings
+func mlflowSignature(sig map[string]any) (*Signature, error) {
+	if len(sig) == 0 {
+		return nil, nil
+	}
+	fields := make(map[string]any)
+	for _, key := range []string{"inputs", "outputs", "params"} {
+		val, ok := sig[key]
+		if !ok || val == nil {
+			continue
+		}
+		if spec, ok := val.(string); ok {
+			var v any
+			if err := json.Unmarshal([]byte(spec), &v); err != nil {
+				return nil, fmt.Errorf("[MLHub.main.mlflowSignature] json.Unmarshal error: %w", err)
+			}
+			val = v
+		}
+		fields[key] = val
+	}
+	return &Signature{Inputs: fields["inputs"], Outputs: fields["outputs"], Params: fields["params"]}, nil
+}
+
+// mlflowRecord fills ML record from MLflow model of given ML bundle, i.e. ML
+// model type and backend unless they are provided, framework version,
+// signature and input example. It returns false if ML bundle is not MLflow model
+func mlflowRecord(rec Record, bf BundleFile) (Record, bool, error) {
+	model, dir, found, err := readMLmodel(bf)
+	if err != nil || !found {
+		return rec, found, err
+	}
+	for _, f := range mlflowFlavors {
+		flavor, ok := model.Flavors[f.Flavor]
+		if !ok {
+			continue
+		}
+		if rec.Type == "" {
+			rec.Type = f.Type
+		}
+		if version, ok := flavor[f.Flavor+"_version"]; ok {
+			rec.Framework = fmt.Sprintf("%s %v", f.Flavor, version)
+		} else {
+			rec.Framework = f.Flavor
+		}
+		break
+	}
+	if rec.Type == "" {
+		var flavors []string
+		for name := range model.Flavors {
+			flavors = append(flavors, name)
+		}
+		msg := fmt.Sprintf("unsupported MLflow flavors %v", flavors)
+		return rec, true, errors.New(msg)
+	}
+	if rec.Backend == "" {
+		if rec.Backend, err = defaultBackend(rec.Type); err != nil {
+			return rec, true, err
+		}
+	}
+	if rec.Signature, err = mlflowSignature(model.Signature); err != nil {
+		return rec, true, err
+	}
+	if fname, ok := model.InputExample["artifact_path"].(string); ok && fname != "" {
+		if rec.InputExample, err = readInputExample(bf, path.Join(dir, fname)); err != nil {
+			return rec, true, err
+		}
+	}
+	return rec, true, nil
+}
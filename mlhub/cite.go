@@ -0,0 +1,46 @@
+package mlhub
+
+// cite module provides citations of ML models
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// citeKeyPattern defines characters which are not allowed in BibTeX keys
+var citeKeyPattern = regexp.MustCompile("[^0-9a-zA-Z_:-]+")
+
+// Citation returns citation of ML model record in given format, supported
+// formats are bibtex and text. The url should point to ML model page
+func Citation(rec Record, url, format string) (string, error) {
+	title := rec.Model
+	if rec.Description != "" {
+		title = fmt.Sprintf("%s: %s", rec.Model, rec.Description)
+	}
+	author := rec.UserName
+	if author == "" {
+		author = "MLHub"
+	}
+	switch strings.ToLower(format) {
+	case "", "bibtex":
+		key := citeKeyPattern.ReplaceAllString(fmt.Sprintf("%s_%s", rec.Model, rec.Version), "_")
+		var cite strings.Builder
+		cite.WriteString(fmt.Sprintf("@misc{%s,\n", key))
+		cite.WriteString(fmt.Sprintf("  title = {%s},\n", title))
+		cite.WriteString(fmt.Sprintf("  author = {%s},\n", author))
+		cite.WriteString(fmt.Sprintf("  version = {%s},\n", rec.Version))
+		cite.WriteString(fmt.Sprintf("  howpublished = {\\url{%s}},\n", url))
+		cite.WriteString(fmt.Sprintf("  note = {%s ML model published in MLHub}\n", rec.Type))
+		cite.WriteString("}\n")
+		return cite.String(), nil
+	case "text":
+		return fmt.Sprintf("%s. %s (version %s) [%s ML model]. MLHub. %s\n",
+			author, title, rec.Version, rec.Type, url), nil
+	}
+	msg := fmt.Sprintf("unsupported citation format '%s'", format)
+	return "", errors.New(msg)
+}
@@ -0,0 +1,359 @@
+// Package mlhub holds data representations of MLHub service shared by
+// MLHub server and its clients
+//
+// Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
+package mlhub
+
+// Record define ML meta record
+type Record struct {
+	Model       string `json:"model"`       // model name
+	Type        string `json:"type"`        // model type
+	Backend     string `json:"backend"`     // ML backend name
+	Version     string `json:"version"`     // ML version
+	Description string `json:"description"` // ML model description
+	Reference   string `json:"reference"`   // ML reference URL
+	Discipline  string `json:"discipline"`  // ML discipline
+	Bundle      string `json:"bundle"`      // ML bundle file
+	Digest      string `json:"digest"`      // ML bundle digest, e.g. sha256:123
+	UserName    string `json:"username"`    // user name
+	Deleted     int64  `json:"deleted"`     // timestamp when ML model was moved to trash, 0 otherwise
+	Updated     int64  `json:"updated"`     // timestamp of the last upload of ML model version
+	Origin      string `json:"origin"`      // URL of remote MLHub ML model is mirrored from, empty for local ML models
+	Framework   string `json:"framework"`   // ML framework and its version, e.g. sklearn 1.3.0
+	Input       any    `json:"input"`       // prediction input
+	Data        []byte `json:"data"`        // input data, e.g. image.png
+
+	Provenance   Provenance  `json:"provenance"`   // ML model provenance
+	Conversion   *Conversion `json:"conversion"`   // source ML model of converted ML model, e.g. ONNX export
+	Signature    *Signature  `json:"signature"`    // ML model signature, i.e. schema of its inputs and outputs
+	InputExample any         `json:"inputexample"` // example of ML model input
+	Replicas     []string    `json:"replicas"`     // ML backend replicas which loaded ML model
+}
+
+// Signature defines ML model signature, e.g. column or tensor specs of its
+// inputs and outputs as provided by MLflow
+type Signature struct {
+	Inputs  any `json:"inputs"`  // schema of ML model inputs
+	Outputs any `json:"outputs"` // schema of ML model outputs
+	Params  any `json:"params"`  // schema of ML model inference parameters
+}
+
+// TensorSpec defines name, data type and shape of ML model input or output
+// tensor, e.g. of ONNX graph. Data types follow Open Inference Protocol, e.g.
+// FP32, and dynamic dimensions of the shape are -1
+type TensorSpec struct {
+	Name     string  `json:"name"`     // tensor name
+	Datatype string  `json:"datatype"` // tensor data type, e.g. FP32
+	Shape    []int64 `json:"shape"`    // tensor shape
+}
+
+// Conversion defines link of converted ML model, e.g. ONNX export, to its
+// source ML model
+type Conversion struct {
+	SourceModel   string `json:"sourcemodel"`   // source ML model name
+	SourceType    string `json:"sourcetype"`    // source ML model type, e.g. PyTorch
+	SourceVersion string `json:"sourceversion"` // source ML model version
+	Converter     string `json:"converter"`     // converter and its version, e.g. tf2onnx 1.16.1
+}
+
+// Provenance defines ML model provenance, i.e. links to FOXDEN datasets
+// and parent ML model it was derived from
+type Provenance struct {
+	Datasets      []string       `json:"datasets"`      // DIDs of training datasets
+	Evaluation    []string       `json:"evaluation"`    // DIDs of evaluation datasets
+	Commit        string         `json:"commit"`        // code commit used for training
+	ParentModel   string         `json:"parentmodel"`   // parent ML model name
+	ParentVersion string         `json:"parentversion"` // parent ML model version
+	Parameters    map[string]any `json:"parameters"`    // training parameters
+}
+
+// Lineage defines lineage of ML model
+type Lineage struct {
+	Record      Record   `json:"record"`      // ML model record
+	Ancestors   []Record `json:"ancestors"`   // ML models given model was derived from
+	Descendants []Record `json:"descendants"` // ML models derived from given model
+}
+
+// PredictionRecord defines provenance record of ML prediction
+type PredictionRecord struct {
+	ID         string `json:"id"`         // provenance record ID
+	User       string `json:"user"`       // user who requested prediction
+	Model      string `json:"model"`      // ML model name
+	Type       string `json:"type"`       // ML model type
+	Version    string `json:"version"`    // resolved ML model version
+	Digest     string `json:"digest"`     // ML bundle digest
+	InputHash  string `json:"inputhash"`  // hash of prediction input
+	OutputHash string `json:"outputhash"` // hash of prediction output
+	Backend    string `json:"backend"`    // ML backend name
+	Timestamp  int64  `json:"timestamp"`  // prediction timestamp
+}
+
+// Domain defines scientific domain (discipline) of ML models
+type Domain struct {
+	Name        string `json:"name"`        // domain name, e.g. Physics
+	Parent      string `json:"parent"`      // parent domain name
+	Description string `json:"description"` // domain description
+}
+
+// DomainNode defines scientific domain within domains taxonomy
+type DomainNode struct {
+	Domain
+	Depth  int      `json:"depth"`  // depth of domain within taxonomy
+	Count  int      `json:"count"`  // number of ML models in this domain
+	Total  int      `json:"total"`  // number of ML models in this domain and its sub-domains
+	Models []string `json:"models"` // ML model names of this domain
+}
+
+// Alias defines alias of ML model version, e.g. production, which is
+// assigned by promote action
+type Alias struct {
+	Model   string `json:"model"`   // ML model name
+	Type    string `json:"type"`    // ML model type
+	Alias   string `json:"alias"`   // alias name, e.g. production
+	Version string `json:"version"` // ML model version the alias points to
+}
+
+// UploadStatus defines status of resumable upload of ML bundle
+type UploadStatus struct {
+	ID   string `json:"id"`   // upload ID
+	Name string `json:"name"` // bundle file name
+	Size int64  `json:"size"` // number of uploaded bytes
+}
+
+// BackendStatus defines health status of ML backend
+type BackendStatus struct {
+	Name      string  `json:"name"`      // ML backend name
+	Type      string  `json:"type"`      // ML backend type
+	URI       string  `json:"uri"`       // ML backend URI
+	Healthy   bool    `json:"healthy"`   // ML backend passed its health probes
+	Failures  int     `json:"failures"`  // number of consecutive failed probes
+	Error     string  `json:"error"`     // error of last failed probe
+	Latency   float64 `json:"latency"`   // latency of last probe in milliseconds
+	LastCheck int64   `json:"lastcheck"` // timestamp of last probe
+	Requests  int     `json:"requests"`  // number of outstanding requests
+	Breaker   string  `json:"breaker"`   // state of circuit breaker: closed, open or half-open
+}
+
+// RoutingArm defines arm of routing rule, i.e. ML model version and its
+// share of prediction traffic
+type RoutingArm struct {
+	Version string `json:"version"` // ML model version or its alias
+	Weight  int    `json:"weight"`  // relative weight of the arm, e.g. 90 and 10
+}
+
+// RoutingRule defines canary or A/B split of predictions of ML model between
+// its versions, it applies to prediction requests which do not ask for
+// specific ML model version
+type RoutingRule struct {
+	Model  string       `json:"model"`  // ML model name
+	Type   string       `json:"type"`   // ML model type
+	Sticky string       `json:"sticky"` // sticky routing: caller, key or empty for random split
+	Arms   []RoutingArm `json:"arms"`   // versions of ML model and their weights
+}
+
+// RoutingOutcome defines outcome of ML prediction routed by routing rule
+type RoutingOutcome struct {
+	Model     string  `json:"model"`     // ML model name
+	Type      string  `json:"type"`      // ML model type
+	Version   string  `json:"version"`   // ML model version which served prediction
+	User      string  `json:"user"`      // user who requested prediction
+	Status    int     `json:"status"`    // HTTP status code of prediction
+	Error     string  `json:"error"`     // error of failed prediction
+	Latency   float64 `json:"latency"`   // latency of prediction in milliseconds
+	Timestamp int64   `json:"timestamp"` // prediction timestamp
+}
+
+// RoutingReport defines evaluation of routing rule arm
+type RoutingReport struct {
+	Version  string  `json:"version"`  // ML model version
+	Weight   int     `json:"weight"`   // configured weight of the arm
+	Requests int     `json:"requests"` // number of routed predictions
+	Share    float64 `json:"share"`    // observed share of routed predictions
+	Errors   int     `json:"errors"`   // number of failed predictions
+	Latency  float64 `json:"latency"`  // mean latency of predictions in milliseconds
+}
+
+// ShadowRule defines shadow deployment of ML model, i.e. version of ML model
+// which receives copy of predictions of other versions without affecting
+// their responses
+type ShadowRule struct {
+	Model   string  `json:"model"`   // ML model name
+	Type    string  `json:"type"`    // ML model type
+	Version string  `json:"version"` // shadow version of ML model or its alias
+	Sample  float64 `json:"sample"`  // fraction of predictions copied to shadow version, default 1
+}
+
+// ShadowResult defines outputs of primary and shadow versions of ML model for
+// the same prediction input
+type ShadowResult struct {
+	Model          string  `json:"model"`          // ML model name
+	Type           string  `json:"type"`           // ML model type
+	Primary        string  `json:"primary"`        // ML model version which served prediction
+	Shadow         string  `json:"shadow"`         // shadow version of ML model
+	PrimaryOutput  string  `json:"primaryoutput"`  // prediction output of primary version
+	ShadowOutput   string  `json:"shadowoutput"`   // prediction output of shadow version
+	PrimaryLatency float64 `json:"primarylatency"` // latency of primary version in milliseconds
+	ShadowLatency  float64 `json:"shadowlatency"`  // latency of shadow version in milliseconds
+	Agree          bool    `json:"agree"`          // outputs of primary and shadow versions agree
+	Error          string  `json:"error"`          // error of shadow prediction
+	Timestamp      int64   `json:"timestamp"`      // prediction timestamp
+}
+
+// ShadowReport defines comparison of primary and shadow versions of ML model
+type ShadowReport struct {
+	Primary          string  `json:"primary"`          // ML model version which served predictions
+	Shadow           string  `json:"shadow"`           // shadow version of ML model
+	Requests         int     `json:"requests"`         // number of shadowed predictions
+	Errors           int     `json:"errors"`           // number of failed shadow predictions
+	Disagreements    int     `json:"disagreements"`    // number of predictions with different outputs
+	DisagreementRate float64 `json:"disagreementrate"` // fraction of successful shadow predictions which disagree
+	PrimaryLatency   float64 `json:"primarylatency"`   // mean latency of primary version in milliseconds
+	ShadowLatency    float64 `json:"shadowlatency"`    // mean latency of shadow version in milliseconds
+	LatencyDiff      float64 `json:"latencydiff"`      // mean latency difference of shadow and primary versions
+}
+
+// AuditEvent defines event of ML model lifecycle in MLHub audit log
+type AuditEvent struct {
+	Event     string `json:"event"`     // event name, e.g. upload, update, promote, download or delete
+	User      string `json:"user"`      // user who performed the action
+	Timestamp int64  `json:"timestamp"` // event timestamp
+	Model     string `json:"model"`     // ML model name
+	Type      string `json:"type"`      // ML model type
+	Version   string `json:"version"`   // ML model version
+	ClientIP  string `json:"clientip"`  // IP address of the client
+	Outcome   string `json:"outcome"`   // outcome of the action: success or failure
+	Error     string `json:"error"`     // error of failed action
+	Details   string `json:"details"`   // event details, e.g. alias of promoted version
+}
+
+// Webhook defines subscription of downstream system to events of ML models,
+// empty filters match all events, ML models and disciplines
+type Webhook struct {
+	ID         string   `json:"id"`         // webhook ID
+	URL        string   `json:"url"`        // URL which receives events
+	Secret     string   `json:"secret"`     // secret of HMAC signature of event payloads
+	Events     []string `json:"events"`     // events to deliver: upload, update, promote, delete or restore
+	Model      string   `json:"model"`      // ML model name filter
+	Discipline string   `json:"discipline"` // scientific discipline filter
+	User       string   `json:"user"`       // user who created webhook
+	Timestamp  int64    `json:"timestamp"`  // creation timestamp
+}
+
+// WebhookEvent defines payload of event delivered to webhook
+type WebhookEvent struct {
+	ID        string `json:"id"`        // event ID
+	Event     string `json:"event"`     // event name
+	Timestamp int64  `json:"timestamp"` // event timestamp
+	Details   string `json:"details"`   // event details, e.g. alias of promoted version
+	Record    Record `json:"record"`    // ML record of the event
+}
+
+// WebhookDelivery defines attempt to deliver event to webhook
+type WebhookDelivery struct {
+	Webhook   string `json:"webhook"`   // webhook ID
+	Event     string `json:"event"`     // event name
+	EventID   string `json:"eventid"`   // event ID
+	Model     string `json:"model"`     // ML model name
+	Attempt   int    `json:"attempt"`   // delivery attempt, starting from 1
+	Status    int    `json:"status"`    // HTTP status code of webhook response
+	Error     string `json:"error"`     // delivery error
+	Delivered bool   `json:"delivered"` // event is delivered
+	Timestamp int64  `json:"timestamp"` // attempt timestamp
+}
+
+// ConsistencyIssue defines inconsistency between MLHub storage, meta-data
+// and ML backends
+type ConsistencyIssue struct {
+	Kind     string `json:"kind"`     // orphaned-bundle, missing-bundle or missing-deployment
+	Model    string `json:"model"`    // ML model name
+	Type     string `json:"type"`     // ML model type
+	Version  string `json:"version"`  // ML model version
+	Path     string `json:"path"`     // path of ML bundle in storage
+	Replica  string `json:"replica"`  // ML backend replica which lacks ML model
+	Repaired bool   `json:"repaired"` // issue is repaired
+	Error    string `json:"error"`    // error of repair
+}
+
+// ConsistencyReport defines result of consistency check of MLHub storage,
+// meta-data and ML backends
+type ConsistencyReport struct {
+	Policy   string             `json:"policy"`   // report or repair
+	Started  int64              `json:"started"`  // check start timestamp
+	Finished int64              `json:"finished"` // check end timestamp
+	Records  int                `json:"records"`  // number of checked meta-data records
+	Bundles  int                `json:"bundles"`  // number of checked ML model versions in storage
+	Issues   []ConsistencyIssue `json:"issues"`   // found issues
+}
+
+// BackupManifest defines manifest of MLHub backup archive
+type BackupManifest struct {
+	Format  int           `json:"format"`  // format version of backup archive
+	Created int64         `json:"created"` // backup timestamp
+	Source  string        `json:"source"`  // URL of MLHub which created backup
+	Models  []BackupEntry `json:"models"`  // ML model versions in backup archive
+	Aliases int           `json:"aliases"` // number of aliases in backup archive
+}
+
+// BackupEntry defines ML model version in backup archive
+type BackupEntry struct {
+	Model   string `json:"model"`   // ML model name
+	Type    string `json:"type"`    // ML model type
+	Version string `json:"version"` // ML model version
+	Bundle  string `json:"bundle"`  // ML bundle file name
+	Digest  string `json:"digest"`  // ML bundle digest, e.g. sha256:123
+	Path    string `json:"path"`    // directory of ML model version in backup archive
+}
+
+// ImportResult defines outcome of import of ML model version or alias
+type ImportResult struct {
+	Model   string `json:"model"`   // ML model name
+	Type    string `json:"type"`    // ML model type
+	Version string `json:"version"` // ML model version
+	Alias   string `json:"alias"`   // alias name for imported aliases
+	Status  string `json:"status"`  // imported, overwritten, skipped or failed
+	Error   string `json:"error"`   // import error
+}
+
+// ImportReport defines outcome of import of MLHub backup archive
+type ImportReport struct {
+	Conflict string         `json:"conflict"` // conflict policy: skip or overwrite
+	Results  []ImportResult `json:"results"`  // outcomes of imported ML models and aliases
+}
+
+// Mirror defines state of mirroring of ML models from remote MLHub
+type Mirror struct {
+	Remote string `json:"remote"` // remote name
+	URL    string `json:"url"`    // remote MLHub URL
+	Synced int64  `json:"synced"` // change timestamp of remote ML models mirrored so far
+	Pulled int64  `json:"pulled"` // timestamp of the last pull from remote MLHub
+	Error  string `json:"error"`  // error of the last pull
+}
+
+// MirrorReport defines outcome of pull of ML models from remote MLHub
+type MirrorReport struct {
+	Remote   string         `json:"remote"`   // remote name
+	URL      string         `json:"url"`      // remote MLHub URL
+	Since    int64          `json:"since"`    // change timestamp pull started from
+	Synced   int64          `json:"synced"`   // change timestamp of remote ML models mirrored so far
+	Started  int64          `json:"started"`  // pull start timestamp
+	Finished int64          `json:"finished"` // pull end timestamp
+	Results  []ImportResult `json:"results"`  // outcomes of mirrored ML models
+}
+
+// HFImport defines import of ML model from Hugging Face-style repository,
+// either from Hugging Face Hub compatible HTTP endpoint or from local directory
+type HFImport struct {
+	Repo       string `json:"repo"`       // repository name, e.g. google/vit-base-patch16-224
+	Endpoint   string `json:"endpoint"`   // Hugging Face Hub compatible endpoint, default https://huggingface.co
+	Revision   string `json:"revision"`   // repository revision, default main
+	Token      string `json:"token"`      // optional access token of gated repositories
+	Dir        string `json:"dir"`        // local directory with repository layout on MLHub server
+	Model      string `json:"model"`      // ML model name, default repository name
+	Version    string `json:"version"`    // ML model version, default latest
+	Type       string `json:"type"`       // ML model type, inferred from repository if empty
+	Backend    string `json:"backend"`    // ML backend name, inferred from ML model type if empty
+	Discipline string `json:"discipline"` // ML model discipline
+}
+
+// MLTypes defines supported ML data types
+var MLTypes = []string{"TensorFlow", "PyTorch", "ScikitLearn", "ONNX"}
@@ -0,0 +1,1011 @@
+// MLHub gRPC service definition
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+// To regenerate Go code use:
+// protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative mlhubpb/mlhub.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: mlhubpb/mlhub.proto
+
+package mlhubpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Provenance represents ML model provenance
+type Provenance struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Datasets      []string               `protobuf:"bytes,1,rep,name=datasets,proto3" json:"datasets,omitempty"`                                // DIDs of training datasets
+	Evaluation    []string               `protobuf:"bytes,2,rep,name=evaluation,proto3" json:"evaluation,omitempty"`                            // DIDs of evaluation datasets
+	Commit        string                 `protobuf:"bytes,3,opt,name=commit,proto3" json:"commit,omitempty"`                                    // code commit used for training
+	ParentModel   string                 `protobuf:"bytes,4,opt,name=parent_model,json=parentModel,proto3" json:"parent_model,omitempty"`       // parent ML model name
+	ParentVersion string                 `protobuf:"bytes,5,opt,name=parent_version,json=parentVersion,proto3" json:"parent_version,omitempty"` // parent ML model version
+	Parameters    string                 `protobuf:"bytes,6,opt,name=parameters,proto3" json:"parameters,omitempty"`                            // training parameters in JSON format
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Provenance) Reset() {
+	*x = Provenance{}
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Provenance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Provenance) ProtoMessage() {}
+
+func (x *Provenance) ProtoReflect() protoreflect.Message {
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Provenance.ProtoReflect.Descriptor instead.
+func (*Provenance) Descriptor() ([]byte, []int) {
+	return file_mlhubpb_mlhub_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Provenance) GetDatasets() []string {
+	if x != nil {
+		return x.Datasets
+	}
+	return nil
+}
+
+func (x *Provenance) GetEvaluation() []string {
+	if x != nil {
+		return x.Evaluation
+	}
+	return nil
+}
+
+func (x *Provenance) GetCommit() string {
+	if x != nil {
+		return x.Commit
+	}
+	return ""
+}
+
+func (x *Provenance) GetParentModel() string {
+	if x != nil {
+		return x.ParentModel
+	}
+	return ""
+}
+
+func (x *Provenance) GetParentVersion() string {
+	if x != nil {
+		return x.ParentVersion
+	}
+	return ""
+}
+
+func (x *Provenance) GetParameters() string {
+	if x != nil {
+		return x.Parameters
+	}
+	return ""
+}
+
+// Record represents ML model meta-data record
+type Record struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Model         string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`                        // ML model name
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`                          // ML model type
+	Backend       string                 `protobuf:"bytes,3,opt,name=backend,proto3" json:"backend,omitempty"`                    // ML backend name
+	Version       string                 `protobuf:"bytes,4,opt,name=version,proto3" json:"version,omitempty"`                    // ML model version
+	Description   string                 `protobuf:"bytes,5,opt,name=description,proto3" json:"description,omitempty"`            // ML model description
+	Reference     string                 `protobuf:"bytes,6,opt,name=reference,proto3" json:"reference,omitempty"`                // ML model reference URL
+	Discipline    string                 `protobuf:"bytes,7,opt,name=discipline,proto3" json:"discipline,omitempty"`              // ML model discipline
+	Bundle        string                 `protobuf:"bytes,8,opt,name=bundle,proto3" json:"bundle,omitempty"`                      // ML bundle file name
+	Digest        string                 `protobuf:"bytes,9,opt,name=digest,proto3" json:"digest,omitempty"`                      // ML bundle digest
+	UserName      string                 `protobuf:"bytes,10,opt,name=user_name,json=userName,proto3" json:"user_name,omitempty"` // ML model author
+	Provenance    *Provenance            `protobuf:"bytes,11,opt,name=provenance,proto3" json:"provenance,omitempty"`             // ML model provenance
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Record) Reset() {
+	*x = Record{}
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Record) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Record) ProtoMessage() {}
+
+func (x *Record) ProtoReflect() protoreflect.Message {
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Record.ProtoReflect.Descriptor instead.
+func (*Record) Descriptor() ([]byte, []int) {
+	return file_mlhubpb_mlhub_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Record) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *Record) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Record) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *Record) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Record) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Record) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+func (x *Record) GetDiscipline() string {
+	if x != nil {
+		return x.Discipline
+	}
+	return ""
+}
+
+func (x *Record) GetBundle() string {
+	if x != nil {
+		return x.Bundle
+	}
+	return ""
+}
+
+func (x *Record) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+func (x *Record) GetUserName() string {
+	if x != nil {
+		return x.UserName
+	}
+	return ""
+}
+
+func (x *Record) GetProvenance() *Provenance {
+	if x != nil {
+		return x.Provenance
+	}
+	return nil
+}
+
+// ListModelsRequest represents query of ML models
+type ListModelsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`           // free text query of ML model name and description
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`             // ML model type
+	Backend       string                 `protobuf:"bytes,3,opt,name=backend,proto3" json:"backend,omitempty"`       // ML backend name
+	Discipline    string                 `protobuf:"bytes,4,opt,name=discipline,proto3" json:"discipline,omitempty"` // ML model discipline
+	User          string                 `protobuf:"bytes,5,opt,name=user,proto3" json:"user,omitempty"`             // ML model author
+	Idx           int32                  `protobuf:"varint,6,opt,name=idx,proto3" json:"idx,omitempty"`              // index of first record
+	Limit         int32                  `protobuf:"varint,7,opt,name=limit,proto3" json:"limit,omitempty"`          // number of records, zero means all
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListModelsRequest) Reset() {
+	*x = ListModelsRequest{}
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListModelsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListModelsRequest) ProtoMessage() {}
+
+func (x *ListModelsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListModelsRequest.ProtoReflect.Descriptor instead.
+func (*ListModelsRequest) Descriptor() ([]byte, []int) {
+	return file_mlhubpb_mlhub_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListModelsRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ListModelsRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ListModelsRequest) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *ListModelsRequest) GetDiscipline() string {
+	if x != nil {
+		return x.Discipline
+	}
+	return ""
+}
+
+func (x *ListModelsRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *ListModelsRequest) GetIdx() int32 {
+	if x != nil {
+		return x.Idx
+	}
+	return 0
+}
+
+func (x *ListModelsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+// ListModelsResponse represents list of ML models
+type ListModelsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Records       []*Record              `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListModelsResponse) Reset() {
+	*x = ListModelsResponse{}
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListModelsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListModelsResponse) ProtoMessage() {}
+
+func (x *ListModelsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListModelsResponse.ProtoReflect.Descriptor instead.
+func (*ListModelsResponse) Descriptor() ([]byte, []int) {
+	return file_mlhubpb_mlhub_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListModelsResponse) GetRecords() []*Record {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+// GetModelRequest represents ML model specification
+type GetModelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Model         string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`     // ML model name
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`       // ML model type
+	Version       string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"` // ML model version or alias
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetModelRequest) Reset() {
+	*x = GetModelRequest{}
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetModelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetModelRequest) ProtoMessage() {}
+
+func (x *GetModelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetModelRequest.ProtoReflect.Descriptor instead.
+func (*GetModelRequest) Descriptor() ([]byte, []int) {
+	return file_mlhubpb_mlhub_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetModelRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *GetModelRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *GetModelRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+// PredictRequest represents ML prediction request
+type PredictRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Model   string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`     // ML model name
+	Type    string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`       // ML model type
+	Version string                 `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"` // ML model version or alias
+	Backend string                 `protobuf:"bytes,4,opt,name=backend,proto3" json:"backend,omitempty"` // ML backend name
+	// Types that are valid to be assigned to Input:
+	//
+	//	*PredictRequest_Json
+	//	*PredictRequest_Data
+	Input            isPredictRequest_Input `protobuf_oneof:"input"`
+	FileName         string                 `protobuf:"bytes,7,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`                          // input file name
+	Field            string                 `protobuf:"bytes,8,opt,name=field,proto3" json:"field,omitempty"`                                                // form field name of input file, default image
+	RecordProvenance bool                   `protobuf:"varint,9,opt,name=record_provenance,json=recordProvenance,proto3" json:"record_provenance,omitempty"` // record provenance of prediction
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *PredictRequest) Reset() {
+	*x = PredictRequest{}
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PredictRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PredictRequest) ProtoMessage() {}
+
+func (x *PredictRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PredictRequest.ProtoReflect.Descriptor instead.
+func (*PredictRequest) Descriptor() ([]byte, []int) {
+	return file_mlhubpb_mlhub_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PredictRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *PredictRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *PredictRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *PredictRequest) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *PredictRequest) GetInput() isPredictRequest_Input {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+func (x *PredictRequest) GetJson() string {
+	if x != nil {
+		if x, ok := x.Input.(*PredictRequest_Json); ok {
+			return x.Json
+		}
+	}
+	return ""
+}
+
+func (x *PredictRequest) GetData() []byte {
+	if x != nil {
+		if x, ok := x.Input.(*PredictRequest_Data); ok {
+			return x.Data
+		}
+	}
+	return nil
+}
+
+func (x *PredictRequest) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *PredictRequest) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *PredictRequest) GetRecordProvenance() bool {
+	if x != nil {
+		return x.RecordProvenance
+	}
+	return false
+}
+
+type isPredictRequest_Input interface {
+	isPredictRequest_Input()
+}
+
+type PredictRequest_Json struct {
+	Json string `protobuf:"bytes,5,opt,name=json,proto3,oneof"` // JSON input, e.g. [1,2,3]
+}
+
+type PredictRequest_Data struct {
+	Data []byte `protobuf:"bytes,6,opt,name=data,proto3,oneof"` // input file content, e.g. image
+}
+
+func (*PredictRequest_Json) isPredictRequest_Input() {}
+
+func (*PredictRequest_Data) isPredictRequest_Input() {}
+
+// PredictResponse represents ML prediction
+type PredictResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Output        []byte                 `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`                              // prediction output
+	ContentType   string                 `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"` // content type of prediction output
+	Provenance    string                 `protobuf:"bytes,3,opt,name=provenance,proto3" json:"provenance,omitempty"`                      // provenance ID of prediction (if it was recorded)
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`                                // prediction error of streaming predictions
+	Version       string                 `protobuf:"bytes,5,opt,name=version,proto3" json:"version,omitempty"`                            // ML model version which served prediction
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PredictResponse) Reset() {
+	*x = PredictResponse{}
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PredictResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PredictResponse) ProtoMessage() {}
+
+func (x *PredictResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PredictResponse.ProtoReflect.Descriptor instead.
+func (*PredictResponse) Descriptor() ([]byte, []int) {
+	return file_mlhubpb_mlhub_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PredictResponse) GetOutput() []byte {
+	if x != nil {
+		return x.Output
+	}
+	return nil
+}
+
+func (x *PredictResponse) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *PredictResponse) GetProvenance() string {
+	if x != nil {
+		return x.Provenance
+	}
+	return ""
+}
+
+func (x *PredictResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *PredictResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+// UploadBundleRequest represents part of ML model upload
+type UploadBundleRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*UploadBundleRequest_Header
+	//	*UploadBundleRequest_Chunk
+	Payload       isUploadBundleRequest_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadBundleRequest) Reset() {
+	*x = UploadBundleRequest{}
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadBundleRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadBundleRequest) ProtoMessage() {}
+
+func (x *UploadBundleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadBundleRequest.ProtoReflect.Descriptor instead.
+func (*UploadBundleRequest) Descriptor() ([]byte, []int) {
+	return file_mlhubpb_mlhub_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UploadBundleRequest) GetPayload() isUploadBundleRequest_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *UploadBundleRequest) GetHeader() *UploadHeader {
+	if x != nil {
+		if x, ok := x.Payload.(*UploadBundleRequest_Header); ok {
+			return x.Header
+		}
+	}
+	return nil
+}
+
+func (x *UploadBundleRequest) GetChunk() []byte {
+	if x != nil {
+		if x, ok := x.Payload.(*UploadBundleRequest_Chunk); ok {
+			return x.Chunk
+		}
+	}
+	return nil
+}
+
+type isUploadBundleRequest_Payload interface {
+	isUploadBundleRequest_Payload()
+}
+
+type UploadBundleRequest_Header struct {
+	Header *UploadHeader `protobuf:"bytes,1,opt,name=header,proto3,oneof"` // ML model record and bundle file name
+}
+
+type UploadBundleRequest_Chunk struct {
+	Chunk []byte `protobuf:"bytes,2,opt,name=chunk,proto3,oneof"` // chunk of ML bundle
+}
+
+func (*UploadBundleRequest_Header) isUploadBundleRequest_Payload() {}
+
+func (*UploadBundleRequest_Chunk) isUploadBundleRequest_Payload() {}
+
+// UploadHeader represents ML model upload meta-data
+type UploadHeader struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Record        *Record                `protobuf:"bytes,1,opt,name=record,proto3" json:"record,omitempty"`                     // ML model record
+	FileName      string                 `protobuf:"bytes,2,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"` // ML bundle file name
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadHeader) Reset() {
+	*x = UploadHeader{}
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadHeader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadHeader) ProtoMessage() {}
+
+func (x *UploadHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadHeader.ProtoReflect.Descriptor instead.
+func (*UploadHeader) Descriptor() ([]byte, []int) {
+	return file_mlhubpb_mlhub_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UploadHeader) GetRecord() *Record {
+	if x != nil {
+		return x.Record
+	}
+	return nil
+}
+
+func (x *UploadHeader) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+// UploadBundleResponse represents result of ML model upload
+type UploadBundleResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Record        *Record                `protobuf:"bytes,1,opt,name=record,proto3" json:"record,omitempty"` // uploaded ML model record
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`    // size of uploaded ML bundle
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadBundleResponse) Reset() {
+	*x = UploadBundleResponse{}
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadBundleResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadBundleResponse) ProtoMessage() {}
+
+func (x *UploadBundleResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_mlhubpb_mlhub_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadBundleResponse.ProtoReflect.Descriptor instead.
+func (*UploadBundleResponse) Descriptor() ([]byte, []int) {
+	return file_mlhubpb_mlhub_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *UploadBundleResponse) GetRecord() *Record {
+	if x != nil {
+		return x.Record
+	}
+	return nil
+}
+
+func (x *UploadBundleResponse) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+var File_mlhubpb_mlhub_proto protoreflect.FileDescriptor
+
+const file_mlhubpb_mlhub_proto_rawDesc = "" +
+	"\n" +
+	"\x13mlhubpb/mlhub.proto\x12\bmlhub.v1\"\xca\x01\n" +
+	"\n" +
+	"Provenance\x12\x1a\n" +
+	"\bdatasets\x18\x01 \x03(\tR\bdatasets\x12\x1e\n" +
+	"\n" +
+	"evaluation\x18\x02 \x03(\tR\n" +
+	"evaluation\x12\x16\n" +
+	"\x06commit\x18\x03 \x01(\tR\x06commit\x12!\n" +
+	"\fparent_model\x18\x04 \x01(\tR\vparentModel\x12%\n" +
+	"\x0eparent_version\x18\x05 \x01(\tR\rparentVersion\x12\x1e\n" +
+	"\n" +
+	"parameters\x18\x06 \x01(\tR\n" +
+	"parameters\"\xc9\x02\n" +
+	"\x06Record\x12\x14\n" +
+	"\x05model\x18\x01 \x01(\tR\x05model\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x18\n" +
+	"\abackend\x18\x03 \x01(\tR\abackend\x12\x18\n" +
+	"\aversion\x18\x04 \x01(\tR\aversion\x12 \n" +
+	"\vdescription\x18\x05 \x01(\tR\vdescription\x12\x1c\n" +
+	"\treference\x18\x06 \x01(\tR\treference\x12\x1e\n" +
+	"\n" +
+	"discipline\x18\a \x01(\tR\n" +
+	"discipline\x12\x16\n" +
+	"\x06bundle\x18\b \x01(\tR\x06bundle\x12\x16\n" +
+	"\x06digest\x18\t \x01(\tR\x06digest\x12\x1b\n" +
+	"\tuser_name\x18\n" +
+	" \x01(\tR\buserName\x124\n" +
+	"\n" +
+	"provenance\x18\v \x01(\v2\x14.mlhub.v1.ProvenanceR\n" +
+	"provenance\"\xb3\x01\n" +
+	"\x11ListModelsRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x18\n" +
+	"\abackend\x18\x03 \x01(\tR\abackend\x12\x1e\n" +
+	"\n" +
+	"discipline\x18\x04 \x01(\tR\n" +
+	"discipline\x12\x12\n" +
+	"\x04user\x18\x05 \x01(\tR\x04user\x12\x10\n" +
+	"\x03idx\x18\x06 \x01(\x05R\x03idx\x12\x14\n" +
+	"\x05limit\x18\a \x01(\x05R\x05limit\"@\n" +
+	"\x12ListModelsResponse\x12*\n" +
+	"\arecords\x18\x01 \x03(\v2\x10.mlhub.v1.RecordR\arecords\"U\n" +
+	"\x0fGetModelRequest\x12\x14\n" +
+	"\x05model\x18\x01 \x01(\tR\x05model\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\"\x83\x02\n" +
+	"\x0ePredictRequest\x12\x14\n" +
+	"\x05model\x18\x01 \x01(\tR\x05model\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\x12\x18\n" +
+	"\abackend\x18\x04 \x01(\tR\abackend\x12\x14\n" +
+	"\x04json\x18\x05 \x01(\tH\x00R\x04json\x12\x14\n" +
+	"\x04data\x18\x06 \x01(\fH\x00R\x04data\x12\x1b\n" +
+	"\tfile_name\x18\a \x01(\tR\bfileName\x12\x14\n" +
+	"\x05field\x18\b \x01(\tR\x05field\x12+\n" +
+	"\x11record_provenance\x18\t \x01(\bR\x10recordProvenanceB\a\n" +
+	"\x05input\"\x9c\x01\n" +
+	"\x0fPredictResponse\x12\x16\n" +
+	"\x06output\x18\x01 \x01(\fR\x06output\x12!\n" +
+	"\fcontent_type\x18\x02 \x01(\tR\vcontentType\x12\x1e\n" +
+	"\n" +
+	"provenance\x18\x03 \x01(\tR\n" +
+	"provenance\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12\x18\n" +
+	"\aversion\x18\x05 \x01(\tR\aversion\"j\n" +
+	"\x13UploadBundleRequest\x120\n" +
+	"\x06header\x18\x01 \x01(\v2\x16.mlhub.v1.UploadHeaderH\x00R\x06header\x12\x16\n" +
+	"\x05chunk\x18\x02 \x01(\fH\x00R\x05chunkB\t\n" +
+	"\apayload\"U\n" +
+	"\fUploadHeader\x12(\n" +
+	"\x06record\x18\x01 \x01(\v2\x10.mlhub.v1.RecordR\x06record\x12\x1b\n" +
+	"\tfile_name\x18\x02 \x01(\tR\bfileName\"T\n" +
+	"\x14UploadBundleResponse\x12(\n" +
+	"\x06record\x18\x01 \x01(\v2\x10.mlhub.v1.RecordR\x06record\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size2\xe4\x02\n" +
+	"\x05MLHub\x12G\n" +
+	"\n" +
+	"ListModels\x12\x1b.mlhub.v1.ListModelsRequest\x1a\x1c.mlhub.v1.ListModelsResponse\x127\n" +
+	"\bGetModel\x12\x19.mlhub.v1.GetModelRequest\x1a\x10.mlhub.v1.Record\x12>\n" +
+	"\aPredict\x12\x18.mlhub.v1.PredictRequest\x1a\x19.mlhub.v1.PredictResponse\x12H\n" +
+	"\rPredictStream\x12\x18.mlhub.v1.PredictRequest\x1a\x19.mlhub.v1.PredictResponse(\x010\x01\x12O\n" +
+	"\fUploadBundle\x12\x1d.mlhub.v1.UploadBundleRequest\x1a\x1e.mlhub.v1.UploadBundleResponse(\x01B)Z'github.com/CHESSComputing/MLHub/mlhubpbb\x06proto3"
+
+var (
+	file_mlhubpb_mlhub_proto_rawDescOnce sync.Once
+	file_mlhubpb_mlhub_proto_rawDescData []byte
+)
+
+func file_mlhubpb_mlhub_proto_rawDescGZIP() []byte {
+	file_mlhubpb_mlhub_proto_rawDescOnce.Do(func() {
+		file_mlhubpb_mlhub_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_mlhubpb_mlhub_proto_rawDesc), len(file_mlhubpb_mlhub_proto_rawDesc)))
+	})
+	return file_mlhubpb_mlhub_proto_rawDescData
+}
+
+var file_mlhubpb_mlhub_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_mlhubpb_mlhub_proto_goTypes = []any{
+	(*Provenance)(nil),           // 0: mlhub.v1.Provenance
+	(*Record)(nil),               // 1: mlhub.v1.Record
+	(*ListModelsRequest)(nil),    // 2: mlhub.v1.ListModelsRequest
+	(*ListModelsResponse)(nil),   // 3: mlhub.v1.ListModelsResponse
+	(*GetModelRequest)(nil),      // 4: mlhub.v1.GetModelRequest
+	(*PredictRequest)(nil),       // 5: mlhub.v1.PredictRequest
+	(*PredictResponse)(nil),      // 6: mlhub.v1.PredictResponse
+	(*UploadBundleRequest)(nil),  // 7: mlhub.v1.UploadBundleRequest
+	(*UploadHeader)(nil),         // 8: mlhub.v1.UploadHeader
+	(*UploadBundleResponse)(nil), // 9: mlhub.v1.UploadBundleResponse
+}
+var file_mlhubpb_mlhub_proto_depIdxs = []int32{
+	0,  // 0: mlhub.v1.Record.provenance:type_name -> mlhub.v1.Provenance
+	1,  // 1: mlhub.v1.ListModelsResponse.records:type_name -> mlhub.v1.Record
+	8,  // 2: mlhub.v1.UploadBundleRequest.header:type_name -> mlhub.v1.UploadHeader
+	1,  // 3: mlhub.v1.UploadHeader.record:type_name -> mlhub.v1.Record
+	1,  // 4: mlhub.v1.UploadBundleResponse.record:type_name -> mlhub.v1.Record
+	2,  // 5: mlhub.v1.MLHub.ListModels:input_type -> mlhub.v1.ListModelsRequest
+	4,  // 6: mlhub.v1.MLHub.GetModel:input_type -> mlhub.v1.GetModelRequest
+	5,  // 7: mlhub.v1.MLHub.Predict:input_type -> mlhub.v1.PredictRequest
+	5,  // 8: mlhub.v1.MLHub.PredictStream:input_type -> mlhub.v1.PredictRequest
+	7,  // 9: mlhub.v1.MLHub.UploadBundle:input_type -> mlhub.v1.UploadBundleRequest
+	3,  // 10: mlhub.v1.MLHub.ListModels:output_type -> mlhub.v1.ListModelsResponse
+	1,  // 11: mlhub.v1.MLHub.GetModel:output_type -> mlhub.v1.Record
+	6,  // 12: mlhub.v1.MLHub.Predict:output_type -> mlhub.v1.PredictResponse
+	6,  // 13: mlhub.v1.MLHub.PredictStream:output_type -> mlhub.v1.PredictResponse
+	9,  // 14: mlhub.v1.MLHub.UploadBundle:output_type -> mlhub.v1.UploadBundleResponse
+	10, // [10:15] is the sub-list for method output_type
+	5,  // [5:10] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_mlhubpb_mlhub_proto_init() }
+func file_mlhubpb_mlhub_proto_init() {
+	if File_mlhubpb_mlhub_proto != nil {
+		return
+	}
+	file_mlhubpb_mlhub_proto_msgTypes[5].OneofWrappers = []any{
+		(*PredictRequest_Json)(nil),
+		(*PredictRequest_Data)(nil),
+	}
+	file_mlhubpb_mlhub_proto_msgTypes[7].OneofWrappers = []any{
+		(*UploadBundleRequest_Header)(nil),
+		(*UploadBundleRequest_Chunk)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_mlhubpb_mlhub_proto_rawDesc), len(file_mlhubpb_mlhub_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_mlhubpb_mlhub_proto_goTypes,
+		DependencyIndexes: file_mlhubpb_mlhub_proto_depIdxs,
+		MessageInfos:      file_mlhubpb_mlhub_proto_msgTypes,
+	}.Build()
+	File_mlhubpb_mlhub_proto = out.File
+	file_mlhubpb_mlhub_proto_goTypes = nil
+	file_mlhubpb_mlhub_proto_depIdxs = nil
+}
@@ -0,0 +1,291 @@
+// MLHub gRPC service definition
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+// To regenerate Go code use:
+// protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative mlhubpb/mlhub.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: mlhubpb/mlhub.proto
+
+package mlhubpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MLHub_ListModels_FullMethodName    = "/mlhub.v1.MLHub/ListModels"
+	MLHub_GetModel_FullMethodName      = "/mlhub.v1.MLHub/GetModel"
+	MLHub_Predict_FullMethodName       = "/mlhub.v1.MLHub/Predict"
+	MLHub_PredictStream_FullMethodName = "/mlhub.v1.MLHub/PredictStream"
+	MLHub_UploadBundle_FullMethodName  = "/mlhub.v1.MLHub/UploadBundle"
+)
+
+// MLHubClient is the client API for MLHub service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// MLHub service provides access to ML models and their predictions. Every
+// call should provide bearer token in authorization metadata, e.g.
+// authorization: Bearer <token>
+type MLHubClient interface {
+	// ListModels lists ML models matching given query
+	ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error)
+	// GetModel provides ML model record, version can be either version or alias
+	GetModel(ctx context.Context, in *GetModelRequest, opts ...grpc.CallOption) (*Record, error)
+	// Predict provides ML prediction for given input
+	Predict(ctx context.Context, in *PredictRequest, opts ...grpc.CallOption) (*PredictResponse, error)
+	// PredictStream provides ML predictions for stream of inputs, predictions
+	// are sent back in order of inputs
+	PredictStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[PredictRequest, PredictResponse], error)
+	// UploadBundle uploads ML model, the first message should provide ML model
+	// record and bundle file name, the following messages provide bundle chunks
+	UploadBundle(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadBundleRequest, UploadBundleResponse], error)
+}
+
+type mLHubClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMLHubClient(cc grpc.ClientConnInterface) MLHubClient {
+	return &mLHubClient{cc}
+}
+
+func (c *mLHubClient) ListModels(ctx context.Context, in *ListModelsRequest, opts ...grpc.CallOption) (*ListModelsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListModelsResponse)
+	err := c.cc.Invoke(ctx, MLHub_ListModels_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mLHubClient) GetModel(ctx context.Context, in *GetModelRequest, opts ...grpc.CallOption) (*Record, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Record)
+	err := c.cc.Invoke(ctx, MLHub_GetModel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mLHubClient) Predict(ctx context.Context, in *PredictRequest, opts ...grpc.CallOption) (*PredictResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PredictResponse)
+	err := c.cc.Invoke(ctx, MLHub_Predict_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mLHubClient) PredictStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[PredictRequest, PredictResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MLHub_ServiceDesc.Streams[0], MLHub_PredictStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PredictRequest, PredictResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MLHub_PredictStreamClient = grpc.BidiStreamingClient[PredictRequest, PredictResponse]
+
+func (c *mLHubClient) UploadBundle(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadBundleRequest, UploadBundleResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MLHub_ServiceDesc.Streams[1], MLHub_UploadBundle_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UploadBundleRequest, UploadBundleResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MLHub_UploadBundleClient = grpc.ClientStreamingClient[UploadBundleRequest, UploadBundleResponse]
+
+// MLHubServer is the server API for MLHub service.
+// All implementations must embed UnimplementedMLHubServer
+// for forward compatibility.
+//
+// MLHub service provides access to ML models and their predictions. Every
+// call should provide bearer token in authorization metadata, e.g.
+// authorization: Bearer <token>
+type MLHubServer interface {
+	// ListModels lists ML models matching given query
+	ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error)
+	// GetModel provides ML model record, version can be either version or alias
+	GetModel(context.Context, *GetModelRequest) (*Record, error)
+	// Predict provides ML prediction for given input
+	Predict(context.Context, *PredictRequest) (*PredictResponse, error)
+	// PredictStream provides ML predictions for stream of inputs, predictions
+	// are sent back in order of inputs
+	PredictStream(grpc.BidiStreamingServer[PredictRequest, PredictResponse]) error
+	// UploadBundle uploads ML model, the first message should provide ML model
+	// record and bundle file name, the following messages provide bundle chunks
+	UploadBundle(grpc.ClientStreamingServer[UploadBundleRequest, UploadBundleResponse]) error
+	mustEmbedUnimplementedMLHubServer()
+}
+
+// UnimplementedMLHubServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMLHubServer struct{}
+
+func (UnimplementedMLHubServer) ListModels(context.Context, *ListModelsRequest) (*ListModelsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListModels not implemented")
+}
+func (UnimplementedMLHubServer) GetModel(context.Context, *GetModelRequest) (*Record, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetModel not implemented")
+}
+func (UnimplementedMLHubServer) Predict(context.Context, *PredictRequest) (*PredictResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Predict not implemented")
+}
+func (UnimplementedMLHubServer) PredictStream(grpc.BidiStreamingServer[PredictRequest, PredictResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method PredictStream not implemented")
+}
+func (UnimplementedMLHubServer) UploadBundle(grpc.ClientStreamingServer[UploadBundleRequest, UploadBundleResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method UploadBundle not implemented")
+}
+func (UnimplementedMLHubServer) mustEmbedUnimplementedMLHubServer() {}
+func (UnimplementedMLHubServer) testEmbeddedByValue()               {}
+
+// UnsafeMLHubServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MLHubServer will
+// result in compilation errors.
+type UnsafeMLHubServer interface {
+	mustEmbedUnimplementedMLHubServer()
+}
+
+func RegisterMLHubServer(s grpc.ServiceRegistrar, srv MLHubServer) {
+	// If the following call pancis, it indicates UnimplementedMLHubServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MLHub_ServiceDesc, srv)
+}
+
+func _MLHub_ListModels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListModelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MLHubServer).ListModels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MLHub_ListModels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MLHubServer).ListModels(ctx, req.(*ListModelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MLHub_GetModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetModelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MLHubServer).GetModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MLHub_GetModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MLHubServer).GetModel(ctx, req.(*GetModelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MLHub_Predict_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PredictRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MLHubServer).Predict(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MLHub_Predict_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MLHubServer).Predict(ctx, req.(*PredictRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MLHub_PredictStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MLHubServer).PredictStream(&grpc.GenericServerStream[PredictRequest, PredictResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MLHub_PredictStreamServer = grpc.BidiStreamingServer[PredictRequest, PredictResponse]
+
+func _MLHub_UploadBundle_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MLHubServer).UploadBundle(&grpc.GenericServerStream[UploadBundleRequest, UploadBundleResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MLHub_UploadBundleServer = grpc.ClientStreamingServer[UploadBundleRequest, UploadBundleResponse]
+
+// MLHub_ServiceDesc is the grpc.ServiceDesc for MLHub service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MLHub_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "mlhub.v1.MLHub",
+	HandlerType: (*MLHubServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListModels",
+			Handler:    _MLHub_ListModels_Handler,
+		},
+		{
+			MethodName: "GetModel",
+			Handler:    _MLHub_GetModel_Handler,
+		},
+		{
+			MethodName: "Predict",
+			Handler:    _MLHub_Predict_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PredictStream",
+			Handler:       _MLHub_PredictStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "UploadBundle",
+			Handler:       _MLHub_UploadBundle_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "mlhubpb/mlhub.proto",
+}
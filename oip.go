@@ -0,0 +1,340 @@
+package main
+
+// oip module provides client of ML backends which serve ONNX ML models via
+// Open Inference Protocol (KServe v2), e.g. NVIDIA Triton Inference Server.
+// ONNX models are loaded through model repository API of ML backend and
+// every ML model version is served under its own name
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// OIPModelFile defines name of ONNX model file within ML backend model repository
+const OIPModelFile = "model.onnx"
+
+// characters which are not allowed in model names of OIP ML backend
+var oipNamePattern = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// helper function to get name of ML model version on OIP ML backend. Model
+// name and version are sanitized for ML backend, therefore short hash of
+// their original values keeps names of distinct versions apart
+func oipModelName(rec Record) string {
+	sum := sha256.Sum256([]byte(rec.Model + "\x00" + rec.Version))
+	name := oipNamePattern.ReplaceAllString(rec.Model+"-"+rec.Version, "_")
+	return fmt.Sprintf("%s-%x", name, sum[:4])
+}
+
+// helper function to get OIP error message from ML backend response
+func oipError(rsp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(rsp.Body, 1<<16))
+	var oerr struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &oerr) == nil && oerr.Error != "" {
+		return fmt.Errorf("OIP response status %s, error %s", rsp.Status, oerr.Error)
+	}
+	return fmt.Errorf("OIP response status %s", rsp.Status)
+}
+
+// helper function to upload ONNX model of ML bundle to all healthy replicas
+// of OIP ML backend, it returns URIs of replicas which loaded the ML model
+func uploadBundleOIP(ctx context.Context, rec Record, bf BundleFile) ([]string, error) {
+	model, cleanup, err := onnxFile(bf)
+	if err != nil {
+		return nil, fmt.Errorf("[MLHub.main.uploadBundleOIP] onnxFile error: %w", err)
+	}
+	defer cleanup()
+	return uploadBundleReplicas(ctx, rec, model)
+}
+
+// helper function to load ONNX model on given OIP replica, ONNX model is
+// provided as file of model repository along with ML model configuration
+func uploadReplicaOIP(ctx context.Context, replica srvConfig.MLBackend, rec Record, bf BundleFile) error {
+	name := oipModelName(rec)
+	uri := fmt.Sprintf("%s/v2/repository/models/%s/load", replica.URI, url.PathEscape(name))
+	if Verbose > 0 {
+		log.Printf("upload model %s bundle to %s", rec.Model, uri)
+	}
+	file, err := bf.Open()
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.uploadReplicaOIP] bf.Open error: %w", err)
+	}
+	defer file.Close()
+	config, err := json.Marshal(map[string]any{"name": name, "backend": "onnxruntime"})
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.uploadReplicaOIP] json.Marshal error: %w", err)
+	}
+	param, err := json.Marshal(string(config))
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.uploadReplicaOIP] json.Marshal error: %w", err)
+	}
+	// ONNX model is streamed as base64 content of model repository file
+	body, writer := io.Pipe()
+	go func() {
+		_, err := fmt.Fprintf(writer, `{"parameters": {"config": %s, "file:1/%s": "`, param, OIPModelFile)
+		if err == nil {
+			enc := base64.NewEncoder(base64.StdEncoding, writer)
+			if _, err = io.Copy(enc, file); err == nil {
+				err = enc.Close()
+			}
+		}
+		if err == nil {
+			_, err = io.WriteString(writer, `"}}`)
+		}
+		writer.CloseWithError(err)
+	}()
+	defer body.Close()
+	// upload should complete even if client of MLHub went away
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), "POST", uri, body)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.uploadReplicaOIP] http.NewRequest error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// uploads are not retried since bundle is streamed to ML backend
+	rsp, err := httpClient(replica.Name).Do(req, false)
+	if err != nil {
+		backendFailure(replica, err)
+		return fmt.Errorf("[MLHub.main.uploadReplicaOIP] client.Do error: %w", err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return oipError(rsp)
+	}
+	return nil
+}
+
+// helper function to unload ML model version from given OIP replica
+func undeployReplicaOIP(ctx context.Context, replica srvConfig.MLBackend, rec Record) error {
+	uri := fmt.Sprintf("%s/v2/repository/models/%s/unload", replica.URI, url.PathEscape(oipModelName(rec)))
+	if Verbose > 0 {
+		log.Printf("undeploy model %s from %s", rec.Model, uri)
+	}
+	// undeploy should complete even if client of MLHub went away
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), "POST", uri, nil)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.undeployReplicaOIP] http.NewRequest error: %w", err)
+	}
+	rsp, err := httpClient(replica.Name).Do(req, true)
+	if err != nil {
+		backendFailure(replica, err)
+		return fmt.Errorf("[MLHub.main.undeployReplicaOIP] client.Do error: %w", err)
+	}
+	defer rsp.Body.Close()
+	// ML model which is not loaded by replica is already undeployed
+	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusNotFound {
+		return oipError(rsp)
+	}
+	return nil
+}
+
+// helper function to get ML models loaded by OIP replica, OIP replica
+// provides ready ML models of its model repository via index API
+func replicaModelsOIP(ctx context.Context, replica srvConfig.MLBackend) (map[string]bool, error) {
+	uri := replica.URI + "/v2/repository/index"
+	req, err := http.NewRequestWithContext(ctx, "POST", uri, bytes.NewReader([]byte(`{"ready": true}`)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rsp, err := httpClient(replica.Name).Do(req, true)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, oipError(rsp)
+	}
+	var index []struct {
+		Name  string `json:"name"`
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(rsp.Body).Decode(&index); err != nil {
+		return nil, err
+	}
+	models := make(map[string]bool)
+	for _, m := range index {
+		if m.State == "" || m.State == "READY" {
+			models[m.Name] = true
+		}
+	}
+	return models, nil
+}
+
+// helper function to get tensor specs from ML model signature, e.g. inputs
+// of ONNX model stored in MetaData database
+func tensorSpecs(v any) ([]TensorSpec, error) {
+	var specs []TensorSpec
+	data, err := json.Marshal(v)
+	if err != nil {
+		return specs, err
+	}
+	err = json.Unmarshal(data, &specs)
+	return specs, err
+}
+
+// helper function to get data and shape of tensor from nested JSON arrays
+func tensorData(v any) ([]any, []int64, error) {
+	var data []any
+	var shape []int64
+	var walk func(v any, depth int) error
+	walk = func(v any, depth int) error {
+		arr, ok := v.([]any)
+		if !ok {
+			if depth != len(shape) {
+				return errors.New("tensor input should not be ragged array")
+			}
+			data = append(data, v)
+			return nil
+		}
+		if depth == len(shape) {
+			if len(data) > 0 {
+				return errors.New("tensor input should not be ragged array")
+			}
+			shape = append(shape, int64(len(arr)))
+		} else if shape[depth] != int64(len(arr)) {
+			return errors.New("tensor input should not be ragged array")
+		}
+		for _, val := range arr {
+			if err := walk(val, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := walk(v, 0)
+	return data, shape, err
+}
+
+// helper function to build OIP tensor of ML model input from JSON value,
+// its shape is checked against static dimensions of input tensor
+func oipTensor(spec TensorSpec, v any) (map[string]any, error) {
+	data, shape, err := tensorData(v)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Shape != nil {
+		mismatch := len(shape) != len(spec.Shape)
+		for i := 0; !mismatch && i < len(shape); i++ {
+			mismatch = spec.Shape[i] >= 0 && spec.Shape[i] != shape[i]
+		}
+		if mismatch {
+			msg := fmt.Sprintf("input %s has shape %v while ONNX model expects %v", spec.Name, shape, spec.Shape)
+			return nil, errors.New(msg)
+		}
+	}
+	tensor := map[string]any{
+		"name":     spec.Name,
+		"shape":    shape,
+		"datatype": spec.Datatype,
+		"data":     data,
+	}
+	return tensor, nil
+}
+
+// oipRequest builds OIP inference request from prediction input of ML
+// record. Input is either OIP inference request with inputs, JSON object
+// with values of ML model inputs keyed by their names, or value of single
+// ML model input
+func oipRequest(rec Record) (map[string]any, error) {
+	if obj, ok := rec.Input.(map[string]any); ok {
+		if _, ok := obj["inputs"]; ok {
+			return obj, nil
+		}
+	}
+	var specs []TensorSpec
+	if rec.Signature != nil {
+		var err error
+		if specs, err = tensorSpecs(rec.Signature.Inputs); err != nil {
+			return nil, fmt.Errorf("[MLHub.main.oipRequest] tensorSpecs error: %w", err)
+		}
+	}
+	if len(specs) == 0 {
+		return nil, errors.New("inputs of ONNX model are unknown, please provide OIP inference request")
+	}
+	var inputs []map[string]any
+	if obj, ok := rec.Input.(map[string]any); ok {
+		for _, spec := range specs {
+			val, ok := obj[spec.Name]
+			if !ok {
+				msg := fmt.Sprintf("prediction input does not provide %s input of ONNX model", spec.Name)
+				return nil, errors.New(msg)
+			}
+			tensor, err := oipTensor(spec, val)
+			if err != nil {
+				return nil, err
+			}
+			inputs = append(inputs, tensor)
+		}
+	} else {
+		if len(specs) != 1 {
+			msg := fmt.Sprintf("ONNX model has %d inputs, please provide them as JSON object keyed by input names", len(specs))
+			return nil, errors.New(msg)
+		}
+		tensor, err := oipTensor(specs[0], rec.Input)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, tensor)
+	}
+	return map[string]any{"inputs": inputs}, nil
+}
+
+// PredictOIP fetches prediction of ONNX model from OIP ML backend replica
+// with given URI, prediction output is OIP inference response
+func PredictOIP(uri string, rec Record, r *http.Request) ([]byte, string, error) {
+	mtype := ""
+	if r.Header.Get("Accept") != "application/json" {
+		return []byte{}, mtype, errors.New("ONNX ML models support only JSON prediction input")
+	}
+	input, err := oipRequest(rec)
+	if err != nil {
+		return []byte{}, mtype, fmt.Errorf("[MLHub.main.PredictOIP] oipRequest error: %w", err)
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return []byte{}, mtype, fmt.Errorf("[MLHub.main.PredictOIP] json.Marshal error: %w", err)
+	}
+	uri = fmt.Sprintf("%s/v2/models/%s/infer", uri, url.PathEscape(oipModelName(rec)))
+	if Verbose > 0 {
+		log.Printf("POST request to %s with body\n%v", uri, string(data))
+	}
+	req, err := http.NewRequestWithContext(r.Context(), "POST", uri, bytes.NewReader(data))
+	if err != nil {
+		return []byte{}, mtype, fmt.Errorf("[MLHub.main.PredictOIP] http.NewRequest error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	// predictions have no side effects and therefore can be retried
+	rsp, err := httpClient(rec.Backend).Do(req, true)
+	if err != nil {
+		return []byte{}, mtype, fmt.Errorf("[MLHub.main.PredictOIP] client.Do error: %w", err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		err := oipError(rsp)
+		log.Printf("request to %s failed, error %v", rec.Backend, err)
+		return []byte{}, mtype, err
+	}
+	mtype = rsp.Header.Get("Content-type")
+	data, err = io.ReadAll(rsp.Body)
+	if err != nil {
+		return data, mtype, fmt.Errorf("[MLHub.main.PredictOIP] io.ReadAll error: %w", err)
+	}
+	return data, mtype, nil
+}
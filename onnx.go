@@ -0,0 +1,509 @@
+package main
+
+// onnx module provides validation of ONNX ML models, it parses protocol
+// buffers graph of ONNX model to extract its inputs, outputs and opset, and
+// links ONNX exports to their source ML models
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// max length of ONNX graph names we read, e.g. names of graph inputs
+const onnxMaxName = 1 << 16
+
+// protocol buffers wire types used by ONNX model
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// ONNX tensor element types and their Open Inference Protocol data types
+var onnxDatatypes = map[uint64]string{
+	1:  "FP32",
+	2:  "UINT8",
+	3:  "INT8",
+	4:  "UINT16",
+	5:  "INT16",
+	6:  "INT32",
+	7:  "INT64",
+	8:  "BYTES",
+	9:  "BOOL",
+	10: "FP16",
+	11: "FP64",
+	12: "UINT32",
+	13: "UINT64",
+	16: "BF16",
+}
+
+// onnxModel represents parts of ONNX model we keep in ML record
+type onnxModel struct {
+	IRVersion       uint64       // ONNX IR version
+	Producer        string       // name of tool which produced ONNX model, e.g. pytorch
+	ProducerVersion string       // version of tool which produced ONNX model
+	Opset           uint64       // version of default ONNX operator set
+	Nodes           int          // number of graph nodes
+	Inputs          []TensorSpec // graph inputs which are not initializers
+	Outputs         []TensorSpec // graph outputs
+}
+
+// onnxReader reads protocol buffers message of ONNX model from stream, large
+// fields like tensor data of initializers are skipped without loading them
+type onnxReader struct {
+	r *bufio.Reader
+}
+
+// helper function to read tag of next field of message, io.EOF is returned
+// at the end of message
+func (p onnxReader) field() (int, int, error) {
+	tag, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(tag >> 3), int(tag & 7), nil
+}
+
+// helper function to read varint field
+func (p onnxReader) varint() (uint64, error) {
+	val, err := binary.ReadUvarint(p.r)
+	return val, unexpectedEOF(err)
+}
+
+// helper function to read length of length-delimited field
+func (p onnxReader) length() (int64, error) {
+	size, err := p.varint()
+	if err != nil {
+		return 0, err
+	}
+	if size > 1<<62 {
+		return 0, errors.New("invalid length of ONNX model field")
+	}
+	return int64(size), nil
+}
+
+// helper function to read string field
+func (p onnxReader) str() (string, error) {
+	size, err := p.length()
+	if err != nil {
+		return "", err
+	}
+	if size > onnxMaxName {
+		msg := fmt.Sprintf("ONNX model name is longer than %d bytes", onnxMaxName)
+		return "", errors.New(msg)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(p.r, buf); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	return string(buf), nil
+}
+
+// helper function to read embedded message field, read function is called
+// with reader of embedded message and the rest of message is skipped
+func (p onnxReader) message(read func(onnxReader) error) error {
+	size, err := p.length()
+	if err != nil {
+		return err
+	}
+	lr := &io.LimitedReader{R: p.r, N: size}
+	if err := read(onnxReader{r: bufio.NewReader(lr)}); err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, lr); err != nil {
+		return err
+	}
+	if lr.N > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// helper function to read all fields of message, read function is called
+// for every field and it should consume the field value
+func (p onnxReader) fields(read func(num, typ int) error) error {
+	for {
+		num, typ, err := p.field()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return unexpectedEOF(err)
+		}
+		if err := read(num, typ); err != nil {
+			return err
+		}
+	}
+}
+
+// helper function to skip field value of given wire type
+func (p onnxReader) skip(typ int) error {
+	var size int64
+	var err error
+	switch typ {
+	case wireVarint:
+		_, err = p.varint()
+		return err
+	case wireFixed64:
+		size = 8
+	case wireFixed32:
+		size = 4
+	case wireBytes:
+		if size, err = p.length(); err != nil {
+			return err
+		}
+	default:
+		msg := fmt.Sprintf("unsupported wire type %d of ONNX model field", typ)
+		return errors.New(msg)
+	}
+	_, err = io.CopyN(io.Discard, p.r, size)
+	return unexpectedEOF(err)
+}
+
+// helper function to report end of stream in the middle of message as
+// unexpected EOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readONNXModel parses ONNX ModelProto from given reader and validates its
+// graph, see https://github.com/onnx/onnx/blob/main/onnx/onnx.proto
+func readONNXModel(r io.Reader) (onnxModel, error) {
+	var model onnxModel
+	p := onnxReader{r: bufio.NewReader(r)}
+	graph := false
+	err := p.fields(func(num, typ int) error {
+		var err error
+		switch {
+		case num == 1 && typ == wireVarint:
+			model.IRVersion, err = p.varint()
+		case num == 2 && typ == wireBytes:
+			model.Producer, err = p.str()
+		case num == 3 && typ == wireBytes:
+			model.ProducerVersion, err = p.str()
+		case num == 7 && typ == wireBytes:
+			graph = true
+			err = p.message(func(g onnxReader) error {
+				return readONNXGraph(g, &model)
+			})
+		case num == 8 && typ == wireBytes:
+			err = p.message(func(o onnxReader) error {
+				return readONNXOpset(o, &model)
+			})
+		default:
+			err = p.skip(typ)
+		}
+		return err
+	})
+	if err != nil {
+		return model, fmt.Errorf("[MLHub.main.readONNXModel] invalid ONNX model: %w", err)
+	}
+	if model.IRVersion == 0 {
+		return model, errors.New("invalid ONNX model, it does not provide IR version")
+	}
+	if !graph || model.Nodes == 0 {
+		return model, errors.New("invalid ONNX model, it does not contain graph nodes")
+	}
+	if len(model.Outputs) == 0 {
+		return model, errors.New("invalid ONNX model, its graph does not have outputs")
+	}
+	for _, t := range append(model.Inputs, model.Outputs...) {
+		if t.Name == "" {
+			return model, errors.New("invalid ONNX model, its graph has tensor without name")
+		}
+	}
+	return model, nil
+}
+
+// helper function to read operator set of ONNX model, only version of
+// default ai.onnx domain is kept
+func readONNXOpset(p onnxReader, model *onnxModel) error {
+	var domain string
+	var version uint64
+	err := p.fields(func(num, typ int) error {
+		var err error
+		switch {
+		case num == 1 && typ == wireBytes:
+			domain, err = p.str()
+		case num == 2 && typ == wireVarint:
+			version, err = p.varint()
+		default:
+			err = p.skip(typ)
+		}
+		return err
+	})
+	if err == nil && (domain == "" || domain == "ai.onnx") {
+		model.Opset = version
+	}
+	return err
+}
+
+// helper function to read GraphProto of ONNX model, graph inputs which are
+// initializers, i.e. ML model weights in older IR versions, are not inputs
+// of ML model
+func readONNXGraph(p onnxReader, model *onnxModel) error {
+	var inputs []TensorSpec
+	initializers := make(map[string]bool)
+	err := p.fields(func(num, typ int) error {
+		switch {
+		case num == 1 && typ == wireBytes:
+			model.Nodes++
+			return p.skip(typ)
+		case num == 5 && typ == wireBytes:
+			return p.message(func(t onnxReader) error {
+				return t.fields(func(num, typ int) error {
+					if num == 8 && typ == wireBytes {
+						name, err := t.str()
+						initializers[name] = true
+						return err
+					}
+					return t.skip(typ)
+				})
+			})
+		case (num == 11 || num == 12) && typ == wireBytes:
+			var spec TensorSpec
+			err := p.message(func(v onnxReader) error {
+				return readONNXValue(v, &spec)
+			})
+			if num == 11 {
+				inputs = append(inputs, spec)
+			} else {
+				model.Outputs = append(model.Outputs, spec)
+			}
+			return err
+		}
+		return p.skip(typ)
+	})
+	for _, spec := range inputs {
+		if !initializers[spec.Name] {
+			model.Inputs = append(model.Inputs, spec)
+		}
+	}
+	return err
+}
+
+// helper function to read ValueInfoProto of graph input or output, tensor
+// shape is nil when its rank is unknown
+func readONNXValue(p onnxReader, spec *TensorSpec) error {
+	return p.fields(func(num, typ int) error {
+		var err error
+		switch {
+		case num == 1 && typ == wireBytes:
+			spec.Name, err = p.str()
+		case num == 2 && typ == wireBytes:
+			// TypeProto, only tensor types are supported by ML backends
+			err = p.message(func(t onnxReader) error {
+				return t.fields(func(num, typ int) error {
+					if num == 1 && typ == wireBytes {
+						return t.message(func(tt onnxReader) error {
+							return readONNXTensorType(tt, spec)
+						})
+					}
+					return t.skip(typ)
+				})
+			})
+		default:
+			err = p.skip(typ)
+		}
+		return err
+	})
+}
+
+// helper function to read TypeProto.Tensor, i.e. element type and shape of tensor
+func readONNXTensorType(p onnxReader, spec *TensorSpec) error {
+	return p.fields(func(num, typ int) error {
+		switch {
+		case num == 1 && typ == wireVarint:
+			elem, err := p.varint()
+			spec.Datatype = onnxDatatypes[elem]
+			return err
+		case num == 2 && typ == wireBytes:
+			spec.Shape = []int64{}
+			return p.message(func(s onnxReader) error {
+				return s.fields(func(num, typ int) error {
+					if num != 1 || typ != wireBytes {
+						return s.skip(typ)
+					}
+					// dimension is either dim_value or symbolic dim_param
+					dim := int64(-1)
+					err := s.message(func(d onnxReader) error {
+						return d.fields(func(num, typ int) error {
+							if num == 1 && typ == wireVarint {
+								val, err := d.varint()
+								dim = int64(val)
+								return err
+							}
+							return d.skip(typ)
+						})
+					})
+					spec.Shape = append(spec.Shape, dim)
+					return err
+				})
+			})
+		}
+		return p.skip(typ)
+	})
+}
+
+// helper function to check if file is ONNX model
+func isONNX(fname string) bool {
+	return strings.HasSuffix(strings.ToLower(fname), ".onnx")
+}
+
+// readONNX reads ONNX model of ML bundle, ML bundle is either ONNX model
+// file or archive which contains it, e.g. MLflow model with onnx flavor
+func readONNX(bf BundleFile) (onnxModel, error) {
+	var model onnxModel
+	if isONNX(bf.Name) {
+		file, err := bf.Open()
+		if err != nil {
+			return model, fmt.Errorf("[MLHub.main.readONNX] bf.Open error: %w", err)
+		}
+		defer file.Close()
+		return readONNXModel(file)
+	}
+	found := false
+	err := readArchive(bf, func(name string, size int64, r io.Reader) (bool, error) {
+		if !isONNX(name) {
+			return true, nil
+		}
+		found = true
+		var err error
+		model, err = readONNXModel(r)
+		return false, err
+	})
+	if err == nil && !found {
+		msg := fmt.Sprintf("ML bundle %s does not contain ONNX model", bf.Name)
+		err = errors.New(msg)
+	}
+	return model, err
+}
+
+// onnxFile provides ONNX model file of ML bundle, ONNX model is extracted
+// from archive into temporary file which is removed by returned function
+func onnxFile(bf BundleFile) (BundleFile, func(), error) {
+	if isONNX(bf.Name) {
+		return bf, func() {}, nil
+	}
+	var fname string
+	err := readArchive(bf, func(name string, size int64, r io.Reader) (bool, error) {
+		if !isONNX(name) {
+			return true, nil
+		}
+		file, err := os.CreateTemp("", "mlhub-*.onnx")
+		if err != nil {
+			return false, err
+		}
+		fname = file.Name()
+		_, err = io.Copy(file, r)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		return false, err
+	})
+	cleanup := func() {
+		if fname != "" {
+			os.Remove(fname)
+		}
+	}
+	if err != nil {
+		cleanup()
+		return bf, func() {}, fmt.Errorf("[MLHub.main.onnxFile] readArchive error: %w", err)
+	}
+	if fname == "" {
+		msg := fmt.Sprintf("ML bundle %s does not contain ONNX model", bf.Name)
+		return bf, func() {}, errors.New(msg)
+	}
+	return FileBundle(fname), cleanup, nil
+}
+
+// onnxRecord validates ONNX model of given ML bundle and fills ML record
+// with its inputs and outputs, opset and ML backend unless it is provided.
+// ML bundle is ONNX model if ML model type is ONNX or if it is ONNX model
+// file. It returns false if ML bundle is not ONNX model
+func onnxRecord(rec Record, bf BundleFile) (Record, bool, error) {
+	if rec.Type != "ONNX" && (rec.Type != "" || !isONNX(bf.Name)) {
+		return rec, false, nil
+	}
+	rec.Type = "ONNX"
+	model, err := readONNX(bf)
+	if err != nil {
+		return rec, true, err
+	}
+	if rec.Backend == "" {
+		if rec.Backend, err = defaultBackend(rec.Type); err != nil {
+			return rec, true, err
+		}
+	}
+	rec.Framework = fmt.Sprintf("onnx ir %d opset %d", model.IRVersion, model.Opset)
+	rec.Signature = &Signature{Inputs: model.Inputs, Outputs: model.Outputs}
+	if rec.Conversion != nil && rec.Conversion.Converter == "" && model.Producer != "" {
+		rec.Conversion.Converter = strings.TrimSpace(model.Producer + " " + model.ProducerVersion)
+	}
+	return rec, true, nil
+}
+
+// helper function to parse conversion meta-data from HTTP form, it returns
+// nil if ML model is not converted from another ML model
+func parseConversion(r *http.Request) *Conversion {
+	if r.FormValue("sourcemodel") == "" {
+		return nil
+	}
+	return &Conversion{
+		SourceModel:   r.FormValue("sourcemodel"),
+		SourceType:    r.FormValue("sourcetype"),
+		SourceVersion: r.FormValue("sourceversion"),
+		Converter:     r.FormValue("converter"),
+	}
+}
+
+// helper function to validate conversion meta-data of ML record, source ML
+// model should exist and its type is filled unless it is provided
+func validateConversion(rec Record) (*Conversion, error) {
+	conv := rec.Conversion
+	if conv == nil {
+		return nil, nil
+	}
+	if rec.Type != "ONNX" {
+		msg := fmt.Sprintf("conversion meta-data is supported for ONNX ML models, got %s", rec.Type)
+		return conv, errors.New(msg)
+	}
+	if conv.SourceModel == "" || conv.SourceVersion == "" {
+		return conv, errors.New("conversion meta-data should provide source ML model and its version")
+	}
+	if conv.SourceType == "ONNX" {
+		return conv, errors.New("source ML model of ONNX export should not be ONNX model")
+	}
+	records, err := metaRecords(conv.SourceModel, conv.SourceType, conv.SourceVersion)
+	if err != nil {
+		return conv, fmt.Errorf("[MLHub.main.validateConversion] metaRecords error: %w", err)
+	}
+	var sources []Record
+	for _, r := range records {
+		if r.Type != "ONNX" {
+			sources = append(sources, r)
+		}
+	}
+	if len(sources) != 1 {
+		msg := fmt.Sprintf("source model=%s type=%s version=%s does not exist", conv.SourceModel, conv.SourceType, conv.SourceVersion)
+		if len(sources) > 1 {
+			msg = fmt.Sprintf("source model=%s version=%s is ambiguous, please provide its type", conv.SourceModel, conv.SourceVersion)
+		}
+		return conv, errors.New(msg)
+	}
+	source := *conv
+	source.SourceType = sources[0].Type
+	return &source, nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// helper function to encode protocol buffers message field
+func protoField(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// helper function to encode protocol buffers varint field
+func protoVarint(b []byte, num protowire.Number, val uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, val)
+}
+
+// helper function to encode ValueInfoProto of float tensor, negative
+// dimensions are encoded as symbolic dimensions
+func onnxValue(name string, dims ...int64) []byte {
+	var shape []byte
+	for _, d := range dims {
+		var dim []byte
+		if d < 0 {
+			dim = protoField(dim, 2, []byte("batch"))
+		} else {
+			dim = protoVarint(dim, 1, uint64(d))
+		}
+		shape = protoField(shape, 1, dim)
+	}
+	tensor := protoVarint(nil, 1, 1)
+	tensor = protoField(tensor, 2, shape)
+	value := protoField(nil, 1, []byte(name))
+	return protoField(value, 2, protoField(nil, 1, tensor))
+}
+
+// helper function to build ONNX model y = MatMul(x, w) with initializer w
+// which is also listed as graph input
+func testONNXModel() []byte {
+	node := protoField(nil, 1, []byte("x"))
+	node = protoField(node, 1, []byte("w"))
+	node = protoField(node, 2, []byte("y"))
+	node = protoField(node, 4, []byte("MatMul"))
+	weights := protoVarint(nil, 1, 3)
+	weights = protoVarint(weights, 2, 1)
+	weights = protoField(weights, 8, []byte("w"))
+	weights = protoField(weights, 9, make([]byte, 12))
+	graph := protoField(nil, 1, node)
+	graph = protoField(graph, 2, []byte("test"))
+	graph = protoField(graph, 5, weights)
+	graph = protoField(graph, 11, onnxValue("x", -1, 3))
+	graph = protoField(graph, 11, onnxValue("w", 3))
+	graph = protoField(graph, 12, onnxValue("y", -1))
+	opset := protoField(nil, 1, []byte(""))
+	opset = protoVarint(opset, 2, 17)
+	model := protoVarint(nil, 1, 8)
+	model = protoField(model, 2, []byte("pytorch"))
+	model = protoField(model, 3, []byte("2.1.0"))
+	model = protoField(model, 7, graph)
+	return protoField(model, 8, opset)
+}
+
+// TestReadONNXModel tests parsing and validation of ONNX model graph
+func TestReadONNXModel(t *testing.T) {
+	data := testONNXModel()
+	model, err := readONNXModel(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.IRVersion != 8 || model.Opset != 17 || model.Nodes != 1 {
+		t.Errorf("wrong ONNX model %+v", model)
+	}
+	if model.Producer != "pytorch" || model.ProducerVersion != "2.1.0" {
+		t.Errorf("wrong producer of ONNX model %+v", model)
+	}
+	inputs := []TensorSpec{{Name: "x", Datatype: "FP32", Shape: []int64{-1, 3}}}
+	if !reflect.DeepEqual(model.Inputs, inputs) {
+		t.Errorf("wrong inputs of ONNX model %+v", model.Inputs)
+	}
+	outputs := []TensorSpec{{Name: "y", Datatype: "FP32", Shape: []int64{-1}}}
+	if !reflect.DeepEqual(model.Outputs, outputs) {
+		t.Errorf("wrong outputs of ONNX model %+v", model.Outputs)
+	}
+	for _, invalid := range [][]byte{data[:len(data)-5], []byte("not an ONNX model"), {}} {
+		if _, err := readONNXModel(bytes.NewReader(invalid)); err == nil {
+			t.Errorf("invalid ONNX model %q is accepted", invalid)
+		}
+	}
+}
+
+// TestTensorData tests conversion of JSON arrays to tensor data and shape
+func TestTensorData(t *testing.T) {
+	var input any
+	json.Unmarshal([]byte(`[[1, 2, 3], [4, 5, 6]]`), &input)
+	data, shape, err := tensorData(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(shape, []int64{2, 3}) || len(data) != 6 {
+		t.Errorf("wrong tensor data %v shape %v", data, shape)
+	}
+	for _, ragged := range []string{`[[1, 2], [3]]`, `[1, [2]]`, `[[1], 2]`} {
+		json.Unmarshal([]byte(ragged), &input)
+		if _, _, err := tensorData(input); err == nil {
+			t.Errorf("ragged input %s is accepted", ragged)
+		}
+	}
+}
+
+// TestOIPModelName tests that distinct ML model versions get distinct names
+// on OIP ML backend
+func TestOIPModelName(t *testing.T) {
+	pairs := [][2]Record{
+		{{Model: "a/b", Version: "v1"}, {Model: "a_b", Version: "v1"}},
+		{{Model: "a-b", Version: "c"}, {Model: "a", Version: "b-c"}},
+	}
+	for _, p := range pairs {
+		if oipModelName(p[0]) == oipModelName(p[1]) {
+			t.Errorf("%+v and %+v have the same name %s", p[0], p[1], oipModelName(p[0]))
+		}
+	}
+	if name := oipModelName(Record{Model: "mnist", Version: "v1"}); !strings.HasPrefix(name, "mnist-v1-") {
+		t.Errorf("wrong name %s of mnist v1", name)
+	}
+}
+
+// fakeOIP implements model repository and inference APIs of Open Inference
+// Protocol ML backend, inference responds with provided inputs as outputs
+type fakeOIP struct {
+	sync.Mutex
+	models map[string][]byte
+}
+
+func (f *fakeOIP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/v2/repository/index":
+		var index []map[string]string
+		for name := range f.models {
+			index = append(index, map[string]string{"name": name, "state": "READY"})
+		}
+		json.NewEncoder(w).Encode(index)
+	case len(path) == 5 && path[4] == "load":
+		var req struct {
+			Parameters map[string]string `json:"parameters"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, err := base64.StdEncoding.DecodeString(req.Parameters["file:1/"+OIPModelFile])
+		if err != nil || req.Parameters["config"] == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.models[path[3]] = data
+	case len(path) == 5 && path[4] == "unload":
+		delete(f.models, path[3])
+	case len(path) == 4 && path[3] == "infer":
+		if _, ok := f.models[path[2]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "model is not found"})
+			return
+		}
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"model_name": path[2], "outputs": req["inputs"]})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// TestOIPBackend tests deployment, prediction and undeployment of ONNX
+// model on fake OIP ML backend
+func TestOIPBackend(t *testing.T) {
+	srvConfig.Config = &srvConfig.SrvConfig{}
+	HubConfig.defaults()
+	fake := &fakeOIP{models: make(map[string][]byte)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	replica := srvConfig.MLBackend{Name: "Triton", Type: "ONNX", URI: srv.URL}
+
+	data := testONNXModel()
+	bf := BundleFile{Name: "mnist.onnx"}
+	rec := Record{Model: "mnist", Type: "ONNX", Backend: "Triton", Version: "v1", Bundle: bf.Name}
+	bf.Open = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	rec, ok, err := onnxRecord(rec, bf)
+	if err != nil || !ok {
+		t.Fatalf("unable to read ONNX model, ok %v error %v", ok, err)
+	}
+	if err := uploadReplicaOIP(context.Background(), replica, rec, bf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fake.models[oipModelName(rec)], data) {
+		t.Fatal("ONNX model is not loaded by OIP ML backend")
+	}
+	models, err := replicaModelsOIP(context.Background(), replica)
+	if err != nil || !models[deployedName(rec)] {
+		t.Fatalf("ONNX model is not listed by OIP ML backend, models %v error %v", models, err)
+	}
+
+	// ML record from MetaData database keeps signature as generic JSON
+	sig, _ := json.Marshal(rec.Signature)
+	rec.Signature = nil
+	json.Unmarshal(sig, &rec.Signature)
+	json.Unmarshal([]byte(`[[1, 2, 3]]`), &rec.Input)
+	r := httptest.NewRequest("POST", "/predict", nil)
+	r.Header.Set("Accept", "application/json")
+	output, _, err := PredictOIP(srv.URL, rec, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rsp struct {
+		Outputs []struct {
+			Name     string    `json:"name"`
+			Datatype string    `json:"datatype"`
+			Shape    []int64   `json:"shape"`
+			Data     []float64 `json:"data"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal(output, &rsp); err != nil {
+		t.Fatal(err)
+	}
+	if len(rsp.Outputs) != 1 || rsp.Outputs[0].Name != "x" || rsp.Outputs[0].Datatype != "FP32" ||
+		!reflect.DeepEqual(rsp.Outputs[0].Shape, []int64{1, 3}) || len(rsp.Outputs[0].Data) != 3 {
+		t.Errorf("wrong OIP inference request %s", output)
+	}
+	json.Unmarshal([]byte(`[[1, 2]]`), &rec.Input)
+	if _, _, err := PredictOIP(srv.URL, rec, r); err == nil {
+		t.Error("input with wrong shape is accepted")
+	}
+
+	if err := undeployReplicaOIP(context.Background(), replica, rec); err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal([]byte(`[[1, 2, 3]]`), &rec.Input)
+	if _, _, err := PredictOIP(srv.URL, rec, r); err == nil {
+		t.Error("prediction of unloaded ONNX model succeeded")
+	}
+}
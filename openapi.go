@@ -0,0 +1,703 @@
+package main
+
+// openapi module provides OpenAPI specification of MLHub APIs
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	server "github.com/CHESSComputing/golib/server"
+	services "github.com/CHESSComputing/golib/services"
+	"github.com/gin-gonic/gin"
+)
+
+// apiParam defines parameter of MLHub API
+type apiParam struct {
+	Name        string // parameter name
+	In          string // parameter location: path or query
+	Description string // parameter description
+	Required    bool   // parameter is required
+}
+
+// apiOperation defines OpenAPI operation of MLHub API. Request and Response
+// map content type to schema, where schema is either name of MLHub data type,
+// e.g. Record, array of data type, e.g. []Record, form fields, e.g.
+// form:model,type, or empty string for arbitrary content
+type apiOperation struct {
+	Summary     string            // operation summary
+	Description string            // operation description
+	Tags        []string          // operation tags
+	Params      []apiParam        // operation parameters
+	Request     map[string]string // request body content types and schemas
+	Status      int               // status code of successful response, default 200
+	Response    map[string]string // response content types and schemas
+	Errors      map[int]string    // additional error responses and their descriptions
+}
+
+// helper function to define path parameter
+func pathParam(name, desc string) apiParam {
+	return apiParam{Name: name, In: "path", Description: desc, Required: true}
+}
+
+// helper function to define query parameter
+func queryParam(name, desc string) apiParam {
+	return apiParam{Name: name, In: "query", Description: desc}
+}
+
+// common parameters of ML model APIs
+var modelParams = []apiParam{
+	pathParam("name", "ML model name"),
+	queryParam("type", "ML model type, e.g. TensorFlow"),
+	queryParam("version", "ML model version or its alias, e.g. production"),
+}
+
+// query parameters of audit log APIs
+var auditParams = []apiParam{
+	queryParam("event", "event name: upload, update, promote, download, delete, restore or purge"),
+	queryParam("user", "user who performed the action"),
+	queryParam("model", "ML model name"),
+	queryParam("type", "ML model type, e.g. TensorFlow"),
+	queryParam("version", "ML model version"),
+	queryParam("outcome", "outcome of the action: success or failure"),
+	queryParam("since", "unix timestamp of the earliest event"),
+	queryParam("until", "unix timestamp of the latest event"),
+	queryParam("idx", "index of the first event"),
+	queryParam("limit", "number of events"),
+}
+
+// common responses of MLHub APIs
+var (
+	jsonResponse = map[string]string{"application/json": "ServiceResponse"}
+	htmlResponse = map[string]string{"text/html": ""}
+)
+
+// apiSpecs defines OpenAPI operations of MLHub routes, every route registered
+// in setupRouter should have its entry here
+var apiSpecs = map[string]apiOperation{
+	"GET /docs/:name": {
+		Summary:  "MLHub documentation page",
+		Tags:     []string{"docs"},
+		Params:   []apiParam{pathParam("name", "documentation name, e.g. apis")},
+		Response: htmlResponse,
+	},
+	"GET /openapi.json": {
+		Summary:  "OpenAPI specification of MLHub APIs",
+		Tags:     []string{"docs"},
+		Response: map[string]string{"application/json": ""},
+	},
+	"GET /apidocs": {
+		Summary:  "interactive documentation of MLHub APIs",
+		Tags:     []string{"docs"},
+		Response: htmlResponse,
+	},
+	"GET /upload": {
+		Summary:  "upload page of web UI",
+		Tags:     []string{"ui"},
+		Response: htmlResponse,
+	},
+	"GET /download": {
+		Summary:  "download page of web UI",
+		Tags:     []string{"ui"},
+		Response: htmlResponse,
+	},
+	"GET /inference": {
+		Summary:  "inference page of web UI",
+		Tags:     []string{"ui"},
+		Response: htmlResponse,
+	},
+	"GET /models": {
+		Summary:     "list ML models",
+		Description: "provides ML models catalog page for web browsers (Accept: text/html)",
+		Tags:        []string{"models"},
+		Params: []apiParam{
+			queryParam("q", "search in ML model name and description"),
+			queryParam("type", "ML model type"),
+			queryParam("backend", "ML backend name"),
+			queryParam("discipline", "ML model discipline"),
+			queryParam("user", "ML model author"),
+			queryParam("origin", "URL of remote MLHub of mirrored ML models"),
+			queryParam("source", "source ML model of converted ML models, e.g. ONNX exports"),
+			queryParam("since", "ML models uploaded since given unix timestamp"),
+			queryParam("idx", "index of first record"),
+			queryParam("limit", "number of records"),
+		},
+		Response: map[string]string{"application/json": "[]Record", "text/html": ""},
+	},
+	"GET /models/:name": {
+		Summary:     "download ML model bundle",
+		Description: "redirects to ML bundle file which supports HTTP range requests",
+		Tags:        []string{"models"},
+		Params:      modelParams,
+		Status:      http.StatusSeeOther,
+	},
+	"GET /model/:name": {
+		Summary:     "ML model meta-data",
+		Description: "provides ML model page with embedded schema.org JSON-LD for web browsers (Accept: text/html)",
+		Tags:        []string{"models"},
+		Params:      modelParams,
+		Response:    map[string]string{"application/json": "Record", "text/html": ""},
+	},
+	"GET /model/:name/jsonld": {
+		Summary:  "schema.org JSON-LD of ML model",
+		Tags:     []string{"fair"},
+		Params:   modelParams,
+		Response: map[string]string{"application/ld+json": ""},
+	},
+	"GET /model/:name/rocrate": {
+		Summary:  "RO-Crate zip archive of ML model",
+		Tags:     []string{"fair"},
+		Params:   modelParams,
+		Response: map[string]string{"application/zip": "binary"},
+	},
+	"GET /lineage/dataset": {
+		Summary:  "ML models trained or evaluated on FOXDEN dataset",
+		Tags:     []string{"lineage"},
+		Params:   []apiParam{{Name: "did", In: "query", Description: "FOXDEN dataset identifier", Required: true}},
+		Response: map[string]string{"application/json": "[]Record"},
+	},
+	"GET /lineage/model/:name": {
+		Summary:  "lineage of ML model",
+		Tags:     []string{"lineage"},
+		Params:   modelParams,
+		Response: map[string]string{"application/json": "Lineage"},
+	},
+	"GET /provenance/:name": {
+		Summary:  "provenance record of ML prediction",
+		Tags:     []string{"predictions"},
+		Params:   []apiParam{pathParam("name", "provenance ID returned in X-MLHub-Provenance header")},
+		Response: map[string]string{"application/json": "PredictionRecord"},
+	},
+	"POST /predict": {
+		Summary:     "ML prediction",
+		Description: "JSON request provides input in input field, form request provides input file, e.g. image. ONNX models accept JSON input only, i.e. value of single input, object with values keyed by input names or Open Inference Protocol request, and respond with Open Inference Protocol response. Use X-MLHub-Provenance: true header to record provenance of prediction. Requests without version are routed by routing rule of ML model (if any), X-MLHub-Routing-Key header provides routing key of sticky rules, and X-MLHub-Version response header reports version which served prediction",
+		Tags:        []string{"predictions"},
+		Request: map[string]string{
+			"application/json":    "Record",
+			"multipart/form-data": "form:model,type,backend,version,image",
+		},
+		Response: map[string]string{"application/json": "", "application/octet-stream": "binary"},
+		Errors:   map[int]string{http.StatusServiceUnavailable: "ML backend is unavailable, see /backends"},
+	},
+	"POST /upload": {
+		Summary:     "upload ML model",
+		Description: "ML bundle is provided either as file form field or as upload ID of resumable upload. For MLflow models, i.e. tar, tar.gz or zip archives with MLmodel file, ML model type, framework version, signature and input example are taken from MLmodel file and ML backend is chosen by ML model type unless they are provided. ONNX models, i.e. .onnx files or archives with ONNX model, are validated and their graph inputs and outputs are stored as signature, sourcemodel, sourcetype, sourceversion and converter fields link ONNX export to its source ML model",
+		Tags:        []string{"models"},
+		Request: map[string]string{
+			"multipart/form-data": "form:model,type,backend,version,description,reference,discipline,file,upload,datasets,evaluation,commit,parentmodel,parentversion,parameters,sourcemodel,sourcetype,sourceversion,converter",
+		},
+		Response: jsonResponse,
+	},
+	"GET /domains": {
+		Summary:     "scientific domains of ML models",
+		Description: "provides domains page for web browsers (Accept: text/html)",
+		Tags:        []string{"domains"},
+		Response:    map[string]string{"application/json": "[]DomainNode", "text/html": ""},
+	},
+	"GET /backends": {
+		Summary:  "health status of ML backends",
+		Tags:     []string{"backends"},
+		Response: map[string]string{"application/json": "[]BackendStatus"},
+	},
+	"GET /metrics": {
+		Summary:     "MLHub metrics in Prometheus format",
+		Description: "metrics of ML models are labeled by model, type, version and backend fields of ML record",
+		Tags:        []string{"backends"},
+		Response:    map[string]string{"text/plain": ""},
+	},
+	"POST /domains": {
+		Summary:  "create or update scientific domain (admin only)",
+		Tags:     []string{"domains"},
+		Request:  map[string]string{"application/json": "Domain"},
+		Response: jsonResponse,
+	},
+	"DELETE /domains/:name": {
+		Summary:  "delete scientific domain (admin only)",
+		Tags:     []string{"domains"},
+		Params:   []apiParam{pathParam("name", "domain name")},
+		Response: jsonResponse,
+	},
+	"POST /promote": {
+		Summary:  "assign alias to ML model version",
+		Tags:     []string{"models"},
+		Request:  map[string]string{"application/json": "Alias"},
+		Response: jsonResponse,
+	},
+	"GET /routing/:name": {
+		Summary:  "canary or A/B routing rule of ML model",
+		Tags:     []string{"routing"},
+		Params:   []apiParam{pathParam("name", "ML model name"), queryParam("type", "ML model type, e.g. TensorFlow")},
+		Response: map[string]string{"application/json": "RoutingRule"},
+	},
+	"GET /routing/:name/report": {
+		Summary:  "evaluation of routing rule arms of ML model",
+		Tags:     []string{"routing"},
+		Params:   []apiParam{pathParam("name", "ML model name"), queryParam("type", "ML model type, e.g. TensorFlow")},
+		Response: map[string]string{"application/json": "[]RoutingReport"},
+	},
+	"POST /routing": {
+		Summary:     "set canary or A/B routing rule of ML model",
+		Description: "weights of arms define split of predictions between versions, sticky caller or key routes the same caller or X-MLHub-Routing-Key to the same version",
+		Tags:        []string{"routing"},
+		Request:     map[string]string{"application/json": "RoutingRule"},
+		Response:    jsonResponse,
+	},
+	"DELETE /routing/:name": {
+		Summary: "delete routing rule of ML model",
+		Tags:    []string{"routing"},
+		Params: []apiParam{
+			pathParam("name", "ML model name"),
+			{Name: "type", In: "query", Description: "ML model type, e.g. TensorFlow", Required: true},
+		},
+		Response: jsonResponse,
+	},
+	"GET /shadow/:name": {
+		Summary:  "shadow deployment of ML model",
+		Tags:     []string{"shadow"},
+		Params:   []apiParam{pathParam("name", "ML model name"), queryParam("type", "ML model type, e.g. TensorFlow")},
+		Response: map[string]string{"application/json": "ShadowRule"},
+	},
+	"GET /shadow/:name/report": {
+		Summary:  "disagreement rate and latency differences of primary and shadow versions of ML model",
+		Tags:     []string{"shadow"},
+		Params:   []apiParam{pathParam("name", "ML model name"), queryParam("type", "ML model type, e.g. TensorFlow")},
+		Response: map[string]string{"application/json": "[]ShadowReport"},
+	},
+	"POST /shadow": {
+		Summary:     "set shadow deployment of ML model",
+		Description: "shadow version asynchronously receives copy of given sample fraction of successful predictions of ML model and its outputs are stored along with outputs of version which served them",
+		Tags:        []string{"shadow"},
+		Request:     map[string]string{"application/json": "ShadowRule"},
+		Response:    jsonResponse,
+	},
+	"DELETE /shadow/:name": {
+		Summary: "delete shadow deployment of ML model",
+		Tags:    []string{"shadow"},
+		Params: []apiParam{
+			pathParam("name", "ML model name"),
+			{Name: "type", In: "query", Description: "ML model type, e.g. TensorFlow", Required: true},
+		},
+		Response: jsonResponse,
+	},
+	"GET /audit": {
+		Summary:     "audit events of ML model lifecycle (admin only)",
+		Description: "audit log records upload, update, promote, download, delete, restore and purge events along with user, client IP and outcome of the action",
+		Tags:        []string{"audit"},
+		Params:      auditParams,
+		Response:    map[string]string{"application/json": "[]AuditEvent"},
+	},
+	"GET /audit/export": {
+		Summary:  "export audit events of ML model lifecycle as JSON Lines (admin only)",
+		Tags:     []string{"audit"},
+		Params:   auditParams,
+		Response: map[string]string{"application/x-ndjson": "AuditEvent"},
+	},
+	"GET /webhooks": {
+		Summary:     "webhooks of the user, all webhooks for MLHub administrators",
+		Description: "secrets of webhooks are not provided",
+		Tags:        []string{"webhooks"},
+		Response:    map[string]string{"application/json": "[]Webhook"},
+	},
+	"GET /webhooks/:name/deliveries": {
+		Summary:  "delivery log of webhook",
+		Tags:     []string{"webhooks"},
+		Params:   []apiParam{pathParam("name", "webhook ID"), queryParam("idx", "index of the first delivery"), queryParam("limit", "number of deliveries")},
+		Response: map[string]string{"application/json": "[]WebhookDelivery"},
+	},
+	"POST /webhooks": {
+		Summary:     "create webhook of ML model events",
+		Description: "events of ML models matching filters of webhook are sent as WebhookEvent JSON payload via POST request with X-MLHub-Event, X-MLHub-Delivery and X-MLHub-Signature (sha256=<HMAC-SHA256 of payload with webhook secret>) headers, failed deliveries are retried with exponential backoff",
+		Tags:        []string{"webhooks"},
+		Request:     map[string]string{"application/json": "Webhook"},
+		Response:    map[string]string{"application/json": "Webhook"},
+	},
+	"DELETE /webhooks/:name": {
+		Summary:  "delete webhook",
+		Tags:     []string{"webhooks"},
+		Params:   []apiParam{pathParam("name", "webhook ID")},
+		Response: jsonResponse,
+	},
+	"GET /uploads/:name": {
+		Summary:  "status of resumable upload",
+		Tags:     []string{"models"},
+		Params:   []apiParam{pathParam("name", "upload ID chosen by client")},
+		Response: map[string]string{"application/json": "UploadStatus"},
+	},
+	"PUT /uploads/:name": {
+		Summary: "append chunk to resumable upload",
+		Tags:    []string{"models"},
+		Params: []apiParam{
+			pathParam("name", "upload ID chosen by client"),
+			{Name: "file", In: "query", Description: "ML bundle file name", Required: true},
+			{Name: "offset", In: "query", Description: "number of already uploaded bytes", Required: true},
+		},
+		Request:  map[string]string{"application/octet-stream": "binary"},
+		Response: map[string]string{"application/json": "UploadStatus"},
+	},
+	"DELETE /delete": {
+		Summary:     "delete ML model version",
+		Description: "ML model version is undeployed from its ML backend and moved to trash, where it is kept for retention period unless it is restored or purged",
+		Tags:        []string{"models"},
+		Params:      []apiParam{queryParam("purge", "delete ML model version permanently if true")},
+		Request:     map[string]string{"application/json": "Record"},
+		Response:    jsonResponse,
+	},
+	"GET /consistency": {
+		Summary:  "the last consistency report of storage, meta-data and ML backends (admin only)",
+		Tags:     []string{"consistency"},
+		Response: map[string]string{"application/json": "ConsistencyReport"},
+	},
+	"POST /consistency": {
+		Summary:     "check consistency of storage, meta-data and ML backends (admin only)",
+		Description: "finds ML bundles without records (orphaned-bundle), records without ML bundles (missing-bundle) and ML models missing on ML backend replicas (missing-deployment). Repair policy removes orphaned bundles, moves records without bundles to trash and redeploys missing ML models",
+		Tags:        []string{"consistency"},
+		Params:      []apiParam{queryParam("policy", "report (default) or repair")},
+		Response:    map[string]string{"application/json": "ConsistencyReport"},
+	},
+	"GET /export": {
+		Summary:     "export ML models and aliases into backup archive (admin only)",
+		Description: "zip archive contains manifest.json (BackupManifest), aliases.json and models/type/model/version directories with meta-data record, model card and ML bundle of every active ML model version",
+		Tags:        []string{"backup"},
+		Response:    map[string]string{"application/zip": "binary"},
+	},
+	"POST /import": {
+		Summary:     "import ML models and aliases from backup archive (admin only)",
+		Description: "digests of ML bundles are verified against the manifest, ML models are uploaded to storage and deployed to configured ML backends. Existing ML model versions and aliases are either skipped or overwritten, overwritten ML models are moved to trash",
+		Tags:        []string{"backup"},
+		Params:      []apiParam{queryParam("conflict", "skip (default) or overwrite")},
+		Request:     map[string]string{"application/zip": "binary"},
+		Response:    map[string]string{"application/json": "ImportReport"},
+	},
+	"POST /import/huggingface": {
+		Summary:     "import ML model from Hugging Face-style repository",
+		Description: "repository files are fetched from Hugging Face Hub compatible endpoint (or taken from local directory of MLHub server, admin only) and packed into tar.gz ML bundle. ML model description is taken from README.md model card or config.json, ML model type is inferred from library_name of model card or from weight files and ML backend from ML model type, then ML model is uploaded to its ML backend",
+		Tags:        []string{"models"},
+		Request:     map[string]string{"application/json": "HFImport"},
+		Response:    map[string]string{"application/json": "Record"},
+	},
+	"GET /federation": {
+		Summary:  "mirroring state of remote MLHub instances (admin only)",
+		Tags:     []string{"federation"},
+		Response: map[string]string{"application/json": "[]Mirror"},
+	},
+	"POST /federation": {
+		Summary:     "pull ML models from remote MLHub instances (admin only)",
+		Description: "ML models changed since the last pull are fetched from public catalog of remote MLHub, their ML bundles are verified against remote digests and they are uploaded with URL of remote MLHub as their origin",
+		Tags:        []string{"federation"},
+		Params:      []apiParam{queryParam("remote", "name of remote MLHub, all remote MLHub instances by default")},
+		Response:    map[string]string{"application/json": "[]MirrorReport"},
+	},
+	"GET /trash": {
+		Summary: "deleted ML models in trash",
+		Tags:    []string{"trash"},
+		Params: []apiParam{
+			queryParam("model", "ML model name"),
+			queryParam("type", "ML model type, e.g. TensorFlow"),
+			queryParam("version", "ML model version"),
+			queryParam("idx", "index of the first record"),
+			queryParam("limit", "number of records"),
+		},
+		Response: map[string]string{"application/json": "[]Record"},
+	},
+	"POST /restore": {
+		Summary:     "restore ML model version from trash",
+		Description: "ML model version is moved back to storage and redeployed to its ML backend",
+		Tags:        []string{"trash"},
+		Request:     map[string]string{"application/json": "Record"},
+		Response:    jsonResponse,
+	},
+	"DELETE /purge": {
+		Summary:  "permanently delete ML model version from trash",
+		Tags:     []string{"trash"},
+		Request:  map[string]string{"application/json": "Record"},
+		Response: jsonResponse,
+	},
+}
+
+// apiSchemas defines MLHub data types used in OpenAPI specification
+var apiSchemas = map[string]reflect.Type{
+	"Record":            reflect.TypeOf(Record{}),
+	"Provenance":        reflect.TypeOf(Provenance{}),
+	"Lineage":           reflect.TypeOf(Lineage{}),
+	"PredictionRecord":  reflect.TypeOf(PredictionRecord{}),
+	"Domain":            reflect.TypeOf(Domain{}),
+	"DomainNode":        reflect.TypeOf(DomainNode{}),
+	"Alias":             reflect.TypeOf(Alias{}),
+	"UploadStatus":      reflect.TypeOf(UploadStatus{}),
+	"BackendStatus":     reflect.TypeOf(BackendStatus{}),
+	"RoutingArm":        reflect.TypeOf(RoutingArm{}),
+	"RoutingRule":       reflect.TypeOf(RoutingRule{}),
+	"RoutingReport":     reflect.TypeOf(RoutingReport{}),
+	"ShadowRule":        reflect.TypeOf(ShadowRule{}),
+	"ShadowReport":      reflect.TypeOf(ShadowReport{}),
+	"AuditEvent":        reflect.TypeOf(AuditEvent{}),
+	"Webhook":           reflect.TypeOf(Webhook{}),
+	"WebhookEvent":      reflect.TypeOf(WebhookEvent{}),
+	"WebhookDelivery":   reflect.TypeOf(WebhookDelivery{}),
+	"ConsistencyReport": reflect.TypeOf(ConsistencyReport{}),
+	"ConsistencyIssue":  reflect.TypeOf(ConsistencyIssue{}),
+	"BackupManifest":    reflect.TypeOf(BackupManifest{}),
+	"BackupEntry":       reflect.TypeOf(BackupEntry{}),
+	"ImportReport":      reflect.TypeOf(ImportReport{}),
+	"ImportResult":      reflect.TypeOf(ImportResult{}),
+	"Mirror":            reflect.TypeOf(Mirror{}),
+	"MirrorReport":      reflect.TypeOf(MirrorReport{}),
+	"HFImport":          reflect.TypeOf(HFImport{}),
+	"Signature":         reflect.TypeOf(Signature{}),
+	"TensorSpec":        reflect.TypeOf(TensorSpec{}),
+	"Conversion":        reflect.TypeOf(Conversion{}),
+	"ServiceResponse":   reflect.TypeOf(services.Response("MLHub", http.StatusOK, 0, nil)),
+}
+
+// gin path parameter pattern
+var pathParamPattern = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// helper function to convert gin path to OpenAPI path, e.g. /model/:name to /model/{name}
+func openAPIPath(path string) string {
+	return pathParamPattern.ReplaceAllString(path, "{$1}")
+}
+
+// helper function to get OpenAPI schema name of given Go type
+func schemaName(t reflect.Type) string {
+	for name, st := range apiSchemas {
+		if st == t {
+			return name
+		}
+	}
+	return ""
+}
+
+// helper function to build JSON schema of given Go type, known MLHub data
+// types are referenced via $ref
+func typeSchema(t reflect.Type, ref bool) map[string]any {
+	if ref {
+		if name := schemaName(t); name != "" {
+			return map[string]any{"$ref": "#/components/schemas/" + name}
+		}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), true)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), true)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), true)}
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		props := make(map[string]any)
+		structProperties(t, props)
+		return map[string]any{"type": "object", "properties": props}
+	}
+	// interface types accept any value
+	return map[string]any{}
+}
+
+// helper function to collect JSON properties of struct fields including
+// fields of embedded structs
+func structProperties(t reflect.Type, props map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			structProperties(field.Type, props)
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = field.Name
+		}
+		props[name] = typeSchema(field.Type, true)
+	}
+}
+
+// helper function to build OpenAPI schema of given schema name, see apiOperation
+func contentSchema(schema string) map[string]any {
+	switch {
+	case schema == "":
+		return map[string]any{}
+	case schema == "binary":
+		return map[string]any{"type": "string", "format": "binary"}
+	case strings.HasPrefix(schema, "[]"):
+		return map[string]any{"type": "array", "items": contentSchema(strings.TrimPrefix(schema, "[]"))}
+	case strings.HasPrefix(schema, "form:"):
+		props := make(map[string]any)
+		for _, key := range strings.Split(strings.TrimPrefix(schema, "form:"), ",") {
+			props[key] = map[string]any{"type": "string"}
+			if key == "file" || key == "image" {
+				props[key] = map[string]any{"type": "string", "format": "binary"}
+			}
+		}
+		return map[string]any{"type": "object", "properties": props}
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + schema}
+}
+
+// helper function to build OpenAPI content object
+func contentObject(content map[string]string) map[string]any {
+	obj := make(map[string]any)
+	for ctype, schema := range content {
+		obj[ctype] = map[string]any{"schema": contentSchema(schema)}
+	}
+	return obj
+}
+
+// helper function to build OpenAPI operation object of given route
+func operationObject(route server.Route, op apiOperation) map[string]any {
+	obj := map[string]any{
+		"summary":     op.Summary,
+		"operationId": strings.TrimSuffix(handlerName(route.Handler), "Handler"),
+	}
+	if op.Description != "" {
+		obj["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		obj["tags"] = op.Tags
+	}
+	var params []map[string]any
+	for _, p := range op.Params {
+		params = append(params, map[string]any{
+			"name":        p.Name,
+			"in":          p.In,
+			"description": p.Description,
+			"required":    p.Required,
+			"schema":      map[string]any{"type": "string"},
+		})
+	}
+	if len(params) > 0 {
+		obj["parameters"] = params
+	}
+	if len(op.Request) > 0 {
+		obj["requestBody"] = map[string]any{"required": true, "content": contentObject(op.Request)}
+	}
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	if len(op.Response) > 0 {
+		success["content"] = contentObject(op.Response)
+	}
+	errorContent := contentObject(jsonResponse)
+	responses := map[string]any{
+		fmt.Sprintf("%d", status): success,
+		"400":                     map[string]any{"description": "Bad Request", "content": errorContent},
+		"500":                     map[string]any{"description": "Internal Server Error", "content": errorContent},
+	}
+	for code, desc := range op.Errors {
+		responses[fmt.Sprintf("%d", code)] = map[string]any{"description": desc, "content": errorContent}
+	}
+	if route.Authorized {
+		scopes := []string{}
+		if route.Scope != "" {
+			scopes = append(scopes, route.Scope)
+		}
+		obj["security"] = []map[string]any{{"bearerAuth": scopes}}
+		responses["401"] = map[string]any{"description": "Unauthorized"}
+		responses["403"] = map[string]any{"description": "Forbidden"}
+	}
+	obj["responses"] = responses
+	return obj
+}
+
+// helper function to get name of handler function
+func handlerName(handler gin.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+	return name
+}
+
+// OpenAPI builds OpenAPI 3 specification of given MLHub routes, it returns
+// error if some route does not have its entry in apiSpecs
+func OpenAPI(routes []server.Route) (map[string]any, error) {
+	paths := make(map[string]map[string]any)
+	var missing []string
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		op, ok := apiSpecs[key]
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		path := openAPIPath(route.Path)
+		if _, ok := paths[path]; !ok {
+			paths[path] = make(map[string]any)
+		}
+		paths[path][strings.ToLower(route.Method)] = operationObject(route, op)
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("[MLHub.main.OpenAPI] no OpenAPI specification for routes: %s", strings.Join(missing, ", "))
+	}
+	schemas := make(map[string]any)
+	for name, t := range apiSchemas {
+		schemas[name] = typeSchema(t, false)
+	}
+	spec := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "MLHub",
+			"description": "MLHub service of FOXDEN to manage ML models and their predictions",
+			"version":     srvConfig.Info(),
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+	if srvConfig.Config != nil && srvConfig.Config.MLHub.WebServer.Base != "" {
+		spec["servers"] = []map[string]any{{"url": srvConfig.Config.MLHub.WebServer.Base}}
+	}
+	return spec, nil
+}
+
+// OpenAPIHandler provides OpenAPI specification of MLHub APIs
+func OpenAPIHandler(c *gin.Context) {
+	spec, err := OpenAPI(routes())
+	if err != nil {
+		rec := services.Response("MLHub", http.StatusInternalServerError, services.GenericError, err)
+		c.JSON(http.StatusInternalServerError, rec)
+		return
+	}
+	c.JSON(http.StatusOK, spec)
+}
+
+// APIDocsHandler provides interactive documentation page of MLHub APIs
+func APIDocsHandler(c *gin.Context) {
+	tmpl := tmplData()
+	tmpl["Title"] = "MLHub APIs"
+	renderPage(c, "apidocs.tmpl", tmpl)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestOpenAPIRoutes tests that every MLHub route has OpenAPI specification
+// entry and every specification entry belongs to registered route
+func TestOpenAPIRoutes(t *testing.T) {
+	registered := make(map[string]bool)
+	for _, route := range routes() {
+		key := route.Method + " " + route.Path
+		registered[key] = true
+		if _, ok := apiSpecs[key]; !ok {
+			t.Errorf("route %s has no OpenAPI specification entry in apiSpecs", key)
+		}
+	}
+	for key := range apiSpecs {
+		if !registered[key] {
+			t.Errorf("OpenAPI specification entry %s does not match any route", key)
+		}
+	}
+}
+
+// TestOpenAPISpec tests OpenAPI document built from MLHub routes
+func TestOpenAPISpec(t *testing.T) {
+	spec, err := OpenAPI(routes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		OpenAPI string                               `json:"openapi"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+		Comps   struct {
+			Schemas map[string]struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("unexpected OpenAPI version %s", doc.OpenAPI)
+	}
+	for _, route := range routes() {
+		path := openAPIPath(route.Path)
+		op, ok := doc.Paths[path][strings.ToLower(route.Method)]
+		if !ok {
+			t.Errorf("no operation for %s %s", route.Method, path)
+			continue
+		}
+		// every path parameter should be declared
+		for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			found := false
+			params, _ := op["parameters"].([]any)
+			for _, p := range params {
+				param := p.(map[string]any)
+				if param["name"] == match[1] && param["in"] == "path" {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("path parameter %s of %s %s is not declared", match[1], route.Method, path)
+			}
+		}
+		if _, ok := op["security"]; ok != route.Authorized {
+			t.Errorf("security of %s %s does not match route authorization", route.Method, path)
+		}
+	}
+	// Record schema is generated from Record data type
+	props := doc.Comps.Schemas["Record"].Properties
+	for _, key := range []string{"model", "type", "backend", "version", "provenance"} {
+		if _, ok := props[key]; !ok {
+			t.Errorf("Record schema has no %s property", key)
+		}
+	}
+	if _, ok := doc.Comps.Schemas["DomainNode"].Properties["name"]; !ok {
+		t.Error("DomainNode schema has no properties of embedded Domain")
+	}
+}
+
+// TestOpenAPIMissing tests that OpenAPI fails for route without specification
+func TestOpenAPIMissing(t *testing.T) {
+	rts := append(routes(), routes()[0])
+	rts[len(rts)-1].Path = "/unknown"
+	if _, err := OpenAPI(rts); err == nil || !strings.Contains(err.Error(), "GET /unknown") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+// predictions module provides provenance records of ML predictions
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	mongo "github.com/CHESSComputing/golib/mongo"
+)
+
+// ProvenanceHeader defines HTTP header which holds prediction provenance ID,
+// clients may also set it to "true" to request recording of their prediction
+const ProvenanceHeader = "X-MLHub-Provenance"
+
+// helper function to generate new random identifier
+func newID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("ERROR: unable to generate random ID, error %v", err)
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// helper function to check if prediction provenance should be recorded
+func recordProvenance(r *http.Request) bool {
+	if HubConfig.Predictions.Record {
+		return true
+	}
+	return strings.ToLower(r.Header.Get(ProvenanceHeader)) == "true"
+}
+
+// recordPrediction stores provenance record of ML prediction and returns its ID
+func recordPrediction(rec Record, r *http.Request, output []byte) (string, error) {
+	digest, err := bundleDigest(rec)
+	if err != nil {
+		return "", fmt.Errorf("[MLHub.main.recordPrediction] bundleDigest error: %w", err)
+	}
+	input, err := inputDigest(rec, r)
+	if err != nil {
+		return "", fmt.Errorf("[MLHub.main.recordPrediction] inputDigest error: %w", err)
+	}
+	prec := PredictionRecord{
+		ID:         newID(),
+		User:       requestUser(r),
+		Model:      rec.Model,
+		Type:       rec.Type,
+		Version:    rec.Version,
+		Digest:     digest,
+		InputHash:  input,
+		OutputHash: dataDigest(output),
+		Backend:    rec.Backend,
+		Timestamp:  time.Now().Unix(),
+	}
+	if Verbose > 0 {
+		log.Printf("record prediction provenance %+v", prec)
+	}
+	var records []any
+	records = append(records, prec)
+	mongo.UpsertAny(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Predictions.Collection,
+		records)
+	return prec.ID, nil
+}
+
+// predictionRecord retrieves provenance record of ML prediction for given ID
+func predictionRecord(id string) (PredictionRecord, error) {
+	var prec PredictionRecord
+	spec := map[string]any{"id": id}
+	results := mongo.Get(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Predictions.Collection,
+		spec, 0, -1)
+	if len(results) != 1 {
+		msg := fmt.Sprintf("unable to find prediction provenance record %s", id)
+		return prec, errors.New(msg)
+	}
+	rec := results[0]
+	delete(rec, "_id")
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return prec, fmt.Errorf("[MLHub.main.predictionRecord] json.Marshal error: %w", err)
+	}
+	err = json.Unmarshal(data, &prec)
+	if err != nil {
+		return prec, fmt.Errorf("[MLHub.main.predictionRecord] json.Unmarshal error: %w", err)
+	}
+	return prec, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+// provenance module provides links between ML models and FOXDEN datasets
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// helper function to get list of values from HTTP form, values can be
+// provided either as multiple form fields or as comma separated list
+func formValues(r *http.Request, key string) []string {
+	var out []string
+	for _, vals := range r.Form[key] {
+		for _, v := range strings.Split(vals, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
+
+// helper function to parse ML model provenance from HTTP form
+func parseProvenance(r *http.Request) (Provenance, error) {
+	prov := Provenance{
+		Datasets:      formValues(r, "datasets"),
+		Evaluation:    formValues(r, "evaluation"),
+		Commit:        r.FormValue("commit"),
+		ParentModel:   r.FormValue("parentmodel"),
+		ParentVersion: r.FormValue("parentversion"),
+	}
+	if params := r.FormValue("parameters"); params != "" {
+		if err := json.Unmarshal([]byte(params), &prov.Parameters); err != nil {
+			return prov, fmt.Errorf("[MLHub.main.parseProvenance] json.Unmarshal error: %w", err)
+		}
+	}
+	return prov, nil
+}
+
+// helper function to validate ML model provenance
+func validateProvenance(prov Provenance) error {
+	for _, did := range append(prov.Datasets, prov.Evaluation...) {
+		if err := validateDID(did); err != nil {
+			return err
+		}
+	}
+	if prov.ParentModel != "" {
+		records, err := metaRecords(prov.ParentModel, "", prov.ParentVersion)
+		if err != nil {
+			return fmt.Errorf("[MLHub.main.validateProvenance] metaRecords error: %w", err)
+		}
+		if len(records) == 0 {
+			msg := fmt.Sprintf("parent model=%s version=%s does not exist", prov.ParentModel, prov.ParentVersion)
+			return errors.New(msg)
+		}
+	}
+	return nil
+}
+
+// helper function to validate FOXDEN dataset identifier (DID) through
+// FOXDEN MetaData service
+func validateDID(did string) error {
+	if !strings.HasPrefix(did, "/") || !strings.Contains(did, "=") {
+		msg := fmt.Sprintf("invalid DID '%s', expected /key=value/... form", did)
+		return errors.New(msg)
+	}
+	srvURL := srvConfig.Config.Services.MetaDataURL
+	if srvURL == "" {
+		if Verbose > 0 {
+			log.Printf("MetaData service is not configured, skip lookup of DID %s", did)
+		}
+		return nil
+	}
+	rurl := fmt.Sprintf("%s/record?did=%s", srvURL, url.QueryEscape(did))
+	resp, err := _httpReadRequest.Get(rurl)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.validateDID] HttpRequest.Get error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg := fmt.Sprintf("unable to lookup DID %s, MetaData service status %s", did, resp.Status)
+		return errors.New(msg)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.validateDID] io.ReadAll error: %w", err)
+	}
+	var records []map[string]any
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("[MLHub.main.validateDID] json.Unmarshal error: %w", err)
+	}
+	if len(records) == 0 {
+		msg := fmt.Sprintf("DID %s is not found in FOXDEN MetaData service", did)
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// datasetModels returns ML models trained or evaluated on given dataset DID
+func datasetModels(did string) ([]Record, error) {
+	spec := map[string]any{
+		"$or": []map[string]any{
+			{"provenance.datasets": did},
+			{"provenance.evaluation": did},
+		},
+	}
+	return metaQuery(spec)
+}
+
+// modelLineage walks lineage of given ML model in both directions, parent
+// ML models are of the same type as ML models derived from them
+func modelLineage(rec Record) (Lineage, error) {
+	lineage := Lineage{Record: rec}
+	visited := map[string]bool{lineageKey(rec.Model, rec.Type, rec.Version): true}
+
+	// walk up to ML models given model was derived from
+	parent := rec.Provenance
+	mlType := rec.Type
+	for parent.ParentModel != "" {
+		prec, ok, err := parentRecord(parent, mlType)
+		if err != nil {
+			return lineage, err
+		}
+		if !ok {
+			log.Printf("WARNING: parent model=%s type=%s version=%s is not found", parent.ParentModel, mlType, parent.ParentVersion)
+			break
+		}
+		key := lineageKey(prec.Model, prec.Type, prec.Version)
+		if visited[key] {
+			break
+		}
+		visited[key] = true
+		lineage.Ancestors = append(lineage.Ancestors, prec)
+		parent = prec.Provenance
+		mlType = prec.Type
+	}
+
+	// walk down to ML models derived from given model, children may refer to
+	// its version by alias or omit it
+	queue := []Record{rec}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		records, err := metaQuery(map[string]any{"provenance.parentmodel": node.Model, "type": node.Type})
+		if err != nil {
+			return lineage, fmt.Errorf("[MLHub.main.modelLineage] metaQuery error: %w", err)
+		}
+		for _, r := range records {
+			key := lineageKey(r.Model, r.Type, r.Version)
+			if visited[key] {
+				continue
+			}
+			if r.Provenance.ParentVersion != node.Version {
+				prec, ok, err := parentRecord(r.Provenance, r.Type)
+				if err != nil {
+					return lineage, err
+				}
+				if !ok || prec.Version != node.Version {
+					continue
+				}
+			}
+			visited[key] = true
+			lineage.Descendants = append(lineage.Descendants, r)
+			queue = append(queue, r)
+		}
+	}
+	return lineage, nil
+}
+
+// helper function to get record of parent ML model of given provenance and
+// ML model type, parent version may be an alias and empty version refers to
+// the most recently updated version of parent ML model
+func parentRecord(prov Provenance, mlType string) (Record, bool, error) {
+	version := resolveVersion(prov.ParentModel, mlType, prov.ParentVersion)
+	records, err := metaRecords(prov.ParentModel, mlType, version)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("[MLHub.main.parentRecord] metaRecords error: %w", err)
+	}
+	if len(records) == 0 {
+		return Record{}, false, nil
+	}
+	latest := records[0]
+	for _, r := range records[1:] {
+		if r.Updated > latest.Updated {
+			latest = r
+		}
+	}
+	return latest, true, nil
+}
+
+// helper function to build lineage key for given model, type and version
+func lineageKey(model, mlType, version string) string {
+	return fmt.Sprintf("%s:%s:%s", model, mlType, version)
+}
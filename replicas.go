@@ -0,0 +1,140 @@
+package main
+
+// replicas module provides load balancing of ML backend replicas
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// supported load balancing strategies of ML backend replicas
+const (
+	RoundRobin       = "round-robin"
+	LeastOutstanding = "least-outstanding"
+	ConsistentHash   = "consistent-hash"
+)
+
+// balancer keeps state of load balancing of ML backend replicas
+var balancer = struct {
+	sync.Mutex
+	next        map[string]int // next replica index of round-robin strategy keyed by backend name
+	outstanding map[string]int // number of outstanding requests keyed by replica URI
+}{next: make(map[string]int), outstanding: make(map[string]int)}
+
+// helper function to get replicas of ML backend, every replica is ML backend
+// record with URI of replica endpoint
+func backendReplicas(backend srvConfig.MLBackend) []srvConfig.MLBackend {
+	rconfig, ok := HubConfig.Replicas[backend.Name]
+	if !ok || len(rconfig.Endpoints) == 0 {
+		return []srvConfig.MLBackend{backend}
+	}
+	var replicas []srvConfig.MLBackend
+	for _, uri := range rconfig.Endpoints {
+		replica := backend
+		replica.URI = uri
+		replicas = append(replicas, replica)
+	}
+	return replicas
+}
+
+// helper function to get load balancing strategy of ML backend
+func backendStrategy(backend srvConfig.MLBackend) string {
+	if rconfig, ok := HubConfig.Replicas[backend.Name]; ok && rconfig.Strategy != "" {
+		return rconfig.Strategy
+	}
+	return RoundRobin
+}
+
+// helper function to check if ML model is placed on given replica, ML models
+// without placement information are placed on all replicas
+func placedOn(rec Record, uri string) bool {
+	if len(rec.Replicas) == 0 {
+		return true
+	}
+	for _, r := range rec.Replicas {
+		if r == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// helper function to compute weight of replica for given key (rendezvous hashing)
+func replicaWeight(key, uri string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(uri))
+	return h.Sum64()
+}
+
+// selectReplica selects healthy replica of ML backend which holds given ML
+// model according to load balancing strategy of the backend. The returned
+// function should be called once request to the replica is completed
+func selectReplica(backend srvConfig.MLBackend, rec Record) (srvConfig.MLBackend, func(), error) {
+	var candidates []srvConfig.MLBackend
+	var placed int
+	var status BackendStatus
+	for _, replica := range backendReplicas(backend) {
+		if !placedOn(rec, replica.URI) {
+			continue
+		}
+		placed++
+		if status = backendHealth(replica); status.Healthy {
+			candidates = append(candidates, replica)
+		}
+	}
+	if placed == 0 {
+		return backend, func() {}, fmt.Errorf("%w: ML model %s is not placed on any replica of %s",
+			errBackendUnavailable, rec.Model, backend.Name)
+	}
+	if len(candidates) == 0 {
+		return backend, func() {}, fmt.Errorf("%w: no healthy replica of %s holds ML model %s, last error: %s",
+			errBackendUnavailable, backend.Name, rec.Model, status.Error)
+	}
+
+	balancer.Lock()
+	defer balancer.Unlock()
+	var replica srvConfig.MLBackend
+	switch backendStrategy(backend) {
+	case LeastOutstanding:
+		replica = candidates[0]
+		for _, c := range candidates[1:] {
+			if balancer.outstanding[c.URI] < balancer.outstanding[replica.URI] {
+				replica = c
+			}
+		}
+	case ConsistentHash:
+		key := fmt.Sprintf("%s/%s/%s", rec.Model, rec.Type, rec.Version)
+		replica = candidates[0]
+		for _, c := range candidates[1:] {
+			if replicaWeight(key, c.URI) > replicaWeight(key, replica.URI) {
+				replica = c
+			}
+		}
+	default:
+		idx := balancer.next[backend.Name]
+		balancer.next[backend.Name] = idx + 1
+		replica = candidates[idx%len(candidates)]
+	}
+	balancer.outstanding[replica.URI]++
+	done := func() {
+		balancer.Lock()
+		balancer.outstanding[replica.URI]--
+		balancer.Unlock()
+	}
+	return replica, done, nil
+}
+
+// helper function to get number of outstanding requests of replica
+func outstandingRequests(uri string) int {
+	balancer.Lock()
+	defer balancer.Unlock()
+	return balancer.outstanding[uri]
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// helper function to set up replicas of test ML backend with given strategy
+func testReplicas(t *testing.T, strategy string, endpoints ...string) srvConfig.MLBackend {
+	HubConfig.defaults()
+	backend := srvConfig.MLBackend{Name: "test-" + strategy, Type: "TensorFlow", URI: endpoints[0]}
+	if HubConfig.Replicas == nil {
+		HubConfig.Replicas = make(map[string]ReplicasConfig)
+	}
+	HubConfig.Replicas[backend.Name] = ReplicasConfig{Endpoints: endpoints, Strategy: strategy}
+	t.Cleanup(func() {
+		delete(HubConfig.Replicas, backend.Name)
+		backendsHealth.Lock()
+		for _, uri := range endpoints {
+			delete(backendsHealth.records, uri)
+		}
+		backendsHealth.Unlock()
+	})
+	return backend
+}
+
+// TestSelectReplicaRoundRobin tests round-robin selection of replicas
+func TestSelectReplicaRoundRobin(t *testing.T) {
+	endpoints := []string{"http://rr-a:8083", "http://rr-b:8083", "http://rr-c:8083"}
+	backend := testReplicas(t, RoundRobin, endpoints...)
+	rec := Record{Model: "mnist", Type: "TensorFlow", Version: "v1"}
+	seen := make(map[string]int)
+	for i := 0; i < 2*len(endpoints); i++ {
+		replica, done, err := selectReplica(backend, rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done()
+		seen[replica.URI]++
+	}
+	for _, uri := range endpoints {
+		if seen[uri] != 2 {
+			t.Fatalf("replica %s was selected %d times, expected 2", uri, seen[uri])
+		}
+	}
+}
+
+// TestSelectReplicaLeastOutstanding tests selection of replica with least
+// outstanding requests
+func TestSelectReplicaLeastOutstanding(t *testing.T) {
+	endpoints := []string{"http://lo-a:8083", "http://lo-b:8083"}
+	backend := testReplicas(t, LeastOutstanding, endpoints...)
+	rec := Record{Model: "mnist", Type: "TensorFlow", Version: "v1"}
+	first, done1, err := selectReplica(backend, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, done2, err := selectReplica(backend, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.URI == second.URI {
+		t.Fatalf("replica %s with outstanding request was selected again", first.URI)
+	}
+	done1()
+	third, done3, err := selectReplica(backend, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.URI != first.URI {
+		t.Fatalf("selected replica %s, expected idle replica %s", third.URI, first.URI)
+	}
+	done2()
+	done3()
+	for _, uri := range endpoints {
+		if n := outstandingRequests(uri); n != 0 {
+			t.Fatalf("replica %s has %d outstanding requests", uri, n)
+		}
+	}
+}
+
+// TestSelectReplicaConsistentHash tests that ML model is always served by
+// the same replica
+func TestSelectReplicaConsistentHash(t *testing.T) {
+	endpoints := []string{"http://ch-a:8083", "http://ch-b:8083", "http://ch-c:8083"}
+	backend := testReplicas(t, ConsistentHash, endpoints...)
+	rec := Record{Model: "mnist", Type: "TensorFlow", Version: "v1"}
+	replica, done, err := selectReplica(backend, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done()
+	for i := 0; i < 10; i++ {
+		r, done, err := selectReplica(backend, rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done()
+		if r.URI != replica.URI {
+			t.Fatalf("selected replica %s, expected %s", r.URI, replica.URI)
+		}
+	}
+}
+
+// TestSelectReplicaHealth tests that only healthy replicas holding ML model
+// are selected
+func TestSelectReplicaHealth(t *testing.T) {
+	endpoints := []string{"http://hl-a:8083", "http://hl-b:8083", "http://hl-c:8083"}
+	backend := testReplicas(t, RoundRobin, endpoints...)
+	rec := Record{Model: "mnist", Type: "TensorFlow", Version: "v1",
+		Replicas: []string{endpoints[0], endpoints[1]}}
+	unhealthy := backend
+	unhealthy.URI = endpoints[0]
+	for i := 0; i < HubConfig.Health.Threshold; i++ {
+		updateHealth(unhealthy, errors.New("connection refused"), 0)
+	}
+	for i := 0; i < 5; i++ {
+		replica, done, err := selectReplica(backend, rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done()
+		if replica.URI != endpoints[1] {
+			t.Fatalf("selected replica %s, expected %s", replica.URI, endpoints[1])
+		}
+	}
+
+	rec.Replicas = []string{endpoints[0]}
+	if _, _, err := selectReplica(backend, rec); !errors.Is(err, errBackendUnavailable) {
+		t.Fatalf("unexpected error %v for ML model on unhealthy replica", err)
+	}
+	rec.Replicas = []string{"http://hl-d:8083"}
+	if _, _, err := selectReplica(backend, rec); !errors.Is(err, errBackendUnavailable) {
+		t.Fatalf("unexpected error %v for ML model not placed on any replica", err)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+// routing module provides canary and A/B routing of ML predictions between
+// versions of ML model
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	mongo "github.com/CHESSComputing/golib/mongo"
+)
+
+// VersionHeader defines HTTP header which holds ML model version served prediction
+const VersionHeader = "X-MLHub-Version"
+
+// RoutingKeyHeader defines HTTP header which holds client's routing key of
+// routing rules sticky by key
+const RoutingKeyHeader = "X-MLHub-Routing-Key"
+
+// supported sticky routing modes
+const (
+	StickyCaller = "caller"
+	StickyKey    = "key"
+)
+
+// routingRules retrieves routing rules matching given spec from MLHub database
+func routingRules(spec map[string]any) ([]RoutingRule, error) {
+	results := mongo.Get(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Routing.Collection,
+		spec, 0, -1)
+	var rules []RoutingRule
+	for _, rec := range results {
+		var rule RoutingRule
+		delete(rec, "_id")
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return rules, fmt.Errorf("[MLHub.main.routingRules] json.Marshal error: %w", err)
+		}
+		err = json.Unmarshal(data, &rule)
+		if err != nil {
+			return rules, fmt.Errorf("[MLHub.main.routingRules] json.Unmarshal error: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// helper function to get spec of rules of ML model, e.g. routing or shadow rules
+func ruleSpec(model, mlType string) map[string]any {
+	spec := map[string]any{"model": model}
+	if mlType != "" {
+		spec["type"] = mlType
+	}
+	return spec
+}
+
+// routingRule retrieves routing rule of given ML model
+func routingRule(model, mlType string) (RoutingRule, error) {
+	var rule RoutingRule
+	rules, err := routingRules(ruleSpec(model, mlType))
+	if err != nil {
+		return rule, err
+	}
+	if len(rules) != 1 {
+		msg := fmt.Sprintf("no routing rule found for model=%s type=%s", model, mlType)
+		if len(rules) > 1 {
+			msg = fmt.Sprintf("ambiguous routing rules for model=%s, please provide its type", model)
+		}
+		return rule, errors.New(msg)
+	}
+	return rules[0], nil
+}
+
+// setRoutingRule validates and stores routing rule of ML model, it replaces
+// existing rule of ML model
+func setRoutingRule(rule RoutingRule) error {
+	if rule.Model == "" || rule.Type == "" || len(rule.Arms) == 0 {
+		return errors.New("routing rule requires model, type and arms")
+	}
+	switch rule.Sticky {
+	case "", StickyCaller, StickyKey:
+	default:
+		msg := fmt.Sprintf("unsupported sticky routing '%s', should be %s or %s", rule.Sticky, StickyCaller, StickyKey)
+		return errors.New(msg)
+	}
+	for _, arm := range rule.Arms {
+		if arm.Weight <= 0 {
+			msg := fmt.Sprintf("weight of version %s should be positive", arm.Version)
+			return errors.New(msg)
+		}
+		version := resolveVersion(rule.Model, rule.Type, arm.Version)
+		records, err := metaRecords(rule.Model, rule.Type, version)
+		if err != nil {
+			return fmt.Errorf("[MLHub.main.setRoutingRule] metaRecords error: %w", err)
+		}
+		if arm.Version == "" || len(records) != 1 {
+			msg := fmt.Sprintf("ML model %s type %s version '%s' does not exist", rule.Model, rule.Type, arm.Version)
+			return errors.New(msg)
+		}
+	}
+	if err := removeRoutingRule(rule.Model, rule.Type); err != nil {
+		return err
+	}
+	if Verbose > 0 {
+		log.Printf("set routing rule %+v", rule)
+	}
+	var records []any
+	records = append(records, rule)
+	err := mongo.UpsertAny(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Routing.Collection,
+		records)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.setRoutingRule] mongo.UpsertAny error: %w", err)
+	}
+	return nil
+}
+
+// removeRoutingRule removes routing rule of ML model
+func removeRoutingRule(model, mlType string) error {
+	err := mongo.Remove(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Routing.Collection,
+		map[string]any{"model": model, "type": mlType})
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.removeRoutingRule] mongo.Remove error: %w", err)
+	}
+	return nil
+}
+
+// helper function to select arm of routing rule for given prediction request,
+// sticky rules hash caller or routing key to the same arm while requests
+// without caller or key are split randomly
+func selectArm(rule RoutingRule, r *http.Request) RoutingArm {
+	var total int
+	for _, arm := range rule.Arms {
+		total += arm.Weight
+	}
+	var key string
+	switch rule.Sticky {
+	case StickyCaller:
+		key = requestUser(r)
+	case StickyKey:
+		key = r.Header.Get(RoutingKeyHeader)
+	}
+	var point int
+	if key != "" {
+		h := fnv.New64a()
+		h.Write([]byte(rule.Model + "/" + rule.Type + "/" + key))
+		point = int(h.Sum64() % uint64(total))
+	} else {
+		point = rand.Intn(total)
+	}
+	for _, arm := range rule.Arms {
+		if point < arm.Weight {
+			return arm
+		}
+		point -= arm.Weight
+	}
+	return rule.Arms[len(rule.Arms)-1]
+}
+
+// routedRecord evaluates routing rule of ML model for given prediction spec
+// and request and returns ML record of selected version. Routing rules apply
+// only to requests which do not ask for specific version, the returned flag
+// reports if request was routed by the rule
+func routedRecord(spec Record, r *http.Request) (Record, bool, error) {
+	var routed bool
+	if spec.Version == "" {
+		if rule, err := routingRule(spec.Model, spec.Type); err == nil {
+			arm := selectArm(rule, r)
+			if Verbose > 0 {
+				log.Printf("route prediction of model %s to version %s", spec.Model, arm.Version)
+			}
+			spec.Version = arm.Version
+			routed = true
+		}
+	}
+	rec, err := modelRecord(r.Context(), spec)
+	return rec, routed, err
+}
+
+// helper function to get HTTP status code of prediction with given error
+func predictStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	if errors.Is(err, errBackendUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// recordOutcome logs outcome of prediction routed by routing rule
+func recordOutcome(rec Record, r *http.Request, status int, latency time.Duration, perr error) {
+	outcome := RoutingOutcome{
+		Model:     rec.Model,
+		Type:      rec.Type,
+		Version:   rec.Version,
+		User:      requestUser(r),
+		Status:    status,
+		Latency:   float64(latency.Microseconds()) / 1000,
+		Timestamp: time.Now().Unix(),
+	}
+	if perr != nil {
+		outcome.Error = perr.Error()
+	}
+	if Verbose > 0 {
+		log.Printf("routing outcome %+v", outcome)
+	}
+	var records []any
+	records = append(records, outcome)
+	err := mongo.UpsertAny(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Routing.Outcomes,
+		records)
+	if err != nil {
+		log.Printf("ERROR: unable to record routing outcome %+v, error %v", outcome, err)
+	}
+}
+
+// routingReport evaluates arms of routing rule using outcomes of routed predictions
+func routingReport(rule RoutingRule) ([]RoutingReport, error) {
+	results := mongo.Get(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Routing.Outcomes,
+		map[string]any{"model": rule.Model, "type": rule.Type}, 0, -1)
+	var reports []RoutingReport
+	index := make(map[string]int)
+	for _, arm := range rule.Arms {
+		version := resolveVersion(rule.Model, rule.Type, arm.Version)
+		index[version] = len(reports)
+		reports = append(reports, RoutingReport{Version: version, Weight: arm.Weight})
+	}
+	var total int
+	for _, rec := range results {
+		var outcome RoutingOutcome
+		delete(rec, "_id")
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return reports, fmt.Errorf("[MLHub.main.routingReport] json.Marshal error: %w", err)
+		}
+		err = json.Unmarshal(data, &outcome)
+		if err != nil {
+			return reports, fmt.Errorf("[MLHub.main.routingReport] json.Unmarshal error: %w", err)
+		}
+		// versions which are no longer part of the rule are still reported
+		idx, ok := index[outcome.Version]
+		if !ok {
+			idx = len(reports)
+			index[outcome.Version] = idx
+			reports = append(reports, RoutingReport{Version: outcome.Version})
+		}
+		report := &reports[idx]
+		report.Requests++
+		if outcome.Status != http.StatusOK {
+			report.Errors++
+		}
+		report.Latency += outcome.Latency
+		total++
+	}
+	for idx := range reports {
+		report := &reports[idx]
+		if report.Requests > 0 {
+			report.Latency /= float64(report.Requests)
+			report.Share = float64(report.Requests) / float64(total)
+		}
+	}
+	return reports, nil
+}
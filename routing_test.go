@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestSelectArmWeights tests random split of predictions between arms of
+// routing rule according to their weights
+func TestSelectArmWeights(t *testing.T) {
+	rule := RoutingRule{Model: "mnist", Type: "TensorFlow",
+		Arms: []RoutingArm{{Version: "v1", Weight: 90}, {Version: "v2", Weight: 10}}}
+	r := httptest.NewRequest("POST", "/predict", nil)
+	counts := make(map[string]int)
+	total := 10000
+	for i := 0; i < total; i++ {
+		counts[selectArm(rule, r).Version]++
+	}
+	if counts["v2"] < total/20 || counts["v2"] > total*3/20 {
+		t.Fatalf("version v2 with weight 10 got %d of %d predictions", counts["v2"], total)
+	}
+
+	rule.Arms = []RoutingArm{{Version: "v1", Weight: 1}}
+	for i := 0; i < 10; i++ {
+		if arm := selectArm(rule, r); arm.Version != "v1" {
+			t.Fatalf("selected version %s of single arm rule", arm.Version)
+		}
+	}
+}
+
+// TestSelectArmSticky tests that requests with the same routing key are
+// routed to the same arm
+func TestSelectArmSticky(t *testing.T) {
+	rule := RoutingRule{Model: "mnist", Type: "TensorFlow", Sticky: StickyKey,
+		Arms: []RoutingArm{{Version: "v1", Weight: 50}, {Version: "v2", Weight: 50}}}
+	counts := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		r := httptest.NewRequest("POST", "/predict", nil)
+		r.Header.Set(RoutingKeyHeader, fmt.Sprintf("session-%d", i))
+		arm := selectArm(rule, r)
+		for j := 0; j < 5; j++ {
+			if a := selectArm(rule, r); a.Version != arm.Version {
+				t.Fatalf("routing key session-%d was routed to %s and %s", i, arm.Version, a.Version)
+			}
+		}
+		counts[arm.Version]++
+	}
+	if counts["v1"] == 0 || counts["v2"] == 0 {
+		t.Fatalf("routing keys were not split between arms: %v", counts)
+	}
+}
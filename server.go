@@ -5,6 +5,7 @@ package main
 // Copyright (c) 2023 - Valentin Kuznetsov <vkuznet@gmail.com>
 //
 import (
+	"context"
 	"embed"
 	"log"
 	"net/http"
@@ -25,20 +26,74 @@ var _httpReadRequest *services.HttpRequest
 var Verbose int
 var StaticDir, StorageDir string
 
-// helper function to setup our router
-func setupRouter() *gin.Engine {
-	routes := []server.Route{
+// helper function to provide routes of MLHub APIs, every route should have
+// its OpenAPI specification entry in apiSpecs
+func routes() []server.Route {
+	return []server.Route{
 		{Method: "GET", Path: "/docs/:name", Handler: DocsHandler, Authorized: false},
+		{Method: "GET", Path: "/openapi.json", Handler: OpenAPIHandler, Authorized: false},
+		{Method: "GET", Path: "/apidocs", Handler: APIDocsHandler, Authorized: false},
 		{Method: "GET", Path: "/models", Handler: ModelsHandler, Authorized: false},
+		{Method: "GET", Path: "/upload", Handler: UploadPageHandler, Authorized: false},
+		{Method: "GET", Path: "/download", Handler: DownloadPageHandler, Authorized: false},
+		{Method: "GET", Path: "/inference", Handler: InferencePageHandler, Authorized: false},
+		{Method: "GET", Path: "/domains", Handler: DomainsHandler, Authorized: false},
+		{Method: "GET", Path: "/backends", Handler: BackendsHandler, Authorized: false},
+		{Method: "GET", Path: "/metrics", Handler: MetricsHandler, Authorized: false},
 		{Method: "GET", Path: "/models/:name", Handler: DownloadHandler, Authorized: true},
+		{Method: "GET", Path: "/model/:name", Handler: ModelPageHandler, Authorized: false},
+		{Method: "GET", Path: "/model/:name/jsonld", Handler: JSONLDHandler, Authorized: false},
+		{Method: "GET", Path: "/model/:name/rocrate", Handler: ROCrateHandler, Authorized: true},
+		{Method: "GET", Path: "/lineage/dataset", Handler: DatasetLineageHandler, Authorized: false},
+		{Method: "GET", Path: "/lineage/model/:name", Handler: ModelLineageHandler, Authorized: false},
+		{Method: "GET", Path: "/provenance/:name", Handler: ProvenanceHandler, Authorized: true, Scope: "read"},
+		{Method: "GET", Path: "/routing/:name", Handler: RoutingHandler, Authorized: false},
+		{Method: "GET", Path: "/routing/:name/report", Handler: RoutingReportHandler, Authorized: true, Scope: "read"},
+		{Method: "GET", Path: "/shadow/:name", Handler: ShadowHandler, Authorized: false},
+		{Method: "GET", Path: "/shadow/:name/report", Handler: ShadowReportHandler, Authorized: true, Scope: "read"},
+		{Method: "GET", Path: "/audit", Handler: AuditHandler, Authorized: true, Scope: "read"},
+		{Method: "GET", Path: "/audit/export", Handler: AuditExportHandler, Authorized: true, Scope: "read"},
+		{Method: "GET", Path: "/webhooks", Handler: WebhooksHandler, Authorized: true, Scope: "read"},
+		{Method: "GET", Path: "/trash", Handler: TrashHandler, Authorized: true, Scope: "read"},
+		{Method: "GET", Path: "/consistency", Handler: ConsistencyHandler, Authorized: true, Scope: "read"},
+		{Method: "GET", Path: "/export", Handler: ExportHandler, Authorized: true, Scope: "read"},
+		{Method: "GET", Path: "/federation", Handler: FederationHandler, Authorized: true, Scope: "read"},
+		{Method: "GET", Path: "/webhooks/:name/deliveries", Handler: WebhookDeliveriesHandler, Authorized: true, Scope: "read"},
 
 		{Method: "POST", Path: "/predict", Handler: PredictHandler, Authorized: true, Scope: "read"},
 		{Method: "POST", Path: "/upload", Handler: UploadHandler, Authorized: true, Scope: "write"},
 
+		{Method: "POST", Path: "/domains", Handler: DomainUpsertHandler, Authorized: true, Scope: "write"},
+		{Method: "POST", Path: "/promote", Handler: PromoteHandler, Authorized: true, Scope: "write"},
+		{Method: "POST", Path: "/routing", Handler: RoutingUpsertHandler, Authorized: true, Scope: "write"},
+		{Method: "POST", Path: "/shadow", Handler: ShadowUpsertHandler, Authorized: true, Scope: "write"},
+		{Method: "POST", Path: "/webhooks", Handler: WebhookUpsertHandler, Authorized: true, Scope: "write"},
+		{Method: "POST", Path: "/restore", Handler: RestoreHandler, Authorized: true, Scope: "write"},
+		{Method: "POST", Path: "/consistency", Handler: ConsistencyCheckHandler, Authorized: true, Scope: "write"},
+		{Method: "POST", Path: "/import", Handler: ImportHandler, Authorized: true, Scope: "write"},
+		{Method: "POST", Path: "/import/huggingface", Handler: HFImportHandler, Authorized: true, Scope: "write"},
+		{Method: "POST", Path: "/federation", Handler: FederationPullHandler, Authorized: true, Scope: "write"},
+		{Method: "GET", Path: "/uploads/:name", Handler: UploadStatusHandler, Authorized: true, Scope: "write"},
+		{Method: "PUT", Path: "/uploads/:name", Handler: UploadChunkHandler, Authorized: true, Scope: "write"},
+
 		{Method: "DELETE", Path: "/delete", Handler: DeleteHandler, Authorized: true, Scope: "delete"},
+		{Method: "DELETE", Path: "/purge", Handler: PurgeHandler, Authorized: true, Scope: "delete"},
+		{Method: "DELETE", Path: "/domains/:name", Handler: DomainDeleteHandler, Authorized: true, Scope: "delete"},
+		{Method: "DELETE", Path: "/routing/:name", Handler: RoutingDeleteHandler, Authorized: true, Scope: "delete"},
+		{Method: "DELETE", Path: "/shadow/:name", Handler: ShadowDeleteHandler, Authorized: true, Scope: "delete"},
+		{Method: "DELETE", Path: "/webhooks/:name", Handler: WebhookDeleteHandler, Authorized: true, Scope: "delete"},
 	}
+}
 
-	r := server.Router(routes, nil, "static", srvConfig.Config.MLHub.WebServer)
+// helper function to setup our router
+func setupRouter() *gin.Engine {
+	var rts []server.Route
+	for _, route := range routes() {
+		route.Handler = tracingHandler(route)
+		route.Handler = metricsHandler(route)
+		rts = append(rts, route)
+	}
+	r := server.Router(rts, nil, "static", srvConfig.Config.MLHub.WebServer)
 	r.StaticFS("/bundles", http.Dir(StorageDir))
 	return r
 }
@@ -55,6 +110,37 @@ func Server() {
 	log.Println("init mongo", srvConfig.Config.MLHub.MongoDB.DBUri)
 	mongo.InitMongoDB(srvConfig.Config.MLHub.MongoDB.DBUri)
 
+	// init tracing of MLHub APIs and ML backends
+	shutdown, err := InitTracing(HubConfig.Tracing)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	// start health probes of ML backends
+	go HealthProbes()
+
+	// start purge of ML models which are in trash longer than retention period
+	go TrashPurger()
+
+	// start removal of stale resumable uploads
+	go UploadsPurger()
+
+	// start scheduled consistency checks of storage, meta-data and ML backends
+	if HubConfig.Consistency.Interval > 0 {
+		go ConsistencyChecks()
+	}
+
+	// start scheduled pulls of ML models from remote MLHub instances
+	if HubConfig.Federation.Interval > 0 && len(HubConfig.Federation.Remotes) > 0 {
+		go Federation()
+	}
+
+	// start gRPC service on its own port
+	if HubConfig.GRPC.Port > 0 {
+		go GRPCServer()
+	}
+
 	// setup web router and start the service
 	r := setupRouter()
 	webServer := srvConfig.Config.MLHub.WebServer
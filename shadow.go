@@ -0,0 +1,340 @@
+package main
+
+// shadow module provides shadow deployments of ML models, i.e. shadow
+// version of ML model receives copy of live predictions and its outputs are
+// compared with outputs of version which served them
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	mongo "github.com/CHESSComputing/golib/mongo"
+)
+
+// shadowSlots limits number of concurrent shadow predictions
+var shadowSlots chan struct{}
+var shadowOnce sync.Once
+
+// shadowRules retrieves shadow rules matching given spec from MLHub database
+func shadowRules(spec map[string]any) ([]ShadowRule, error) {
+	results := mongo.Get(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Shadow.Collection,
+		spec, 0, -1)
+	var rules []ShadowRule
+	for _, rec := range results {
+		var rule ShadowRule
+		delete(rec, "_id")
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return rules, fmt.Errorf("[MLHub.main.shadowRules] json.Marshal error: %w", err)
+		}
+		err = json.Unmarshal(data, &rule)
+		if err != nil {
+			return rules, fmt.Errorf("[MLHub.main.shadowRules] json.Unmarshal error: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// shadowRule retrieves shadow rule of given ML model
+func shadowRule(model, mlType string) (ShadowRule, error) {
+	var rule ShadowRule
+	rules, err := shadowRules(ruleSpec(model, mlType))
+	if err != nil {
+		return rule, err
+	}
+	if len(rules) != 1 {
+		msg := fmt.Sprintf("no shadow deployment found for model=%s type=%s", model, mlType)
+		if len(rules) > 1 {
+			msg = fmt.Sprintf("ambiguous shadow deployments for model=%s, please provide its type", model)
+		}
+		return rule, errors.New(msg)
+	}
+	return rules[0], nil
+}
+
+// setShadowRule validates and stores shadow rule of ML model, it replaces
+// existing shadow deployment of ML model
+func setShadowRule(rule ShadowRule) error {
+	if rule.Model == "" || rule.Type == "" || rule.Version == "" {
+		return errors.New("shadow deployment requires model, type and version")
+	}
+	if rule.Sample == 0 {
+		rule.Sample = 1
+	}
+	if rule.Sample < 0 || rule.Sample > 1 {
+		return errors.New("sample of shadow deployment should be within (0, 1] range")
+	}
+	version := resolveVersion(rule.Model, rule.Type, rule.Version)
+	records, err := metaRecords(rule.Model, rule.Type, version)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.setShadowRule] metaRecords error: %w", err)
+	}
+	if len(records) != 1 {
+		msg := fmt.Sprintf("ML model %s type %s version %s does not exist", rule.Model, rule.Type, rule.Version)
+		return errors.New(msg)
+	}
+	if err := removeShadowRule(rule.Model, rule.Type); err != nil {
+		return err
+	}
+	if Verbose > 0 {
+		log.Printf("set shadow deployment %+v", rule)
+	}
+	var rules []any
+	rules = append(rules, rule)
+	err = mongo.UpsertAny(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Shadow.Collection,
+		rules)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.setShadowRule] mongo.UpsertAny error: %w", err)
+	}
+	return nil
+}
+
+// removeShadowRule removes shadow deployment of ML model
+func removeShadowRule(model, mlType string) error {
+	err := mongo.Remove(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Shadow.Collection,
+		map[string]any{"model": model, "type": mlType})
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.removeShadowRule] mongo.Remove error: %w", err)
+	}
+	return nil
+}
+
+// helper function to build copy of prediction request which outlives
+// original HTTP request, form files are copied into memory since they are
+// removed once original request is completed
+func detachedRequest(r *http.Request) (*http.Request, error) {
+	// keep values of original request context, e.g. its trace, but not its cancellation
+	ctx := context.WithoutCancel(r.Context())
+	if r.MultipartForm == nil {
+		req := r.Clone(ctx)
+		req.Body = http.NoBody
+		return req, nil
+	}
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for k, vals := range r.MultipartForm.Value {
+		for _, v := range vals {
+			writer.WriteField(k, v)
+		}
+	}
+	for k, vals := range r.MultipartForm.File {
+		for _, fh := range vals {
+			fw, err := writer.CreateFormFile(k, fh.Filename)
+			if err != nil {
+				return nil, err
+			}
+			file, err := fh.Open()
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.Copy(fw, file)
+			file.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	writer.Close()
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"Authorization", "Accept"} {
+		req.Header.Set(key, r.Header.Get(key))
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := req.ParseMultipartForm(32 << 20); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// shadowPredict sends copy of successful prediction of ML model to its shadow
+// version (if any) and stores outputs of both versions. Shadow prediction is
+// performed asynchronously and never affects response of primary version,
+// i.e. it is skipped when all shadow workers are busy
+func shadowPredict(rec Record, r *http.Request, output []byte, latency time.Duration) {
+	rule, err := shadowRule(rec.Model, rec.Type)
+	if err != nil {
+		return
+	}
+	version := resolveVersion(rule.Model, rule.Type, rule.Version)
+	if version == rec.Version || rand.Float64() >= rule.Sample {
+		return
+	}
+	shadowOnce.Do(func() {
+		shadowSlots = make(chan struct{}, HubConfig.Shadow.Workers)
+	})
+	select {
+	case shadowSlots <- struct{}{}:
+	default:
+		if Verbose > 0 {
+			log.Printf("skip shadow prediction of model %s version %s, all workers are busy", rec.Model, version)
+		}
+		return
+	}
+	req, err := detachedRequest(r)
+	if err != nil {
+		<-shadowSlots
+		log.Printf("ERROR: unable to copy prediction request of model %s, error %v", rec.Model, err)
+		return
+	}
+	go func() {
+		defer func() { <-shadowSlots }()
+		result := ShadowResult{
+			Model:          rec.Model,
+			Type:           rec.Type,
+			Primary:        rec.Version,
+			Shadow:         version,
+			PrimaryOutput:  string(output),
+			PrimaryLatency: float64(latency.Microseconds()) / 1000,
+			Timestamp:      time.Now().Unix(),
+		}
+		srec, err := modelRecord(req.Context(), Record{Model: rec.Model, Type: rec.Type, Version: version, Input: rec.Input})
+		if err == nil {
+			time0 := time.Now()
+			var data []byte
+			data, _, err = Predict(srec, req)
+			result.ShadowLatency = float64(time.Since(time0).Microseconds()) / 1000
+			result.ShadowOutput = string(data)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Agree = outputsAgree([]byte(result.PrimaryOutput), []byte(result.ShadowOutput), HubConfig.Shadow.Tolerance)
+		}
+		if Verbose > 0 {
+			log.Printf("shadow prediction %+v", result)
+		}
+		var records []any
+		records = append(records, result)
+		err = mongo.UpsertAny(
+			srvConfig.Config.MLHub.MongoDB.DBName,
+			HubConfig.Shadow.Results,
+			records)
+		if err != nil {
+			log.Printf("ERROR: unable to store shadow prediction of model %s, error %v", rec.Model, err)
+		}
+	}()
+}
+
+// helper function to check if prediction outputs agree, JSON outputs are
+// compared by their values with given relative tolerance of numbers while
+// other outputs should be identical
+func outputsAgree(a, b []byte, tolerance float64) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	return valuesAgree(va, vb, tolerance)
+}
+
+// helper function to compare decoded JSON values with given tolerance
+func valuesAgree(a, b any, tolerance float64) bool {
+	switch va := a.(type) {
+	case float64:
+		vb, ok := b.(float64)
+		if !ok {
+			return false
+		}
+		diff := math.Abs(va - vb)
+		return diff <= tolerance || diff <= tolerance*math.Max(math.Abs(va), math.Abs(vb))
+	case []any:
+		vb, ok := b.([]any)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if !valuesAgree(va[i], vb[i], tolerance) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		vb, ok := b.(map[string]any)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for k, v := range va {
+			if !valuesAgree(v, vb[k], tolerance) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// shadowReport summarizes disagreement and latency of shadow predictions of
+// ML model grouped by its primary and shadow versions
+func shadowReport(model, mlType string) ([]ShadowReport, error) {
+	results := mongo.Get(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Shadow.Results,
+		ruleSpec(model, mlType), 0, -1)
+	var reports []ShadowReport
+	index := make(map[string]int)
+	for _, rec := range results {
+		var result ShadowResult
+		delete(rec, "_id")
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return reports, fmt.Errorf("[MLHub.main.shadowReport] json.Marshal error: %w", err)
+		}
+		err = json.Unmarshal(data, &result)
+		if err != nil {
+			return reports, fmt.Errorf("[MLHub.main.shadowReport] json.Unmarshal error: %w", err)
+		}
+		key := result.Primary + "/" + result.Shadow
+		idx, ok := index[key]
+		if !ok {
+			idx = len(reports)
+			index[key] = idx
+			reports = append(reports, ShadowReport{Primary: result.Primary, Shadow: result.Shadow})
+		}
+		report := &reports[idx]
+		report.Requests++
+		if result.Error != "" {
+			report.Errors++
+			continue
+		}
+		if !result.Agree {
+			report.Disagreements++
+		}
+		report.PrimaryLatency += result.PrimaryLatency
+		report.ShadowLatency += result.ShadowLatency
+	}
+	for idx := range reports {
+		report := &reports[idx]
+		if succeeded := report.Requests - report.Errors; succeeded > 0 {
+			report.DisagreementRate = float64(report.Disagreements) / float64(succeeded)
+			report.PrimaryLatency /= float64(succeeded)
+			report.ShadowLatency /= float64(succeeded)
+			report.LatencyDiff = report.ShadowLatency - report.PrimaryLatency
+		}
+	}
+	return reports, nil
+}
@@ -0,0 +1,196 @@
+package main
+
+// tracing module provides OpenTelemetry tracing of MLHub APIs, meta-data and
+// storage calls and requests to ML backends
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	server "github.com/CHESSComputing/golib/server"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// supported exporters of traces
+const (
+	OTLPExporter   = "otlp"
+	StdoutExporter = "stdout"
+)
+
+// tracer provides spans of MLHub
+var tracer = otel.Tracer("github.com/CHESSComputing/MLHub")
+
+// InitTracing initializes OpenTelemetry tracing with given configuration, W3C
+// trace context is always propagated while spans are exported only when
+// exporter is configured. It returns function to flush and stop exporter
+func InitTracing(config TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case OTLPExporter:
+		var opts []otlptracehttp.Option
+		if strings.Contains(config.Endpoint, "://") {
+			opts = append(opts, otlptracehttp.WithEndpointURL(config.Endpoint))
+		} else if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	case StdoutExporter:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		err = fmt.Errorf("unsupported exporter '%s'", config.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[MLHub.main.InitTracing] exporter error: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.Ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", config.Service))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// helper function to start span of given context
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// helper function to end span and record its error (if any)
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// helper function to get span attributes of ML record, attribute names match
+// JSON names of ML record fields
+func recordAttributes(rec Record) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("mlhub.model", rec.Model),
+		attribute.String("mlhub.type", rec.Type),
+		attribute.String("mlhub.version", rec.Version),
+		attribute.String("mlhub.backend", rec.Backend),
+	}
+}
+
+// helper function to wrap route handler with its server span, the span
+// continues trace of incoming W3C trace context headers
+func tracingHandler(route server.Route) gin.HandlerFunc {
+	handler := route.Handler
+	name := fmt.Sprintf("%s %s", route.Method, route.Path)
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", route.Method),
+				attribute.String("http.route", route.Path),
+			))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		handler(c)
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if val, ok := c.Get(metricsRecordKey); ok {
+			if rec, ok := val.(Record); ok {
+				span.SetAttributes(recordAttributes(rec)...)
+			}
+		}
+		if status >= 500 {
+			span.SetStatus(codes.Error, strconv.Itoa(status))
+		}
+	}
+}
+
+// metadataCarrier adapts gRPC metadata to propagation.TextMapCarrier
+type metadataCarrier metadata.MD
+
+// Get implements propagation.TextMapCarrier interface
+func (m metadataCarrier) Get(key string) string {
+	if vals := metadata.MD(m).Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// Set implements propagation.TextMapCarrier interface
+func (m metadataCarrier) Set(key, value string) {
+	metadata.MD(m).Set(key, value)
+}
+
+// Keys implements propagation.TextMapCarrier interface
+func (m metadataCarrier) Keys() []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// helper function to start server span of gRPC call
+func grpcSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	return tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.system", "grpc"), attribute.String("rpc.method", method)))
+}
+
+// helper function to trace unary gRPC calls
+func grpcUnaryTracing(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, span := grpcSpan(ctx, info.FullMethod)
+	if mreq, ok := req.(grpcModelRequest); ok {
+		rec := Record{Model: mreq.GetModel(), Type: mreq.GetType(), Version: mreq.GetVersion(), Backend: mreq.GetBackend()}
+		span.SetAttributes(recordAttributes(rec)...)
+	}
+	rsp, err := handler(ctx, req)
+	endSpan(span, err)
+	return rsp, err
+}
+
+// contextStream provides gRPC server stream with given context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context implements grpc.ServerStream interface
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// helper function to trace streaming gRPC calls
+func grpcStreamTracing(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, span := grpcSpan(ss.Context(), info.FullMethod)
+	err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	endSpan(span, err)
+	return err
+}
@@ -0,0 +1,292 @@
+package main
+
+// transport module provides shared HTTP transport of ML backends with
+// connection pooling, retries and circuit breakers
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// circuitBreaker represents circuit breaker of ML backend replica, it opens
+// after configured number of consecutive failures, rejects requests while
+// open and lets single trial request through once reset timeout passed
+type circuitBreaker struct {
+	sync.Mutex
+	state    string
+	failures int
+	opened   time.Time
+	trial    bool
+}
+
+// helper function to check if request is allowed by circuit breaker
+func (b *circuitBreaker) allow(config TransportConfig) bool {
+	b.Lock()
+	defer b.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.opened) < time.Duration(config.BreakerReset)*time.Second {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+		return true
+	case BreakerHalfOpen:
+		// only single trial request is allowed in half-open state
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+// helper function to record outcome of request in circuit breaker
+func (b *circuitBreaker) record(config TransportConfig, failed bool) {
+	b.Lock()
+	defer b.Unlock()
+	b.trial = false
+	if !failed {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= config.BreakerFailures {
+		b.state = BreakerOpen
+		b.opened = time.Now()
+	}
+}
+
+// helper function to release trial request of circuit breaker without
+// recording its outcome, e.g. when request was cancelled by client
+func (b *circuitBreaker) release() {
+	b.Lock()
+	defer b.Unlock()
+	b.trial = false
+}
+
+// helper function to get state of circuit breaker
+func (b *circuitBreaker) State() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.state
+}
+
+// backendClient represents HTTP client of ML backend, it is shared by all
+// requests to the ML backend and keeps circuit breakers of its replicas
+type backendClient struct {
+	name     string
+	config   TransportConfig
+	client   *http.Client
+	mutex    sync.Mutex
+	breakers map[string]*circuitBreaker // circuit breakers keyed by replica host
+}
+
+// backendClients keeps HTTP clients of ML backends keyed by backend name
+var backendClients = struct {
+	sync.Mutex
+	clients map[string]*backendClient
+}{clients: make(map[string]*backendClient)}
+
+// helper function to get transport configuration of ML backend
+func transportConfig(name string) TransportConfig {
+	config := HubConfig.Transport
+	if bconfig, ok := HubConfig.Transports[name]; ok {
+		if bconfig.ConnectTimeout > 0 {
+			config.ConnectTimeout = bconfig.ConnectTimeout
+		}
+		if bconfig.ReadTimeout > 0 {
+			config.ReadTimeout = bconfig.ReadTimeout
+		}
+		if bconfig.Retries > 0 {
+			config.Retries = bconfig.Retries
+		}
+		if bconfig.Backoff > 0 {
+			config.Backoff = bconfig.Backoff
+		}
+		if bconfig.MaxConns > 0 {
+			config.MaxConns = bconfig.MaxConns
+		}
+		if bconfig.BreakerFailures > 0 {
+			config.BreakerFailures = bconfig.BreakerFailures
+		}
+		if bconfig.BreakerReset > 0 {
+			config.BreakerReset = bconfig.BreakerReset
+		}
+	}
+	return config
+}
+
+// httpClient returns shared HTTP client of ML backend with given name
+func httpClient(name string) *backendClient {
+	backendClients.Lock()
+	defer backendClients.Unlock()
+	if bc, ok := backendClients.clients[name]; ok {
+		return bc
+	}
+	config := transportConfig(name)
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(config.ConnectTimeout) * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          config.MaxConns,
+		MaxIdleConnsPerHost:   config.MaxConns,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   time.Duration(config.ConnectTimeout) * time.Second,
+		ResponseHeaderTimeout: time.Duration(config.ReadTimeout) * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	bc := &backendClient{
+		name:   name,
+		config: config,
+		client: &http.Client{
+			// traces requests to ML backend and propagates W3C trace context
+			Transport: otelhttp.NewTransport(transport,
+				otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+					return fmt.Sprintf("%s %s", name, r.Method)
+				})),
+		},
+		breakers: make(map[string]*circuitBreaker),
+	}
+	backendClients.clients[name] = bc
+	return bc
+}
+
+// helper function to get circuit breaker of given replica host
+func (bc *backendClient) breaker(host string) *circuitBreaker {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	b, ok := bc.breakers[host]
+	if !ok {
+		b = &circuitBreaker{state: BreakerClosed}
+		bc.breakers[host] = b
+	}
+	return b
+}
+
+// helper function to check if response status code is worth to retry
+func retryStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable ||
+		code == http.StatusGatewayTimeout
+}
+
+// Do performs HTTP request to ML backend. Idempotent requests are retried on
+// connection errors and temporary failures with exponential backoff and
+// jitter. Requests to replica with open circuit breaker fail immediately
+// with errBackendUnavailable error
+func (bc *backendClient) Do(req *http.Request, idempotent bool) (*http.Response, error) {
+	breaker := bc.breaker(req.URL.Host)
+	retries := 0
+	if idempotent {
+		retries = bc.config.Retries
+	}
+	delay := time.Duration(bc.config.Backoff) * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		if !breaker.allow(bc.config) {
+			observeBackend(bc.name, req.URL.Host, "breaker", 0)
+			return nil, fmt.Errorf("%w: circuit breaker of %s replica %s is open",
+				errBackendUnavailable, bc.name, req.URL.Host)
+		}
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		time0 := time.Now()
+		rsp, err := bc.client.Do(req)
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(rsp.StatusCode)
+		}
+		observeBackend(bc.name, req.URL.Host, code, time.Since(time0))
+		if err != nil && req.Context().Err() != nil {
+			// requests cancelled by clients say nothing about ML backend
+			breaker.release()
+		} else {
+			failed := err != nil || rsp.StatusCode >= http.StatusInternalServerError
+			breaker.record(bc.config, failed)
+		}
+		retry := err != nil || retryStatus(rsp.StatusCode)
+		if !retry || attempt >= retries || req.Context().Err() != nil ||
+			(req.Body != nil && req.GetBody == nil) {
+			return rsp, err
+		}
+		if rsp != nil {
+			io.Copy(io.Discard, rsp.Body)
+			rsp.Body.Close()
+		}
+		// full jitter backoff
+		sleep := time.Duration(rand.Int63n(int64(delay) + 1))
+		if Verbose > 0 {
+			log.Printf("retry request to %s in %v, attempt %d error %v", req.URL, sleep, attempt+1, err)
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(sleep):
+		}
+		delay *= 2
+	}
+}
+
+// helper function to get state of circuit breaker of ML backend replica
+func breakerState(backend srvConfig.MLBackend) string {
+	bc := httpClient(backend.Name)
+	if rurl, err := url.Parse(backend.URI); err == nil {
+		return bc.breaker(rurl.Host).State()
+	}
+	return BreakerClosed
+}
+
+// helper function to get states of circuit breakers keyed by backend name
+// and replica host
+func breakerStates() map[string]map[string]string {
+	states := make(map[string]map[string]string)
+	backendClients.Lock()
+	defer backendClients.Unlock()
+	for name, bc := range backendClients.clients {
+		states[name] = make(map[string]string)
+		bc.mutex.Lock()
+		for host, b := range bc.breakers {
+			states[name][host] = b.State()
+		}
+		bc.mutex.Unlock()
+	}
+	return states
+}
+
+func init() {
+	// expose circuit breakers states via expvar metrics
+	expvar.Publish("mlhub_breakers", expvar.Func(func() any {
+		return breakerStates()
+	}))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestCircuitBreaker tests transitions of circuit breaker states
+func TestCircuitBreaker(t *testing.T) {
+	config := TransportConfig{BreakerFailures: 3, BreakerReset: 60}
+	b := &circuitBreaker{state: BreakerClosed}
+	for i := 0; i < config.BreakerFailures-1; i++ {
+		if !b.allow(config) {
+			t.Fatalf("closed breaker rejected request %d", i)
+		}
+		b.record(config, true)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("breaker is %s after %d failures", b.State(), config.BreakerFailures-1)
+	}
+	// success resets consecutive failures
+	b.record(config, false)
+	for i := 0; i < config.BreakerFailures-1; i++ {
+		b.record(config, true)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("breaker is %s, failures were not reset by success", b.State())
+	}
+	b.record(config, true)
+	if b.State() != BreakerOpen {
+		t.Fatalf("breaker is %s after %d failures", b.State(), config.BreakerFailures)
+	}
+	if b.allow(config) {
+		t.Fatal("open breaker allowed request before reset timeout")
+	}
+
+	// once reset timeout passed only single trial request is allowed
+	config.BreakerReset = 0
+	if !b.allow(config) {
+		t.Fatal("open breaker rejected trial request after reset timeout")
+	}
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("breaker is %s after reset timeout", b.State())
+	}
+	if b.allow(config) {
+		t.Fatal("half-open breaker allowed second trial request")
+	}
+	// failed trial opens breaker again
+	b.record(config, true)
+	if b.State() != BreakerOpen {
+		t.Fatalf("breaker is %s after failed trial", b.State())
+	}
+	// successful trial closes breaker
+	if !b.allow(config) {
+		t.Fatal("open breaker rejected trial request after reset timeout")
+	}
+	b.record(config, false)
+	if b.State() != BreakerClosed || b.failures != 0 {
+		t.Fatalf("breaker is %s with %d failures after successful trial", b.State(), b.failures)
+	}
+}
+
+// TestCircuitBreakerRelease tests that released trial request does not
+// change state of circuit breaker
+func TestCircuitBreakerRelease(t *testing.T) {
+	config := TransportConfig{BreakerFailures: 3}
+	b := &circuitBreaker{state: BreakerOpen, failures: 3}
+	if !b.allow(config) {
+		t.Fatal("open breaker rejected trial request after reset timeout")
+	}
+	b.release()
+	if b.State() != BreakerHalfOpen || b.failures != 3 {
+		t.Fatalf("breaker is %s with %d failures after released trial", b.State(), b.failures)
+	}
+	if !b.allow(config) {
+		t.Fatal("half-open breaker rejected trial request after released trial")
+	}
+}
+
+// TestBackendClientCancel tests that requests cancelled by client do not
+// change state of circuit breaker of ML backend replica
+func TestBackendClientCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	bc := &backendClient{
+		name:     "test",
+		config:   TransportConfig{BreakerFailures: 3},
+		client:   srv.Client(),
+		breakers: make(map[string]*circuitBreaker),
+	}
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := bc.breaker(req.URL.Host)
+	breaker.state = BreakerOpen
+	breaker.failures = 3
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := bc.Do(req.WithContext(ctx), true); err == nil {
+		t.Fatal("cancelled request did not fail")
+	}
+	if breaker.State() != BreakerHalfOpen || breaker.failures != 3 || breaker.trial {
+		t.Fatalf("breaker is %s with %d failures and trial %v after cancelled request",
+			breaker.State(), breaker.failures, breaker.trial)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+// trash module provides soft delete of ML models, deleted ML models are
+// undeployed from ML backends and kept in trash until they are restored or
+// purged after retention period
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+)
+
+// helper function to get trash area of ML bundles
+func trashDir() string {
+	if HubConfig.Trash.Dir != "" {
+		return HubConfig.Trash.Dir
+	}
+	return filepath.Join(filepath.Dir(filepath.Clean(StorageDir)), "trash")
+}
+
+// helper function to get directory of ML model version within given area
+func versionDir(area string, rec Record) string {
+	return filepath.Join(area, rec.Type, rec.Model, rec.Version)
+}
+
+// helper function to move directory of ML model version between storage
+// and trash areas, missing source directory is not an error
+func moveVersion(src, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+// trashRecords retrieves records of ML models in trash matching given spec
+func trashRecords(spec map[string]any, idx, limit int) ([]Record, error) {
+	tspec := map[string]any{"deleted": map[string]any{"$gt": 0}}
+	for key, val := range spec {
+		tspec[key] = val
+	}
+	return metaPage(tspec, idx, limit)
+}
+
+// helper function to get record of ML model version in trash
+func trashRecord(model, mlType, version string) (Record, error) {
+	var rec Record
+	records, err := trashRecords(map[string]any{"model": model, "type": mlType, "version": version}, 0, -1)
+	if err != nil {
+		return rec, err
+	}
+	if len(records) != 1 {
+		msg := fmt.Sprintf("ML model %s type %s version %s is not in trash", model, mlType, version)
+		return rec, errors.New(msg)
+	}
+	return records[0], nil
+}
+
+// undeployBundle removes ML model from all healthy replicas of its ML
+// backend. TFaaS serves ML models by name, therefore ML model is kept on
+// ML backend while other versions of ML model are still active, while OIP
+// ML backends serve every version of ONNX model under its own name
+func undeployBundle(ctx context.Context, rec Record) error {
+	if rec.Type != "TensorFlow" && rec.Type != "ONNX" {
+		// other ML backends do not support upload of ML models
+		return nil
+	}
+	if rec.Type == "TensorFlow" {
+		records, err := metaRecords(rec.Model, rec.Type, "")
+		if err != nil {
+			return fmt.Errorf("[MLHub.main.undeployBundle] metaRecords error: %w", err)
+		}
+		for _, r := range records {
+			if r.Version != rec.Version && r.Backend == rec.Backend {
+				if Verbose > 0 {
+					log.Printf("keep %s on ML backend %s, version %s is active", rec.Model, rec.Backend, r.Version)
+				}
+				return nil
+			}
+		}
+	}
+	backend, err := mlBackend(rec.Backend, rec.Type)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.undeployBundle] mlBackend error: %w", err)
+	}
+	var errs []error
+	for _, replica := range backendReplicas(backend) {
+		if status := backendHealth(replica); !status.Healthy {
+			log.Printf("WARNING: skip undeploy of %s from unhealthy replica %s", rec.Model, replica.URI)
+			continue
+		}
+		if err := undeployReplica(ctx, replica, rec); err != nil {
+			log.Printf("ERROR: unable to undeploy %s from replica %s, error %v", rec.Model, replica.URI, err)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("[MLHub.main.undeployBundle] undeploy error: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// helper function to remove ML model from given replica of ML backend
+func undeployReplica(ctx context.Context, replica srvConfig.MLBackend, rec Record) error {
+	if rec.Type == "ONNX" {
+		return undeployReplicaOIP(ctx, replica, rec)
+	}
+	return undeployReplicaTFaaS(ctx, replica, rec)
+}
+
+// helper function to remove ML model from given TFaaS replica
+func undeployReplicaTFaaS(ctx context.Context, replica srvConfig.MLBackend, rec Record) error {
+	uri := fmt.Sprintf("%s/delete?model=%s", replica.URI, url.QueryEscape(rec.Model))
+	if Verbose > 0 {
+		log.Printf("undeploy model %s from %s", rec.Model, uri)
+	}
+	// undeploy should complete even if client of MLHub went away
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), "DELETE", uri, nil)
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.undeployReplicaTFaaS] http.NewRequest error: %w", err)
+	}
+	rsp, err := httpClient(replica.Name).Do(req, true)
+	if err != nil {
+		backendFailure(replica, err)
+		return fmt.Errorf("[MLHub.main.undeployReplicaTFaaS] client.Do error: %w", err)
+	}
+	defer rsp.Body.Close()
+	// ML model which is not loaded by replica is already undeployed
+	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusNotFound {
+		msg := fmt.Sprintf("TFaaS response status %s", rsp.Status)
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// trashModel soft deletes ML model version, i.e. it undeploys ML model from
+// its ML backend, moves its bundle to trash area and marks its record as
+// deleted. Previous copy of the same version in trash is purged
+func trashModel(ctx context.Context, rec Record) error {
+	if old, err := trashRecord(rec.Model, rec.Type, rec.Version); err == nil {
+		if err := purgeModel(old); err != nil {
+			return err
+		}
+	}
+	if err := undeployBundle(ctx, rec); err != nil {
+		return err
+	}
+	if err := moveVersion(versionDir(StorageDir, rec), versionDir(trashDir(), rec)); err != nil {
+		return fmt.Errorf("[MLHub.main.trashModel] moveVersion error: %w", err)
+	}
+	if Verbose > 0 {
+		log.Printf("move %s type %s version %s to trash", rec.Model, rec.Type, rec.Version)
+	}
+	if err := metaSet(rec, map[string]any{"deleted": time.Now().Unix(), "replicas": []string{}}); err != nil {
+		return fmt.Errorf("[MLHub.main.trashModel] metaSet error: %w", err)
+	}
+	return nil
+}
+
+// restoreModel restores ML model version from trash, i.e. it moves its
+// bundle back to storage, redeploys it to ML backend and marks its record as
+// active. It fails if the same version of ML model was uploaded again
+func restoreModel(ctx context.Context, model, mlType, version string) (Record, error) {
+	rec, err := trashRecord(model, mlType, version)
+	if err != nil {
+		return rec, err
+	}
+	if records, err := metaRecords(model, mlType, version); err == nil && len(records) > 0 {
+		msg := fmt.Sprintf("ML model %s type %s version %s already exists", model, mlType, version)
+		return rec, errors.New(msg)
+	}
+	src, dst := versionDir(trashDir(), rec), versionDir(StorageDir, rec)
+	if err := moveVersion(src, dst); err != nil {
+		return rec, fmt.Errorf("[MLHub.main.restoreModel] moveVersion error: %w", err)
+	}
+	bf := FileBundle(filepath.Join(dst, rec.Bundle))
+	replicas, err := uploadBundle(ctx, rec, bf)
+	if err != nil {
+		// keep ML model in trash if it can not be redeployed
+		if merr := moveVersion(dst, src); merr != nil {
+			log.Printf("ERROR: unable to move %s back to trash, error %v", dst, merr)
+		}
+		return rec, fmt.Errorf("[MLHub.main.restoreModel] uploadBundle error: %w", err)
+	}
+	err = metaSet(rec, map[string]any{"deleted": 0, "replicas": replicas})
+	if err != nil {
+		return rec, fmt.Errorf("[MLHub.main.restoreModel] metaSet error: %w", err)
+	}
+	rec.Deleted = 0
+	rec.Replicas = replicas
+	return rec, nil
+}
+
+// purgeModel permanently removes ML model version from trash
+func purgeModel(rec Record) error {
+	if err := os.RemoveAll(versionDir(trashDir(), rec)); err != nil {
+		return fmt.Errorf("[MLHub.main.purgeModel] os.RemoveAll error: %w", err)
+	}
+	spec := map[string]any{
+		"model":   rec.Model,
+		"type":    rec.Type,
+		"version": rec.Version,
+		"deleted": map[string]any{"$gt": 0},
+	}
+	if err := metaRemove(spec); err != nil {
+		return fmt.Errorf("[MLHub.main.purgeModel] metaRemove error: %w", err)
+	}
+	if Verbose > 0 {
+		log.Printf("purge %s type %s version %s from trash", rec.Model, rec.Type, rec.Version)
+	}
+	return nil
+}
+
+// helper function to purge ML models which are in trash longer than retention period
+func purgeExpired() {
+	cutoff := time.Now().Add(-time.Duration(HubConfig.Trash.Retention) * 24 * time.Hour).Unix()
+	records, err := trashRecords(map[string]any{"deleted": map[string]any{"$gt": 0, "$lt": cutoff}}, 0, -1)
+	if err != nil {
+		log.Printf("ERROR: unable to get expired ML models in trash, error %v", err)
+		return
+	}
+	for _, rec := range records {
+		if err := purgeModel(rec); err != nil {
+			log.Printf("ERROR: unable to purge %s type %s version %s, error %v", rec.Model, rec.Type, rec.Version, err)
+			continue
+		}
+		log.Printf("purged expired %s type %s version %s deleted at %s",
+			rec.Model, rec.Type, rec.Version, time.Unix(rec.Deleted, 0).Format(time.RFC3339))
+	}
+}
+
+// TrashPurger periodically purges ML models which are in trash longer than
+// retention period
+func TrashPurger() {
+	interval := time.Duration(HubConfig.Trash.Interval) * time.Second
+	for {
+		purgeExpired()
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+// ui module holds web UI pages of MLHub
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	"github.com/gin-gonic/gin"
+)
+
+// default number of ML models shown on catalog page
+const pageLimit = 50
+
+// helper function to check if client requested HTML content
+func htmlRequest(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// helper function to provide common template data
+func tmplData() map[string]any {
+	tmpl := make(map[string]any)
+	tmpl["Base"] = srvConfig.Config.MLHub.WebServer.Base
+	tmpl["Title"] = "MLHub"
+	tmpl["User"] = ""
+	return tmpl
+}
+
+// helper function to render HTML page from given template and its data
+func renderPage(c *gin.Context, name string, tmpl map[string]any) {
+	renderStatus(c, http.StatusOK, name, tmpl)
+}
+
+// helper function to render HTML page with given HTTP status code
+func renderStatus(c *gin.Context, code int, name string, tmpl map[string]any) {
+	header := tmplPage("header.tmpl", tmpl)
+	content := tmplPage(name, tmpl)
+	footer := tmplPage("footer.tmpl", tmpl)
+	c.Data(code, "text/html; charset=utf-8", []byte(header+content+footer))
+}
+
+// template functions of web UI pages
+var tmplFuncs = template.FuncMap{
+	"pathEscape": url.PathEscape,
+	"httpURL":    httpURL,
+}
+
+// helper function to check if given link is absolute http or https URL,
+// other links, e.g. javascript: or relative ones, are never rendered as links
+func httpURL(link string) bool {
+	uri, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return (uri.Scheme == "http" || uri.Scheme == "https") && uri.Host != ""
+}
+
+// helper function to execute HTML template of web UI page, html/template
+// escapes values of ML records according to their context on the page
+func tmplPage(name string, tmpl map[string]any) string {
+	t, err := template.New(name).Funcs(tmplFuncs).ParseFS(StaticFs, "static/templates/"+name)
+	if err != nil {
+		log.Printf("ERROR: unable to parse template %s, error %v", name, err)
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, tmpl); err != nil {
+		log.Printf("ERROR: unable to execute template %s, error %v", name, err)
+		return ""
+	}
+	return buf.String()
+}
+
+// helper function to build MongoDB spec from catalog filters of HTTP request,
+// supported filters are q (search in model name and description), type,
+// backend, discipline, origin, user, source (ML models converted from given
+// ML model, e.g. its ONNX exports) and since (ML models uploaded since given
+// unix timestamp)
+func modelsSpec(r *http.Request) map[string]any {
+	spec := map[string]any{}
+	for _, key := range []string{"type", "backend", "discipline", "origin"} {
+		if val := r.FormValue(key); val != "" {
+			spec[key] = val
+		}
+	}
+	if user := r.FormValue("user"); user != "" {
+		spec["username"] = user
+	}
+	if source := r.FormValue("source"); source != "" {
+		spec["conversion.sourcemodel"] = source
+	}
+	if since, err := strconv.ParseInt(r.FormValue("since"), 10, 64); err == nil && since > 0 {
+		spec["updated"] = map[string]any{"$gte": since}
+	}
+	if query := r.FormValue("q"); query != "" {
+		pat := map[string]any{"$regex": regexp.QuoteMeta(query), "$options": "i"}
+		spec["$or"] = []map[string]any{
+			{"model": pat},
+			{"description": pat},
+		}
+	}
+	return spec
+}
+
+// helper function to get pagination parameters of HTTP request
+func pagination(r *http.Request) (int, int) {
+	idx, err := strconv.Atoi(r.FormValue("idx"))
+	if err != nil || idx < 0 {
+		idx = 0
+	}
+	limit, err := strconv.Atoi(r.FormValue("limit"))
+	if err != nil || limit == 0 {
+		limit = -1
+	}
+	return idx, limit
+}
+
+// helper function to get names of configured ML backends
+func backendNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, b := range srvConfig.Config.MLHub.ML.MLBackends {
+		if !seen[b.Name] {
+			seen[b.Name] = true
+			names = append(names, b.Name)
+		}
+	}
+	return names
+}
+
+// helper function to get disciplines of registered ML models
+func disciplines() []string {
+	var out []string
+	records, err := metaRecords("", "", "")
+	if err != nil {
+		return out
+	}
+	seen := make(map[string]bool)
+	for _, rec := range records {
+		if rec.Discipline != "" && !seen[rec.Discipline] {
+			seen[rec.Discipline] = true
+			out = append(out, rec.Discipline)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// helper function to build query string of catalog page with given index
+func pageQuery(r *http.Request, idx, limit int) string {
+	vals := url.Values{}
+	for _, key := range []string{"q", "type", "backend", "discipline", "origin", "user", "source"} {
+		if val := r.FormValue(key); val != "" {
+			vals.Set(key, val)
+		}
+	}
+	vals.Set("idx", strconv.Itoa(idx))
+	vals.Set("limit", strconv.Itoa(limit))
+	return vals.Encode()
+}
+
+// helper function to render ML models catalog page
+func modelsPage(c *gin.Context, title string) {
+	r := c.Request
+	idx, limit := pagination(r)
+	if limit < 0 {
+		limit = pageLimit
+	}
+	records, err := metaPage(modelsSpec(r), idx, limit+1)
+	if err != nil {
+		tmpl := tmplData()
+		tmpl["Content"] = err.Error()
+		renderStatus(c, http.StatusInternalServerError, "error.tmpl", tmpl)
+		return
+	}
+	tmpl := tmplData()
+	tmpl["Title"] = title
+	tmpl["Query"] = r.FormValue("q")
+	tmpl["FilterType"] = r.FormValue("type")
+	tmpl["FilterBackend"] = r.FormValue("backend")
+	tmpl["FilterDiscipline"] = r.FormValue("discipline")
+	tmpl["Types"] = MLTypes
+	tmpl["Backends"] = backendNames()
+	tmpl["Disciplines"] = disciplines()
+	if len(records) > limit {
+		records = records[:limit]
+		tmpl["Next"] = template.URL(pageQuery(r, idx+limit, limit))
+	}
+	if idx > 0 {
+		prev := idx - limit
+		if prev < 0 {
+			prev = 0
+		}
+		tmpl["Prev"] = template.URL(pageQuery(r, prev, limit))
+	}
+	tmpl["Records"] = records
+	renderPage(c, "models.tmpl", tmpl)
+}
+
+// DownloadPageHandler provides HTML page to download ML models
+func DownloadPageHandler(c *gin.Context) {
+	modelsPage(c, "Download ML models")
+}
+
+// UploadPageHandler provides HTML page to upload ML models
+func UploadPageHandler(c *gin.Context) {
+	tmpl := tmplData()
+	tmpl["Types"] = MLTypes
+	tmpl["Backends"] = backendNames()
+	if nodes, err := domainTree(); err == nil {
+		tmpl["Domains"] = nodes
+	}
+	renderPage(c, "upload.tmpl", tmpl)
+}
+
+// InferencePageHandler provides HTML page to run ML inference
+func InferencePageHandler(c *gin.Context) {
+	records, err := metaRecords("", "", "")
+	tmpl := tmplData()
+	if err != nil {
+		tmpl["Content"] = err.Error()
+		renderStatus(c, http.StatusInternalServerError, "error.tmpl", tmpl)
+		return
+	}
+	tmpl["Records"] = records
+	renderPage(c, "inference.tmpl", tmpl)
+}
@@ -0,0 +1,241 @@
+package main
+
+// uploads module provides resumable uploads of ML bundles
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// errUploadOffset is returned when chunk offset does not match size of resumable upload
+var errUploadOffset = errors.New("wrong upload offset")
+
+// errUploadOwner is returned when resumable upload belongs to other user
+var errUploadOwner = errors.New("upload belongs to other user")
+
+// uploadPattern defines allowed upload IDs
+var uploadPattern = regexp.MustCompile("^[0-9a-zA-Z_-]{8,128}$")
+
+// uploadOwnerFile keeps name of user who started resumable upload
+const uploadOwnerFile = ".owner"
+
+// uploadLock serializes requests to the same resumable upload, refs counts
+// requests which hold or wait for the lock
+type uploadLock struct {
+	sync.Mutex
+	refs int
+}
+
+// uploadLocks keeps locks of resumable uploads keyed by upload ID
+var uploadLocks = struct {
+	sync.Mutex
+	locks map[string]*uploadLock
+}{locks: make(map[string]*uploadLock)}
+
+// lockUpload locks resumable upload with given ID and returns function
+// which unlocks it
+func lockUpload(id string) func() {
+	uploadLocks.Lock()
+	lock, ok := uploadLocks.locks[id]
+	if !ok {
+		lock = &uploadLock{}
+		uploadLocks.locks[id] = lock
+	}
+	lock.refs++
+	uploadLocks.Unlock()
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		uploadLocks.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(uploadLocks.locks, id)
+		}
+		uploadLocks.Unlock()
+	}
+}
+
+// helper function to get staging directory of given upload ID
+func stagingDir(id string) (string, error) {
+	if !uploadPattern.MatchString(id) {
+		msg := fmt.Sprintf("invalid upload ID '%s'", id)
+		return "", errors.New(msg)
+	}
+	return filepath.Join(HubConfig.Uploads.Dir, id), nil
+}
+
+// stagedUpload returns status of resumable upload for given ID, the upload
+// should belong to given user
+func stagedUpload(id, user string) (UploadStatus, error) {
+	status := UploadStatus{ID: id}
+	dir, err := stagingDir(id)
+	if err != nil {
+		return status, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return status, nil
+		}
+		return status, fmt.Errorf("[MLHub.main.stagedUpload] os.ReadDir error: %w", err)
+	}
+	owner, err := os.ReadFile(filepath.Join(dir, uploadOwnerFile))
+	if err != nil && !os.IsNotExist(err) {
+		return status, fmt.Errorf("[MLHub.main.stagedUpload] os.ReadFile error: %w", err)
+	}
+	if string(owner) != user {
+		return status, fmt.Errorf("%w, upload %s", errUploadOwner, id)
+	}
+	for _, entry := range entries {
+		if entry.Name() == uploadOwnerFile {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return status, fmt.Errorf("[MLHub.main.stagedUpload] entry.Info error: %w", err)
+		}
+		status.Name = entry.Name()
+		status.Size = info.Size()
+	}
+	return status, nil
+}
+
+// stageChunk appends chunk of ML bundle to resumable upload with given ID.
+// The offset should match number of already uploaded bytes, and new upload
+// is owned by given user
+func stageChunk(id, user, name string, offset int64, chunk io.Reader) (UploadStatus, error) {
+	defer lockUpload(id)()
+	status, err := stagedUpload(id, user)
+	if err != nil {
+		return status, err
+	}
+	name = filepath.Base(name)
+	if name == "." || name == string(filepath.Separator) {
+		return status, errors.New("bundle file name is empty")
+	}
+	if name == uploadOwnerFile {
+		msg := fmt.Sprintf("bundle file name '%s' is not allowed", name)
+		return status, errors.New(msg)
+	}
+	if status.Name != "" && status.Name != name {
+		msg := fmt.Sprintf("upload %s belongs to bundle %s", id, status.Name)
+		return status, errors.New(msg)
+	}
+	if offset != status.Size {
+		return status, fmt.Errorf("%w %d of upload %s, expected %d", errUploadOffset, offset, id, status.Size)
+	}
+	dir, _ := stagingDir(id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return status, fmt.Errorf("[MLHub.main.stageChunk] os.MkdirAll error: %w", err)
+	}
+	if status.Name == "" {
+		if err := os.WriteFile(filepath.Join(dir, uploadOwnerFile), []byte(user), 0644); err != nil {
+			return status, fmt.Errorf("[MLHub.main.stageChunk] os.WriteFile error: %w", err)
+		}
+	}
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return status, fmt.Errorf("[MLHub.main.stageChunk] os.OpenFile error: %w", err)
+	}
+	defer file.Close()
+	size, err := io.Copy(file, chunk)
+	status.Name = name
+	status.Size += size
+	if err != nil {
+		return status, fmt.Errorf("[MLHub.main.stageChunk] io.Copy error: %w", err)
+	}
+	return status, nil
+}
+
+// stagedBundle returns ML bundle file of resumable upload with given ID
+// which belongs to given user, callers should hold lock of the upload
+// until they are done with ML bundle
+func stagedBundle(id, user string) (BundleFile, error) {
+	status, err := stagedUpload(id, user)
+	if err != nil {
+		return BundleFile{}, err
+	}
+	if status.Name == "" {
+		msg := fmt.Sprintf("upload %s does not exist", id)
+		return BundleFile{}, errors.New(msg)
+	}
+	dir, _ := stagingDir(id)
+	return FileBundle(filepath.Join(dir, status.Name)), nil
+}
+
+// removeStagedUpload removes resumable upload with given ID
+func removeStagedUpload(id string) error {
+	dir, err := stagingDir(id)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// purgeStaleUploads removes resumable uploads which did not receive chunks
+// longer than retention period
+func purgeStaleUploads() {
+	entries, err := os.ReadDir(HubConfig.Uploads.Dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("ERROR: unable to read uploads area, error %v", err)
+		}
+		return
+	}
+	expire := time.Now().Add(-time.Duration(HubConfig.Uploads.Retention) * time.Hour)
+	for _, entry := range entries {
+		id := entry.Name()
+		if !entry.IsDir() || !uploadPattern.MatchString(id) {
+			continue
+		}
+		unlock := lockUpload(id)
+		if stale, err := staleUpload(filepath.Join(HubConfig.Uploads.Dir, id), expire); err != nil {
+			log.Printf("ERROR: unable to check upload %s, error %v", id, err)
+		} else if stale {
+			if err := removeStagedUpload(id); err != nil {
+				log.Printf("ERROR: unable to remove stale upload %s, error %v", id, err)
+			} else {
+				log.Printf("removed stale upload %s", id)
+			}
+		}
+		unlock()
+	}
+}
+
+// helper function to check if files of staged upload were not modified
+// since given time
+func staleUpload(dir string, expire time.Time) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return false, err
+		}
+		if info.ModTime().After(expire) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// UploadsPurger periodically removes stale resumable uploads
+func UploadsPurger() {
+	interval := time.Duration(HubConfig.Uploads.Interval) * time.Second
+	for {
+		purgeStaleUploads()
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+// webhooks module provides webhook subscriptions of downstream systems to
+// events of ML models, events are signed with HMAC of webhook secret and
+// delivered asynchronously with retries
+//
+// Copyright (c) 2024 - Valentin Kuznetsov <vkuznet@gmail.com>
+//
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	srvConfig "github.com/CHESSComputing/golib/config"
+	mongo "github.com/CHESSComputing/golib/mongo"
+)
+
+// HTTP headers of webhook deliveries
+const (
+	WebhookEventHeader     = "X-MLHub-Event"
+	WebhookDeliveryHeader  = "X-MLHub-Delivery"
+	WebhookSignatureHeader = "X-MLHub-Signature"
+)
+
+// WebhookEvents lists events of ML models delivered to webhooks
+var WebhookEvents = []string{AuditUpload, AuditUpdate, AuditPromote, AuditDelete, AuditRestore}
+
+// webhookTask represents delivery of event to webhook
+type webhookTask struct {
+	hook    Webhook
+	event   WebhookEvent
+	payload []byte
+	attempt int
+}
+
+// webhookQueue holds pending deliveries of webhook events
+var webhookQueue chan webhookTask
+var webhookOnce sync.Once
+
+// webhooks retrieves webhooks matching given spec from MLHub database
+func webhooks(spec map[string]any) ([]Webhook, error) {
+	results := mongo.Get(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Webhooks.Collection,
+		spec, 0, -1)
+	var hooks []Webhook
+	for _, rec := range results {
+		var hook Webhook
+		delete(rec, "_id")
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return hooks, fmt.Errorf("[MLHub.main.webhooks] json.Marshal error: %w", err)
+		}
+		err = json.Unmarshal(data, &hook)
+		if err != nil {
+			return hooks, fmt.Errorf("[MLHub.main.webhooks] json.Unmarshal error: %w", err)
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// addWebhook validates and stores new webhook, it returns webhook with its ID
+func addWebhook(hook Webhook) (Webhook, error) {
+	uri, err := url.Parse(hook.URL)
+	if err != nil || (uri.Scheme != "http" && uri.Scheme != "https") || uri.Host == "" {
+		msg := fmt.Sprintf("webhook URL '%s' should be valid http or https URL", hook.URL)
+		return hook, errors.New(msg)
+	}
+	if hook.Secret == "" {
+		return hook, errors.New("webhook requires secret to sign its events")
+	}
+	for _, event := range hook.Events {
+		if !slices.Contains(WebhookEvents, event) {
+			msg := fmt.Sprintf("unsupported webhook event '%s', should be one of %v", event, WebhookEvents)
+			return hook, errors.New(msg)
+		}
+	}
+	if hook.Discipline != "" {
+		discipline, err := validateDiscipline(hook.Discipline)
+		if err != nil {
+			return hook, err
+		}
+		hook.Discipline = discipline
+	}
+	hook.ID = newID()
+	hook.Timestamp = time.Now().Unix()
+	if Verbose > 0 {
+		log.Printf("add webhook %s url %s events %v", hook.ID, hook.URL, hook.Events)
+	}
+	var records []any
+	records = append(records, hook)
+	err = mongo.UpsertAny(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Webhooks.Collection,
+		records)
+	if err != nil {
+		return hook, fmt.Errorf("[MLHub.main.addWebhook] mongo.UpsertAny error: %w", err)
+	}
+	return hook, nil
+}
+
+// helper function to check if host of webhook URL is allowed for users
+func webhookHostAllowed(rawURL string) bool {
+	uri, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	for _, host := range HubConfig.Webhooks.Hosts {
+		if strings.EqualFold(uri.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeWebhook removes webhook with given ID
+func removeWebhook(id string) error {
+	err := mongo.Remove(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Webhooks.Collection,
+		map[string]any{"id": id})
+	if err != nil {
+		return fmt.Errorf("[MLHub.main.removeWebhook] mongo.Remove error: %w", err)
+	}
+	return nil
+}
+
+// helper function to check if webhook subscribes to event of given ML record
+func webhookMatch(hook Webhook, event string, rec Record) bool {
+	if len(hook.Events) > 0 && !slices.Contains(hook.Events, event) {
+		return false
+	}
+	if hook.Model != "" && hook.Model != rec.Model {
+		return false
+	}
+	if hook.Discipline != "" && hook.Discipline != rec.Discipline {
+		return false
+	}
+	return true
+}
+
+// helper function to sign webhook payload with HMAC-SHA256 of its secret
+func webhookSignature(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyWebhooks queues event of ML model to all matching webhooks, it never
+// blocks its caller, i.e. events are dropped and logged when queue is full
+func notifyWebhooks(event string, rec Record, details string) {
+	hooks, err := webhooks(map[string]any{})
+	if err != nil {
+		log.Printf("ERROR: unable to get webhooks, error %v", err)
+		return
+	}
+	evt := WebhookEvent{
+		ID:        newID(),
+		Event:     event,
+		Timestamp: time.Now().Unix(),
+		Details:   details,
+		Record:    rec,
+	}
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		log.Printf("ERROR: unable to encode webhook event %+v, error %v", evt, err)
+		return
+	}
+	for _, hook := range hooks {
+		if webhookMatch(hook, event, rec) {
+			enqueueWebhook(webhookTask{hook: hook, event: evt, payload: payload, attempt: 1})
+		}
+	}
+}
+
+// helper function to add delivery to webhook queue, it starts delivery
+// workers on first use
+func enqueueWebhook(task webhookTask) {
+	webhookOnce.Do(func() {
+		webhookQueue = make(chan webhookTask, HubConfig.Webhooks.Queue)
+		client := &http.Client{Timeout: time.Duration(HubConfig.Webhooks.Timeout) * time.Second}
+		for i := 0; i < HubConfig.Webhooks.Workers; i++ {
+			go webhookWorker(client)
+		}
+	})
+	select {
+	case webhookQueue <- task:
+	default:
+		logDelivery(task, 0, errors.New("webhook queue is full"))
+	}
+}
+
+// helper function to deliver queued webhook events, failed deliveries are
+// retried with exponential backoff until number of retries is exhausted
+func webhookWorker(client *http.Client) {
+	for task := range webhookQueue {
+		status, err := deliverWebhook(client, task)
+		logDelivery(task, status, err)
+		if err == nil || task.attempt > HubConfig.Webhooks.Retries {
+			continue
+		}
+		backoff := time.Duration(HubConfig.Webhooks.Backoff) * time.Second << (task.attempt - 1)
+		task.attempt++
+		time.AfterFunc(backoff, func() { enqueueWebhook(task) })
+	}
+}
+
+// helper function to send signed event to webhook, any non 2xx status code
+// is considered as failed delivery
+func deliverWebhook(client *http.Client, task webhookTask) (int, error) {
+	req, err := http.NewRequest("POST", task.hook.URL, bytes.NewReader(task.payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, task.event.Event)
+	req.Header.Set(WebhookDeliveryHeader, task.event.ID)
+	req.Header.Set(WebhookSignatureHeader, webhookSignature(task.hook.Secret, task.payload))
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// helper function to store attempt of webhook delivery in delivery log
+func logDelivery(task webhookTask, status int, derr error) {
+	delivery := WebhookDelivery{
+		Webhook:   task.hook.ID,
+		Event:     task.event.Event,
+		EventID:   task.event.ID,
+		Model:     task.event.Record.Model,
+		Attempt:   task.attempt,
+		Status:    status,
+		Delivered: derr == nil,
+		Timestamp: time.Now().Unix(),
+	}
+	if derr != nil {
+		delivery.Error = derr.Error()
+	}
+	if Verbose > 0 {
+		log.Printf("webhook delivery %+v", delivery)
+	}
+	var records []any
+	records = append(records, delivery)
+	err := mongo.UpsertAny(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Webhooks.Deliveries,
+		records)
+	if err != nil {
+		log.Printf("ERROR: unable to log webhook delivery %+v, error %v", delivery, err)
+	}
+}
+
+// webhookDeliveries retrieves delivery log of webhook with given ID
+func webhookDeliveries(id string, idx, limit int) ([]WebhookDelivery, error) {
+	results := mongo.Get(
+		srvConfig.Config.MLHub.MongoDB.DBName,
+		HubConfig.Webhooks.Deliveries,
+		map[string]any{"webhook": id}, idx, limit)
+	var deliveries []WebhookDelivery
+	for _, rec := range results {
+		var delivery WebhookDelivery
+		delete(rec, "_id")
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return deliveries, fmt.Errorf("[MLHub.main.webhookDeliveries] json.Marshal error: %w", err)
+		}
+		err = json.Unmarshal(data, &delivery)
+		if err != nil {
+			return deliveries, fmt.Errorf("[MLHub.main.webhookDeliveries] json.Unmarshal error: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}